@@ -0,0 +1,130 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Kind категория ошибки, по которой вызывающий код решает, можно ли повторить операцию
+type Kind int
+
+const (
+	Unknown Kind = iota
+	// Config неверные или отсутствующие параметры
+	Config
+	// Auth неверные ключи, куки или истекший токен
+	Auth
+	// RateLimit превышено ограничение на количество запросов
+	RateLimit
+	// Validation запрос отклонен из-за неверных данных
+	Validation
+	// Transport сеть недоступна или сервер вернул 5xx
+	Transport
+)
+
+func (kind Kind) String() string {
+	switch kind {
+	case Config:
+		return "config"
+	case Auth:
+		return "auth"
+	case RateLimit:
+		return "rate_limit"
+	case Validation:
+		return "validation"
+	case Transport:
+		return "transport"
+	default:
+		return "unknown"
+	}
+}
+
+// Error ошибка с категорией. errors.Is(err, apperrors.ErrAuth) проверяет категорию
+type Error struct {
+	Kind Kind
+	Msg  string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Msg
+	}
+	if e.Msg == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Msg, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Msg == "" && t.Err == nil && t.Kind == e.Kind
+}
+
+var (
+	ErrConfig     = &Error{Kind: Config}
+	ErrAuth       = &Error{Kind: Auth}
+	ErrRateLimit  = &Error{Kind: RateLimit}
+	ErrValidation = &Error{Kind: Validation}
+	ErrTransport  = &Error{Kind: Transport}
+)
+
+func New(kind Kind, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Msg: fmt.Sprintf(format, args...)}
+}
+
+func Wrap(kind Kind, err error, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Msg: fmt.Sprintf(format, args...), Err: err}
+}
+
+// FromStatus ошибка для неуспешного HTTP ответа
+func FromStatus(status int, body string) error {
+	kind := Unknown
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		kind = Auth
+	case status == http.StatusTooManyRequests:
+		kind = RateLimit
+	case status == http.StatusBadRequest || status == http.StatusUnprocessableEntity || status == http.StatusNotFound:
+		kind = Validation
+	case status >= 500:
+		kind = Transport
+	}
+	return &Error{Kind: kind, Msg: fmt.Sprintf("API error: %d %s", status, body)}
+}
+
+// KindOf категория ошибки или Unknown
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return Unknown
+}
+
+// severity категории от самой серьезной к наименее серьезной: ошибки, требующие вмешательства,
+// важнее временных
+var severity = []Kind{Config, Auth, Validation, RateLimit, Transport, Unknown}
+
+// MostSevere самая серьезная категория среди ошибок errs. Unknown, если ошибок нет
+func MostSevere(errs ...error) Kind {
+	res := len(severity) - 1
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		kind := KindOf(err)
+		for i := 0; i < res; i++ {
+			if severity[i] == kind {
+				res = i
+				break
+			}
+		}
+	}
+	return severity[res]
+}
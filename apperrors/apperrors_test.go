@@ -0,0 +1,29 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestMostSevere(t *testing.T) {
+	tests := []struct {
+		name string
+		errs []error
+		want Kind
+	}{
+		{"нет ошибок", nil, Unknown},
+		{"только nil", []error{nil}, Unknown},
+		{"ошибка без категории", []error{errors.New("x")}, Unknown},
+		{"категория важнее неизвестной", []error{errors.New("x"), New(Transport, "сеть")}, Transport},
+		{"авторизация важнее сети", []error{New(Transport, "сеть"), New(Auth, "ключ")}, Auth},
+		{"обернутая ошибка", []error{New(RateLimit, "лимит"), fmt.Errorf("проход: %w", New(Config, "файл"))}, Config},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MostSevere(tt.errs...); got != tt.want {
+				t.Errorf("получено %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
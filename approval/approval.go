@@ -0,0 +1,197 @@
+package approval
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/ozon"
+	"PriceGuardian/statefile"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry товар, который классификатор предлагает поместить на карантин, вместе с отзывами,
+// из-за которых он попал в очередь
+type Entry struct {
+	OfferID   string                `json:"offer_id"`
+	Action    ozon.QuarantineAction `json:"action"`
+	Reviews   []ozon.Review         `json:"reviews"`
+	RunID     string                `json:"run_id,omitempty"`
+	CreatedAt time.Time             `json:"created_at"`
+	ExpiresAt time.Time             `json:"expires_at"`
+}
+
+// Queue очередь решений, ожидающих подтверждения оператором, сохраняемая в файл между запусками
+type Queue struct {
+	path    string
+	ttl     time.Duration
+	mu      sync.Mutex
+	Entries map[string]Entry `json:"entries"`
+}
+
+// Load загружает очередь из path. ttl сколько решение ждет подтверждения, прежде чем будет отброшено
+func Load(path string, ttl time.Duration) (*Queue, error) {
+	queue := &Queue{path: path, ttl: ttl, Entries: make(map[string]Entry)}
+	if err := queue.reload(); err != nil {
+		return nil, err
+	}
+	return queue, nil
+}
+
+// reload перечитывает очередь из файла: его могли изменить демон или команда CLI в другом процессе
+func (queue *Queue) reload() error {
+	var state struct {
+		Entries map[string]Entry `json:"entries"`
+	}
+	found, err := statefile.Read(queue.path, &state)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать очередь подтверждений %w", err)
+	}
+	if !found {
+		return nil
+	}
+	if state.Entries == nil {
+		state.Entries = make(map[string]Entry)
+	}
+	queue.Entries = state.Entries
+	return nil
+}
+
+// update изменяет очередь под блокировкой файла, перечитав его перед изменением,
+// чтобы не затереть решения, принятые в другом процессе. Если change возвращает ошибку, файл не меняется
+func (queue *Queue) update(change func() (bool, error)) error {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	unlock, err := statefile.Lock(queue.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := queue.reload(); err != nil {
+		return err
+	}
+	changed, err := change()
+	if err != nil || !changed {
+		return err
+	}
+	return queue.save()
+}
+
+// Add ставит товары в очередь. Если товар уже ожидает подтверждения, к нему добавляются новые отзывы,
+// а срок ожидания продлевается. Возвращает offer_id товаров, впервые попавших в очередь
+func (queue *Queue) Add(entries []Entry) ([]string, error) {
+	var added []string
+	err := queue.update(func() (bool, error) {
+		now := time.Now()
+		for _, entry := range entries {
+			if existing, ok := queue.Entries[entry.OfferID]; ok {
+				entry.Reviews = mergeReviews(existing.Reviews, entry.Reviews)
+				entry.CreatedAt = existing.CreatedAt
+			} else {
+				entry.CreatedAt = now
+				added = append(added, entry.OfferID)
+			}
+			entry.ExpiresAt = now.Add(queue.ttl)
+			queue.Entries[entry.OfferID] = entry
+		}
+		return true, nil
+	})
+	return added, err
+}
+
+// Take убирает товары из очереди и возвращает их записи. Если какого-то товара нет в очереди,
+// очередь не меняется
+func (queue *Queue) Take(offerIDs []string) ([]Entry, error) {
+	var taken []Entry
+	err := queue.update(func() (bool, error) {
+		var missing []string
+		for _, offerID := range offerIDs {
+			if _, ok := queue.Entries[offerID]; !ok {
+				missing = append(missing, offerID)
+			}
+		}
+		if len(missing) > 0 {
+			return false, apperrors.New(apperrors.Validation, "товары %s не ожидают подтверждения", strings.Join(missing, ", "))
+		}
+		taken = make([]Entry, 0, len(offerIDs))
+		for _, offerID := range offerIDs {
+			if entry, ok := queue.Entries[offerID]; ok {
+				taken = append(taken, entry)
+				delete(queue.Entries, offerID)
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return taken, nil
+}
+
+// Restore возвращает записи в очередь, например если карантин после подтверждения не удался
+func (queue *Queue) Restore(entries []Entry) error {
+	return queue.update(func() (bool, error) {
+		for _, entry := range entries {
+			queue.Entries[entry.OfferID] = entry
+		}
+		return true, nil
+	})
+}
+
+// Expire убирает из очереди решения, срок подтверждения которых истек к моменту now
+func (queue *Queue) Expire(now time.Time) ([]Entry, error) {
+	var expired []Entry
+	err := queue.update(func() (bool, error) {
+		for offerID, entry := range queue.Entries {
+			if !entry.ExpiresAt.After(now) {
+				expired = append(expired, entry)
+				delete(queue.Entries, offerID)
+			}
+		}
+		return len(expired) > 0, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
+// Pending решения, ожидающие подтверждения, от старых к новым. Если файл очереди прочитать не удалось,
+// возвращаются решения из памяти
+func (queue *Queue) Pending() []Entry {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	if err := queue.reload(); err != nil {
+		slog.Warn("используется очередь подтверждений из памяти", "error", err)
+	}
+	res := make([]Entry, 0, len(queue.Entries))
+	for _, entry := range queue.Entries {
+		res = append(res, entry)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.Before(res[j].CreatedAt) })
+	return res
+}
+
+// mergeReviews добавляет к reviews новые отзывы, пропуская уже известные
+func mergeReviews(reviews, added []ozon.Review) []ozon.Review {
+	known := make(map[string]bool, len(reviews))
+	for _, review := range reviews {
+		known[review.ID] = true
+	}
+	for _, review := range added {
+		if !known[review.ID] {
+			known[review.ID] = true
+			reviews = append(reviews, review)
+		}
+	}
+	return reviews
+}
+
+func (queue *Queue) save() error {
+	if err := statefile.Write(queue.path, queue); err != nil {
+		return fmt.Errorf("не удалось сохранить очередь подтверждений %w", err)
+	}
+	return nil
+}
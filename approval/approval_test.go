@@ -0,0 +1,201 @@
+package approval
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/ozon"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func newQueue(t *testing.T, entries ...Entry) *Queue {
+	queue, err := Load(filepath.Join(t.TempDir(), "approvals.json"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) > 0 {
+		if _, err := queue.Add(entries); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return queue
+}
+
+func reviews(ids ...string) []ozon.Review {
+	res := make([]ozon.Review, 0, len(ids))
+	for _, id := range ids {
+		res = append(res, ozon.Review{ID: id})
+	}
+	return res
+}
+
+func reviewIDs(reviews []ozon.Review) []string {
+	res := make([]string, 0, len(reviews))
+	for _, review := range reviews {
+		res = append(res, review.ID)
+	}
+	return res
+}
+
+func pendingIDs(queue *Queue) []string {
+	var res []string
+	for _, entry := range queue.Pending() {
+		res = append(res, entry.OfferID)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		name        string
+		existing    []Entry
+		add         []Entry
+		wantAdded   []string
+		wantReviews map[string][]string
+	}{
+		{
+			name:        "новые товары",
+			add:         []Entry{{OfferID: "a", Reviews: reviews("r1")}, {OfferID: "b", Reviews: reviews("r2")}},
+			wantAdded:   []string{"a", "b"},
+			wantReviews: map[string][]string{"a": {"r1"}, "b": {"r2"}},
+		},
+		{
+			name:        "отзывы добавляются к ожидающему товару без повторов",
+			existing:    []Entry{{OfferID: "a", Reviews: reviews("r1", "r2")}},
+			add:         []Entry{{OfferID: "a", Reviews: reviews("r2", "r3")}, {OfferID: "b", Reviews: reviews("r4")}},
+			wantAdded:   []string{"b"},
+			wantReviews: map[string][]string{"a": {"r1", "r2", "r3"}, "b": {"r4"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := newQueue(t, tt.existing...)
+			created := make(map[string]time.Time)
+			for offerID, entry := range queue.Entries {
+				created[offerID] = entry.CreatedAt
+			}
+			added, err := queue.Add(tt.add)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(added, tt.wantAdded) {
+				t.Errorf("добавлены %v, ожидалось %v", added, tt.wantAdded)
+			}
+			for offerID, want := range tt.wantReviews {
+				entry := queue.Entries[offerID]
+				if got := reviewIDs(entry.Reviews); !reflect.DeepEqual(got, want) {
+					t.Errorf("отзывы %s: получено %v, ожидалось %v", offerID, got, want)
+				}
+				if at, ok := created[offerID]; ok && !entry.CreatedAt.Equal(at) {
+					t.Errorf("время постановки %s изменилось при добавлении отзывов", offerID)
+				}
+				if !entry.ExpiresAt.After(time.Now().Add(59 * time.Minute)) {
+					t.Errorf("срок ожидания %s не продлен: %v", offerID, entry.ExpiresAt)
+				}
+			}
+		})
+	}
+}
+
+func TestTake(t *testing.T) {
+	tests := []struct {
+		name        string
+		take        []string
+		wantTaken   []string
+		wantErr     bool
+		wantPending []string
+	}{
+		{"часть товаров", []string{"b"}, []string{"b"}, false, []string{"a", "c"}},
+		{"все товары", []string{"c", "a", "b"}, []string{"c", "a", "b"}, false, nil},
+		{"товара нет в очереди, очередь не меняется", []string{"a", "x"}, nil, true, []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := newQueue(t, Entry{OfferID: "a"}, Entry{OfferID: "b"}, Entry{OfferID: "c"})
+			taken, err := queue.Take(tt.take)
+			if tt.wantErr {
+				if apperrors.KindOf(err) != apperrors.Validation {
+					t.Fatalf("ожидалась ошибка валидации, получено %v", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, entry := range taken {
+				got = append(got, entry.OfferID)
+			}
+			if !reflect.DeepEqual(got, tt.wantTaken) {
+				t.Errorf("взяты %v, ожидалось %v", got, tt.wantTaken)
+			}
+			if got := pendingIDs(queue); !reflect.DeepEqual(got, tt.wantPending) {
+				t.Errorf("в очереди %v, ожидалось %v", got, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestExpire(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name        string
+		at          time.Time
+		wantExpired []string
+		wantPending []string
+	}{
+		{"срок не истек", now, nil, []string{"a", "b"}},
+		{"срок истек у всех", now.Add(2 * time.Hour), []string{"a", "b"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := newQueue(t, Entry{OfferID: "a"}, Entry{OfferID: "b"})
+			expired, err := queue.Expire(tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, entry := range expired {
+				got = append(got, entry.OfferID)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.wantExpired) {
+				t.Errorf("истекли %v, ожидалось %v", got, tt.wantExpired)
+			}
+			if got := pendingIDs(queue); !reflect.DeepEqual(got, tt.wantPending) {
+				t.Errorf("в очереди %v, ожидалось %v", got, tt.wantPending)
+			}
+		})
+	}
+}
+
+// TestSharedFile проверяет, что очередь видит изменения, сделанные через другой экземпляр,
+// как при подтверждении командой CLI во время работы демона
+func TestSharedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "approvals.json")
+	daemon, err := Load(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, err := Load(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := daemon.Add([]Entry{{OfferID: "a"}, {OfferID: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.Take([]string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := daemon.Add([]Entry{{OfferID: "c"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"b", "c"}
+	if got := pendingIDs(daemon); !reflect.DeepEqual(got, want) {
+		t.Errorf("в очереди демона %v, ожидалось %v", got, want)
+	}
+	if got := pendingIDs(cli); !reflect.DeepEqual(got, want) {
+		t.Errorf("в очереди CLI %v, ожидалось %v", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/approval"
+	"PriceGuardian/archive"
+	"PriceGuardian/notify"
+	"PriceGuardian/ozon"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// errApprovalDisabled ручное подтверждение запрошено для кабинета, в котором оно не включено
+var errApprovalDisabled = apperrors.New(apperrors.Config, "режим подтверждения карантина выключен, включите quarantine.approval")
+
+// requestApproval ставит товары в очередь подтверждения вместе с отзывами, из-за которых они туда попали
+func (p *Pipeline) requestApproval(ctx context.Context, logger *slog.Logger, runID string,
+	offerIdsByAction map[ozon.QuarantineAction][]string, reviews map[string][]ozon.Review) error {
+	var entries []approval.Entry
+	for action, offerIds := range offerIdsByAction {
+		for _, offerID := range offerIds {
+			entries = append(entries, approval.Entry{OfferID: offerID, Action: action, Reviews: reviews[offerID], RunID: runID})
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	added, err := p.approvals.Add(entries)
+	if err != nil {
+		return err
+	}
+	logger.Info("товары ожидают подтверждения карантина", "offer_ids", added, "queued", len(entries))
+	if len(added) > 0 {
+		p.notify(ctx, logger, notify.Event{Type: notify.EventApprovalRequested, OfferIDs: added,
+			Details: "Подтвердите или отклоните карантин командой approve/reject или через HTTP API"})
+	}
+	return nil
+}
+
+// expireApprovals отбрасывает решения, которые оператор не подтвердил вовремя
+func (p *Pipeline) expireApprovals(summary *RunSummary, logger *slog.Logger) {
+	if p.approvals == nil {
+		return
+	}
+	expired, err := p.approvals.Expire(time.Now())
+	if err != nil {
+		summary.addError(logger, "ошибка при очистке очереди подтверждения", err)
+		return
+	}
+	for _, entry := range expired {
+		logger.Info("срок подтверждения карантина истек", "offer_id", entry.OfferID, "created_at", entry.CreatedAt)
+	}
+	if err := p.updateDecisions(expired, archive.DecisionExpired); err != nil {
+		summary.addError(logger, "ошибка при сохранении отзывов в архив", err)
+	}
+}
+
+// Approve помещает на карантин товары, подтвержденные оператором. Товары, которые не удалось
+// поместить на карантин, возвращаются в очередь, чтобы подтверждение можно было повторить
+func (p *Pipeline) Approve(ctx context.Context, offerIDs []string) (RunSummary, error) {
+	summary := RunSummary{RunID: newRunID(), Account: p.account, StartedAt: time.Now()}
+	if p.approvals == nil {
+		return summary, errApprovalDisabled
+	}
+	logger := slog.With("run_id", summary.RunID, "account", p.account)
+	entries, err := p.approvals.Take(offerIDs)
+	if err != nil {
+		return summary, err
+	}
+	offerIdsByAction := make(map[ozon.QuarantineAction][]string)
+	reviews := make(map[string][]ozon.Review, len(entries))
+	for _, entry := range entries {
+		offerIdsByAction[entry.Action] = append(offerIdsByAction[entry.Action], entry.OfferID)
+		reviews[entry.OfferID] = entry.Reviews
+	}
+	decisions := p.quarantine(ctx, logger, &summary, offerIdsByAction, reviews)
+	var approved, failed []approval.Entry
+	for _, entry := range entries {
+		if decisions[entry.OfferID] == archive.DecisionQuarantined {
+			approved = append(approved, entry)
+		} else {
+			failed = append(failed, entry)
+		}
+	}
+	if len(failed) > 0 {
+		if err := p.approvals.Restore(failed); err != nil {
+			summary.addError(logger, "ошибка при возврате товаров в очередь подтверждения", err)
+		}
+	}
+	if err := p.updateDecisions(approved, archive.DecisionQuarantined); err != nil {
+		summary.addError(logger, "ошибка при сохранении отзывов в архив", err)
+	}
+	summary.FinishedAt = time.Now()
+	if len(summary.Errors) > 0 {
+		return summary, fmt.Errorf("подтверждение карантина завершилось с ошибками: %s", strings.Join(summary.Errors, "; "))
+	}
+	return summary, nil
+}
+
+// Reject убирает товары из очереди подтверждения, не меняя их цены и остатки
+func (p *Pipeline) Reject(offerIDs []string) error {
+	if p.approvals == nil {
+		return errApprovalDisabled
+	}
+	entries, err := p.approvals.Take(offerIDs)
+	if err != nil {
+		return err
+	}
+	slog.Info("карантин отклонен оператором", "account", p.account, "offer_ids", offerIDs)
+	return p.updateDecisions(entries, archive.DecisionRejected)
+}
+
+// updateDecisions записывает в архив решение оператора по отзывам из очереди подтверждения
+func (p *Pipeline) updateDecisions(entries []approval.Entry, decision archive.Decision) error {
+	var records []archive.Record
+	for _, entry := range entries {
+		for _, review := range entry.Reviews {
+			record, ok := p.archive.Get(review.ID)
+			if !ok {
+				continue
+			}
+			record.Decision = decision
+			if quarantine, ok := p.api.QuarantineRecord(entry.OfferID); ok && decision == archive.DecisionQuarantined {
+				record.PriceBefore = quarantine.Price.Price
+				record.PriceAfter = quarantine.QuarantinePrice
+			}
+			records = append(records, record)
+		}
+	}
+	return p.archive.Put(records)
+}
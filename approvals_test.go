@@ -0,0 +1,100 @@
+package main
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/approval"
+	"PriceGuardian/archive"
+	"PriceGuardian/ozon"
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newApprovalPipeline конвейер с очередью подтверждения, в которой ждут kettle и gone.
+// gone нет на площадке, поэтому поместить его на карантин не получится
+func newApprovalPipeline(t *testing.T) *Pipeline {
+	p := newTestPipeline(t, "ozon", "kettle")
+	approvals, err := approval.Load(filepath.Join(t.TempDir(), "approvals.json"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.approvals = approvals
+	var records []archive.Record
+	var entries []approval.Entry
+	for _, offerID := range []string{"kettle", "gone"} {
+		review := ozon.Review{ID: "r-" + offerID, Product: ozon.Product{OfferID: offerID}}
+		records = append(records, archive.Record{Review: review, Label: archive.LabelNegative, Decision: archive.DecisionPending})
+		entries = append(entries, approval.Entry{OfferID: offerID, Action: ozon.ActionPrice, Reviews: []ozon.Review{review}})
+	}
+	if err := p.archive.Put(records); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.approvals.Add(entries); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func decisionOf(t *testing.T, p *Pipeline, reviewID string) archive.Decision {
+	record, ok := p.archive.Get(reviewID)
+	if !ok {
+		t.Fatalf("отзыва %s нет в архиве", reviewID)
+	}
+	return record.Decision
+}
+
+func TestPipelineApprove(t *testing.T) {
+	p := newApprovalPipeline(t)
+	summary, err := p.Approve(context.Background(), []string{"kettle", "gone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"kettle"}; !reflect.DeepEqual(summary.Quarantined, want) {
+		t.Errorf("на карантине %v, ожидалось %v", summary.Quarantined, want)
+	}
+	if _, ok := p.api.QuarantineRecord("kettle"); !ok {
+		t.Error("подтвержденный товар не помещен на карантин")
+	}
+	record, _ := p.archive.Get("r-kettle")
+	if record.Decision != archive.DecisionQuarantined || record.PriceBefore != 100 || record.PriceAfter == 0 {
+		t.Errorf("запись архива %+v, ожидалось решение quarantined с ценами", record)
+	}
+	// непомещенный на карантин товар возвращается в очередь для повторного подтверждения
+	if pending := p.approvals.Pending(); len(pending) != 1 || pending[0].OfferID != "gone" {
+		t.Errorf("в очереди %v, ожидался gone", pending)
+	}
+	if got := decisionOf(t, p, "r-gone"); got != archive.DecisionPending {
+		t.Errorf("решение по gone %q, ожидалось %q", got, archive.DecisionPending)
+	}
+}
+
+func TestPipelineReject(t *testing.T) {
+	p := newApprovalPipeline(t)
+	if err := p.Reject([]string{"gone"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := decisionOf(t, p, "r-gone"); got != archive.DecisionRejected {
+		t.Errorf("решение по gone %q, ожидалось %q", got, archive.DecisionRejected)
+	}
+	if pending := p.approvals.Pending(); len(pending) != 1 || pending[0].OfferID != "kettle" {
+		t.Errorf("в очереди %v, ожидался kettle", pending)
+	}
+	if _, ok := p.api.QuarantineRecord("gone"); ok {
+		t.Error("отклоненный товар помещен на карантин")
+	}
+	if err := p.Reject([]string{"unknown"}); apperrors.KindOf(err) != apperrors.Validation {
+		t.Errorf("получено %v, ожидалась ошибка валидации", err)
+	}
+}
+
+func TestApprovalDisabled(t *testing.T) {
+	p := newTestPipeline(t, "ozon", "kettle")
+	if _, err := p.Approve(context.Background(), []string{"kettle"}); apperrors.KindOf(err) != apperrors.Config {
+		t.Errorf("подтверждение: получено %v, ожидалась ошибка настроек", err)
+	}
+	if err := p.Reject([]string{"kettle"}); apperrors.KindOf(err) != apperrors.Config {
+		t.Errorf("отклонение: получено %v, ожидалась ошибка настроек", err)
+	}
+}
@@ -0,0 +1,334 @@
+package archive
+
+import (
+	"PriceGuardian/ozon"
+	"PriceGuardian/statefile"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Label результат классификации отзыва
+type Label string
+
+const (
+	LabelNegative Label = "negative"
+	LabelPositive Label = "positive"
+	LabelError    Label = "error"
+)
+
+// Decision что было сделано с товаром по итогам прохода, в котором обработан отзыв
+type Decision string
+
+const (
+	DecisionNone           Decision = ""
+	DecisionBelowThreshold Decision = "below_threshold"
+	DecisionDryRun         Decision = "dry_run"
+	DecisionPending        Decision = "pending"
+	DecisionRejected       Decision = "rejected"
+	DecisionExpired        Decision = "expired"
+	DecisionQuarantined    Decision = "quarantined"
+	DecisionFailed         Decision = "failed"
+	// DecisionSkipped товар уже был на карантине или не найден на площадке
+	DecisionSkipped Decision = "skipped"
+)
+
+// Record отзыв вместе с результатом классификации и принятым решением
+type Record struct {
+	Review  ozon.Review `json:"review"`
+	Account string      `json:"account,omitempty"`
+	RunID   string      `json:"run_id,omitempty"`
+	Label   Label       `json:"label"`
+	// Verdict ответ модели как есть, Reason пояснение модели после класса, если оно было
+	Verdict  string                `json:"verdict,omitempty"`
+	Reason   string                `json:"reason,omitempty"`
+	Decision Decision              `json:"decision,omitempty"`
+	Action   ozon.QuarantineAction `json:"action,omitempty"`
+	// PriceBefore и PriceAfter цена до карантина и на время карантина
+	PriceBefore float64   `json:"price_before,omitempty"`
+	PriceAfter  float64   `json:"price_after,omitempty"`
+	ArchivedAt  time.Time `json:"archived_at"`
+}
+
+// Archive локальное хранилище всех обработанных отзывов. Записи дописываются в JSON Lines файл,
+// более поздняя запись отзыва заменяет предыдущую. Индексы строятся в памяти при открытии.
+// Файл пишут и демон, и команды CLI, поэтому запись идет под блокировкой файла, а перед ней
+// дочитываются строки, добавленные другим процессом
+type Archive struct {
+	path string
+	mu   sync.RWMutex
+	// lines сколько строк в файле, чтобы понять, когда его стоит сжать
+	lines int
+	// file и size прочитанный файл и сколько байт из него проиндексировано
+	file    os.FileInfo
+	size    int64
+	records map[string]Record
+	byOffer map[string]map[string]struct{}
+	bySKU   map[string]map[string]struct{}
+	byLabel map[Label]map[string]struct{}
+	// byDate идентификаторы отзывов, отсортированные по времени публикации и идентификатору
+	byDate []dated
+	tokens map[string]map[string]struct{}
+}
+
+type dated struct {
+	at time.Time
+	id string
+}
+
+func (d dated) before(other dated) bool {
+	if !d.at.Equal(other.at) {
+		return d.at.Before(other.at)
+	}
+	return d.id < other.id
+}
+
+// Open загружает архив из path. Если файла нет, он будет создан при первой записи
+func Open(path string) (*Archive, error) {
+	archive := &Archive{path: path}
+	archive.reset()
+	unlock, err := statefile.Lock(path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err := archive.load(); err != nil {
+		return nil, err
+	}
+	// после многих перезаписей одних и тех же отзывов файл сжимается до последних версий
+	if archive.lines > 2*len(archive.records) && archive.lines > 1000 {
+		if err := archive.compact(); err != nil {
+			return nil, err
+		}
+	}
+	return archive, nil
+}
+
+func (archive *Archive) reset() {
+	archive.records = make(map[string]Record)
+	archive.byOffer = make(map[string]map[string]struct{})
+	archive.bySKU = make(map[string]map[string]struct{})
+	archive.byLabel = make(map[Label]map[string]struct{})
+	archive.tokens = make(map[string]map[string]struct{})
+	archive.byDate = nil
+	archive.lines = 0
+	archive.file = nil
+	archive.size = 0
+}
+
+// load дочитывает строки, добавленные в файл после последнего чтения. Если файл подменен,
+// например сжат другим процессом, он читается заново. Вызывается под блокировкой файла
+func (archive *Archive) load() error {
+	file, err := os.Open(archive.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось открыть архив отзывов %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("не удалось открыть архив отзывов %w", err)
+	}
+	full := archive.file == nil || !os.SameFile(archive.file, info) || info.Size() < archive.size
+	if full {
+		archive.reset()
+	}
+	if _, err := file.Seek(archive.size, io.SeekStart); err != nil {
+		return fmt.Errorf("ошибка чтения архива отзывов %w", err)
+	}
+	scanner := bufio.NewScanner(io.LimitReader(file, info.Size()-archive.size))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("не удалось распарсить строку %d архива отзывов %w", archive.lines+1, err)
+		}
+		archive.lines++
+		if full {
+			// при полном чтении индекс дат сортируется один раз в конце
+			archive.records[record.Review.ID] = record
+			continue
+		}
+		archive.index(record)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("ошибка чтения архива отзывов %w", err)
+	}
+	if full {
+		archive.byDate = make([]dated, 0, len(archive.records))
+		for id, record := range archive.records {
+			archive.addToIndexes(record)
+			archive.byDate = append(archive.byDate, dated{at: record.Review.PublishedTime(), id: id})
+		}
+		sort.Slice(archive.byDate, func(i, j int) bool { return archive.byDate[i].before(archive.byDate[j]) })
+	}
+	archive.file, archive.size = info, info.Size()
+	return nil
+}
+
+// Put сохраняет записи и обновляет индексы
+func (archive *Archive) Put(records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	archive.mu.Lock()
+	defer archive.mu.Unlock()
+	unlock, err := statefile.Lock(archive.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := archive.load(); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(archive.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть архив отзывов для записи %w", err)
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	now := time.Now()
+	for _, record := range records {
+		if record.ArchivedAt.IsZero() {
+			record.ArchivedAt = now
+		}
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("ошибка записи в архив отзывов %w", err)
+		}
+		archive.lines++
+		archive.index(record)
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("ошибка записи в архив отзывов %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("ошибка записи в архив отзывов %w", err)
+	}
+	archive.file, archive.size = info, info.Size()
+	return nil
+}
+
+// Get запись отзыва по идентификатору
+func (archive *Archive) Get(reviewID string) (Record, bool) {
+	archive.mu.RLock()
+	defer archive.mu.RUnlock()
+	record, ok := archive.records[reviewID]
+	return record, ok
+}
+
+// Len количество отзывов в архиве
+func (archive *Archive) Len() int {
+	archive.mu.RLock()
+	defer archive.mu.RUnlock()
+	return len(archive.records)
+}
+
+// index добавляет запись в индексы, убирая из них предыдущую версию отзыва
+func (archive *Archive) index(record Record) {
+	id := record.Review.ID
+	if previous, ok := archive.records[id]; ok {
+		archive.unindex(previous)
+	}
+	archive.records[id] = record
+	archive.addToIndexes(record)
+	d := dated{at: record.Review.PublishedTime(), id: id}
+	i := archive.datePosition(d)
+	archive.byDate = append(archive.byDate, dated{})
+	copy(archive.byDate[i+1:], archive.byDate[i:])
+	archive.byDate[i] = d
+}
+
+// addToIndexes добавляет запись во все индексы, кроме индекса дат
+func (archive *Archive) addToIndexes(record Record) {
+	id := record.Review.ID
+	add(archive.byOffer, record.Review.Product.OfferID, id)
+	add(archive.bySKU, record.Review.SKU, id)
+	add(archive.byLabel, record.Label, id)
+	for _, token := range recordTokens(record) {
+		add(archive.tokens, token, id)
+	}
+}
+
+func (archive *Archive) unindex(record Record) {
+	id := record.Review.ID
+	remove(archive.byOffer, record.Review.Product.OfferID, id)
+	remove(archive.bySKU, record.Review.SKU, id)
+	remove(archive.byLabel, record.Label, id)
+	for _, token := range recordTokens(record) {
+		remove(archive.tokens, token, id)
+	}
+	d := dated{at: record.Review.PublishedTime(), id: id}
+	if i := archive.datePosition(d); i < len(archive.byDate) && archive.byDate[i] == d {
+		archive.byDate = append(archive.byDate[:i], archive.byDate[i+1:]...)
+	}
+}
+
+// datePosition позиция d в индексе дат: индекс самого d или место, куда его нужно вставить
+func (archive *Archive) datePosition(d dated) int {
+	return sort.Search(len(archive.byDate), func(i int) bool { return !archive.byDate[i].before(d) })
+}
+
+// compact перезаписывает файл, оставляя только последние версии отзывов. Вызывается под блокировкой файла
+func (archive *Archive) compact() error {
+	tmp := archive.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("не удалось сжать архив отзывов %w", err)
+	}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, d := range archive.byDate {
+		if err := encoder.Encode(archive.records[d.id]); err != nil {
+			file.Close()
+			return fmt.Errorf("не удалось сжать архив отзывов %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("не удалось сжать архив отзывов %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("не удалось сжать архив отзывов %w", err)
+	}
+	if err := os.Rename(tmp, archive.path); err != nil {
+		return fmt.Errorf("не удалось сжать архив отзывов %w", err)
+	}
+	info, err := os.Stat(archive.path)
+	if err != nil {
+		return fmt.Errorf("не удалось сжать архив отзывов %w", err)
+	}
+	archive.lines = len(archive.records)
+	archive.file, archive.size = info, info.Size()
+	return nil
+}
+
+func add[K comparable](index map[K]map[string]struct{}, key K, id string) {
+	ids, ok := index[key]
+	if !ok {
+		ids = make(map[string]struct{})
+		index[key] = ids
+	}
+	ids[id] = struct{}{}
+}
+
+func remove[K comparable](index map[K]map[string]struct{}, key K, id string) {
+	if ids, ok := index[key]; ok {
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(index, key)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package archive
+
+import (
+	"PriceGuardian/ozon"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func record(id, publishedAt string, label Label) Record {
+	return Record{Review: ozon.Review{ID: id, PublishedAt: publishedAt, Product: ozon.Product{OfferID: "offer-" + id}}, Label: label}
+}
+
+func ids(records []Record) []string {
+	res := make([]string, 0, len(records))
+	for _, record := range records {
+		res = append(res, record.Review.ID)
+	}
+	return res
+}
+
+func TestPutSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		puts  [][]Record
+		query Query
+		want  []string
+	}{
+		{
+			name: "от новых к старым",
+			puts: [][]Record{{
+				record("a", "2024-05-01T10:00:00Z", LabelNegative),
+				record("c", "2024-05-03T10:00:00Z", LabelPositive),
+				record("b", "2024-05-02T10:00:00Z", LabelNegative),
+			}},
+			want: []string{"c", "b", "a"},
+		},
+		{
+			name: "перезапись отзыва меняет его место и метку",
+			puts: [][]Record{
+				{record("a", "2024-05-01T10:00:00Z", LabelNegative), record("b", "2024-05-02T10:00:00Z", LabelNegative)},
+				{record("a", "2024-05-03T10:00:00Z", LabelPositive)},
+			},
+			query: Query{Label: LabelNegative},
+			want:  []string{"b"},
+		},
+		{
+			name: "одинаковое время публикации",
+			puts: [][]Record{
+				{record("b", "2024-05-01T10:00:00Z", LabelNegative)},
+				{record("a", "2024-05-01T10:00:00Z", LabelNegative), record("b", "2024-05-01T10:00:00Z", LabelPositive)},
+			},
+			want: []string{"b", "a"},
+		},
+		{
+			name: "интервал публикации",
+			puts: [][]Record{{
+				record("a", "2024-05-01T10:00:00Z", LabelNegative),
+				record("b", "2024-05-02T10:00:00Z", LabelNegative),
+				record("c", "2024-05-03T10:00:00Z", LabelNegative),
+			}},
+			query: Query{From: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)},
+			want:  []string{"b"},
+		},
+		{
+			name:  "интервал сохранения в архив",
+			puts:  [][]Record{{record("a", "2024-05-01T10:00:00Z", LabelNegative)}},
+			query: Query{ArchivedFrom: time.Now().Add(-time.Hour), ArchivedTo: time.Now().Add(time.Hour)},
+			want:  []string{"a"},
+		},
+		{
+			name:  "сохранен вне интервала",
+			puts:  [][]Record{{record("a", "2024-05-01T10:00:00Z", LabelNegative)}},
+			query: Query{ArchivedTo: time.Now().Add(-time.Hour)},
+			want:  []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "reviews.jsonl")
+			archive, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, records := range tt.puts {
+				if err := archive.Put(records); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := ids(archive.Search(tt.query)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("получено %v, ожидалось %v", got, tt.want)
+			}
+			// после повторного открытия индексы те же
+			reopened, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(reopened.Search(tt.query)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("после открытия получено %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPutReadsOtherWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reviews.jsonl")
+	daemon, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := daemon.Put([]Record{record("a", "2024-05-01T10:00:00Z", LabelNegative)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.Put([]Record{record("b", "2024-05-02T10:00:00Z", LabelNegative)}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(cli.Search(Query{})), []string{"b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("получено %v, ожидалось %v", got, want)
+	}
+	if err := daemon.Put([]Record{record("a", "2024-05-01T10:00:00Z", LabelPositive)}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(daemon.Search(Query{Label: LabelNegative})), []string{"b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("получено %v, ожидалось %v", got, want)
+	}
+}
@@ -0,0 +1,153 @@
+package archive
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Query параметры поиска. Пустые поля не ограничивают результат
+type Query struct {
+	// Text полнотекстовый запрос: все слова должны встречаться в отзыве, слово с * на конце
+	// ищется как префикс, слово с - в начале исключает отзывы, в которых оно встречается
+	Text    string
+	OfferID string
+	SKU     string
+	Label   Label
+	From    time.Time
+	To      time.Time
+	// ArchivedFrom и ArchivedTo интервал времени сохранения в архив. Отзыв может быть загружен
+	// намного позже публикации, поэтому сводки за день выбирают отзывы по этому времени
+	ArchivedFrom time.Time
+	ArchivedTo   time.Time
+	// Limit максимальное количество результатов, 0 без ограничения
+	Limit int
+}
+
+// Search возвращает записи, подходящие под запрос, от новых к старым
+func (archive *Archive) Search(query Query) []Record {
+	archive.mu.RLock()
+	defer archive.mu.RUnlock()
+	var sets []map[string]struct{}
+	if query.OfferID != "" {
+		sets = append(sets, archive.byOffer[query.OfferID])
+	}
+	if query.SKU != "" {
+		sets = append(sets, archive.bySKU[query.SKU])
+	}
+	if query.Label != "" {
+		sets = append(sets, archive.byLabel[query.Label])
+	}
+	var excluded []map[string]struct{}
+	for _, word := range strings.Fields(query.Text) {
+		if negated, ok := strings.CutPrefix(word, "-"); ok {
+			for _, token := range tokenize(negated) {
+				excluded = append(excluded, archive.tokens[token])
+			}
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(word, "*"); ok {
+			sets = append(sets, archive.prefixIDs(normalize(prefix)))
+			continue
+		}
+		for _, token := range tokenize(word) {
+			sets = append(sets, archive.tokens[token])
+		}
+	}
+	// обход по индексу дат от новых к старым сразу дает нужный порядок и учитывает интервал
+	var res []Record
+	for i := len(archive.byDate) - 1; i >= 0; i-- {
+		d := archive.byDate[i]
+		if !query.To.IsZero() && !d.at.Before(query.To) {
+			continue
+		}
+		if !query.From.IsZero() && d.at.Before(query.From) {
+			break
+		}
+		if !inAll(sets, d.id) || inAny(excluded, d.id) {
+			continue
+		}
+		record := archive.records[d.id]
+		if !query.ArchivedTo.IsZero() && !record.ArchivedAt.Before(query.ArchivedTo) ||
+			!query.ArchivedFrom.IsZero() && record.ArchivedAt.Before(query.ArchivedFrom) {
+			continue
+		}
+		res = append(res, record)
+		if query.Limit > 0 && len(res) >= query.Limit {
+			break
+		}
+	}
+	return res
+}
+
+// Offers идентификаторы товаров, по которым есть отзывы, в алфавитном порядке
+func (archive *Archive) Offers() []string {
+	archive.mu.RLock()
+	defer archive.mu.RUnlock()
+	res := make([]string, 0, len(archive.byOffer))
+	for offerID := range archive.byOffer {
+		res = append(res, offerID)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (archive *Archive) prefixIDs(prefix string) map[string]struct{} {
+	res := make(map[string]struct{})
+	for token, ids := range archive.tokens {
+		if strings.HasPrefix(token, prefix) {
+			for id := range ids {
+				res[id] = struct{}{}
+			}
+		}
+	}
+	return res
+}
+
+func inAll(sets []map[string]struct{}, id string) bool {
+	for _, set := range sets {
+		if _, ok := set[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func inAny(sets []map[string]struct{}, id string) bool {
+	for _, set := range sets {
+		if _, ok := set[id]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// recordTokens слова текста отзыва и названия товара без повторов
+func recordTokens(record Record) []string {
+	review := record.Review
+	text := strings.Join([]string{
+		review.Text.Positive, review.Text.Negative, review.Text.Comment, review.Product.Title,
+	}, " ")
+	seen := make(map[string]bool)
+	var res []string
+	for _, token := range tokenize(text) {
+		if !seen[token] {
+			seen[token] = true
+			res = append(res, token)
+		}
+	}
+	return res
+}
+
+// tokenize разбивает текст на слова в нижнем регистре
+func tokenize(text string) []string {
+	return strings.FieldsFunc(normalize(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// normalize приводит текст к нижнему регистру и заменяет ё на е, т.к. в отзывах ее пишут непоследовательно
+func normalize(text string) string {
+	return strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+}
@@ -0,0 +1,938 @@
+package main
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/approval"
+	"PriceGuardian/archive"
+	"PriceGuardian/export"
+	"PriceGuardian/marketplace"
+	"PriceGuardian/notify"
+	"PriceGuardian/ozon"
+	"PriceGuardian/params"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `использование: PriceGuardian <команда> [флаги]
+
+команды:
+  run                         один проход по новым отзывам (по умолчанию)
+  serve                       опрос по расписанию и HTTP API
+  fetch-reviews               показать новые отзывы, не сохраняя время последнего отзыва
+  classify "<текст>"          классифицировать текст отзыва
+  quarantine <offer_id...>    поместить товары на карантин
+  release <offer_id...>       снять товары с карантина
+  approvals                   товары, ожидающие подтверждения карантина
+  approve <offer_id...>       подтвердить карантин товаров из очереди
+  reject <offer_id...>        отклонить карантин товаров из очереди
+  links sync|show|add|remove  связи одинаковых товаров разных кабинетов по offer_id, штрихкоду или вручную
+  status                      время последнего отзыва и товары на карантине
+  backfill -since <время>     сохранить локально отзывы за интервал [-until <время>]
+  replay                      повторно классифицировать сохраненные отзывы, не меняя цены
+  search "<запрос>"           полнотекстовый поиск по архиву обработанных отзывов
+  export -format csv|jsonl|xlsx  выгрузить отзывы из архива с решениями и ценами
+  digest [-day <дата>] [-send]   сводка отрицательных отзывов по товарам за день
+  recover                     вернуть цены из журнала и снять товары с истекшим карантином
+  snapshot                    сохранить снимок цен каталога
+  diff <до> <после>           сравнить два снимка цен
+
+общие флаги: -account <имя кабинета>, -format table|json
+`
+
+func runCommand(name string, cmdArgs []string) error {
+	switch name {
+	case "run":
+		return runCmd(cmdArgs)
+	case "serve":
+		return serveCommand()
+	case "fetch-reviews":
+		return fetchReviewsCommand(cmdArgs)
+	case "classify":
+		return classifyCommand(cmdArgs)
+	case "quarantine":
+		return quarantineCommand(cmdArgs)
+	case "release":
+		return releaseCommand(cmdArgs)
+	case "approvals":
+		return approvalsCommand(cmdArgs)
+	case "approve":
+		return approveCommand(cmdArgs)
+	case "reject":
+		return rejectCommand(cmdArgs)
+	case "links":
+		return linksCommand(cmdArgs)
+	case "status":
+		return statusCommand(cmdArgs)
+	case "backfill":
+		return backfillCommand(cmdArgs)
+	case "replay":
+		return replayCommand(cmdArgs)
+	case "search":
+		return searchCommand(cmdArgs)
+	case "export":
+		return exportCommand(cmdArgs)
+	case "digest":
+		return digestCommand(cmdArgs)
+	case "recover":
+		return recoverCommand(cmdArgs)
+	case "snapshot":
+		return snapshotCommand(cmdArgs)
+	case "diff":
+		return diffCommand(cmdArgs)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return apperrors.New(apperrors.Config, "неизвестная команда %s", name)
+	}
+}
+
+// commandFlags флаги, общие для всех команд
+type commandFlags struct {
+	*flag.FlagSet
+	account string
+	format  string
+}
+
+func newCommandFlags(name string) *commandFlags {
+	fs := &commandFlags{FlagSet: flag.NewFlagSet(name, flag.ExitOnError)}
+	fs.StringVar(&fs.account, "account", "", "кабинет, по умолчанию первый")
+	fs.StringVar(&fs.format, "format", "table", "формат вывода: table или json")
+	return fs
+}
+
+func (fs *commandFlags) parse(cmdArgs []string) error {
+	fs.Parse(cmdArgs)
+	if fs.format != "table" && fs.format != "json" {
+		return apperrors.New(apperrors.Config, "неизвестный формат %s", fs.format)
+	}
+	return nil
+}
+
+// printResult выводит v как JSON или как таблицу с заголовком header и строками rows
+func printResult(w io.Writer, format string, v interface{}, header []string, rows [][]string) error {
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// parseTime разбирает время в формате RFC3339 или дату 2006-01-02
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return time.Time{}, apperrors.New(apperrors.Config, "неверный формат времени %q, ожидается RFC3339 или ГГГГ-ММ-ДД", value)
+	}
+	return t, nil
+}
+
+// runCmd один проход по всем кабинетам или по выбранному
+func runCmd(cmdArgs []string) error {
+	fs := newCommandFlags("run")
+	if err := fs.parse(cmdArgs); err != nil {
+		return err
+	}
+	return runOnce(fs.account)
+}
+
+// reviewRows строки таблицы отзывов
+func reviewRows(reviews []ozon.Review) [][]string {
+	rows := make([][]string, 0, len(reviews))
+	for _, review := range reviews {
+		rows = append(rows, []string{
+			review.ID, review.PublishedAt, review.Product.OfferID, strconv.Itoa(review.Rating), review.Text.String(),
+		})
+	}
+	return rows
+}
+
+// fetchReviewsCommand выводит отзывы, опубликованные после времени последнего обработанного отзыва
+func fetchReviewsCommand(cmdArgs []string) error {
+	fs := newCommandFlags("fetch-reviews")
+	since := fs.String("since", "", "время начала, по умолчанию время последнего обработанного отзыва")
+	if err := fs.parse(cmdArgs); err != nil {
+		return err
+	}
+	cfg, err := loadAccount(fs.account)
+	if err != nil {
+		return err
+	}
+	var start time.Time
+	if *since != "" {
+		start, err = parseTime(*since)
+	} else {
+		start, err = readWatermark(cfg.Ozon.WatermarkPath)
+	}
+	if err != nil {
+		return err
+	}
+	api, err := marketplace.New(cfg)
+	if err != nil {
+		return err
+	}
+	fetched, _, err := api.GetReviewsTillTime(context.Background(), start)
+	if err != nil {
+		return err
+	}
+	reviews := make([]ozon.Review, 0, len(fetched))
+	for _, review := range fetched {
+		reviews = append(reviews, *review)
+	}
+	return printResult(os.Stdout, fs.format, reviews,
+		[]string{"ID", "PUBLISHED_AT", "OFFER_ID", "RATING", "TEXT"}, reviewRows(reviews))
+}
+
+// classifyCommand классифицирует текст, переданный аргументом
+func classifyCommand(cmdArgs []string) error {
+	fs := newCommandFlags("classify")
+	if err := fs.parse(cmdArgs); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return apperrors.New(apperrors.Config, "использование: classify \"<текст отзыва>\"")
+	}
+	cfg, err := loadAccount(fs.account)
+	if err != nil {
+		return err
+	}
+	chat, err := NewChatClient(cfg.Gigachat)
+	if err != nil {
+		return err
+	}
+	defer chat.Close()
+	text := strings.Join(fs.Args(), " ")
+	verdict, err := chat.classify(context.Background(), text)
+	if err != nil {
+		return err
+	}
+	result := struct {
+		Text string `json:"text"`
+		Verdict
+	}{text, verdict}
+	return printResult(os.Stdout, fs.format, result,
+		[]string{"NEGATIVE", "ANSWER", "TEXT"}, [][]string{{strconv.FormatBool(verdict.Negative), verdict.Answer, text}})
+}
+
+// quarantineCommand ручное помещение товаров на карантин
+func quarantineCommand(cmdArgs []string) error {
+	fs := newCommandFlags("quarantine")
+	actionName := fs.String("action", "", "действие карантина: price, stock или archive, по умолчанию из настроек")
+	if err := fs.parse(cmdArgs); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return apperrors.New(apperrors.Config, "использование: quarantine [-action price|stock|archive] <offer_id...>")
+	}
+	cfg, err := loadAccount(fs.account)
+	if err != nil {
+		return err
+	}
+	request := offersRequest{Account: cfg.Account, OfferIDs: fs.Args(), Action: *actionName}
+	if handled, err := daemonRequest(context.Background(), cfg, "/quarantine", request); handled {
+		if err != nil {
+			return err
+		}
+		return printStoredQuarantines(fs.format, cfg)
+	} else if err != nil {
+		return err
+	}
+	policy, err := ozon.ParseQuarantinePolicy(
+		cfg.Quarantine.Action,
+		cfg.Quarantine.OfferActions,
+		cfg.Quarantine.CategoryActions,
+	)
+	if err != nil {
+		return err
+	}
+	byAction, err := manualActions(policy, fs.Args(), *actionName)
+	if err != nil {
+		return err
+	}
+	api, err := marketplace.New(cfg)
+	if err != nil {
+		return err
+	}
+	notifier, err := notify.New(cfg.Notify)
+	if err != nil {
+		return err
+	}
+	for action, ids := range byAction {
+		quarantined, err := api.Quarantine(context.Background(), ids, action, nil)
+		if len(quarantined) > 0 {
+			notifyCommand(notifier, notify.Event{Type: notify.EventQuarantined, Account: cfg.Account,
+				OfferIDs: quarantined, Details: "Ручной карантин, действие: " + string(action)})
+		}
+		if err != nil {
+			return err
+		}
+		if skipped := missing(ids, quarantined); len(skipped) > 0 {
+			slog.Info("товары пропущены: уже на карантине или не найдены на площадке", "offer_ids", skipped)
+		}
+	}
+	return printQuarantines(fs.format, api.Quarantines())
+}
+
+// manualActions распределяет товары ручного карантина по действиям: actionName, если задано,
+// иначе действие из настроек товара. У ручного карантина нет отзывов, поэтому настройки категорий жалоб не применяются
+func manualActions(policy ozon.QuarantinePolicy, offerIDs []string, actionName string) (map[ozon.QuarantineAction][]string, error) {
+	byAction := make(map[ozon.QuarantineAction][]string)
+	if actionName != "" {
+		action, err := ozon.ParseQuarantineAction(actionName)
+		if err != nil {
+			return nil, err
+		}
+		byAction[action] = offerIDs
+		return byAction, nil
+	}
+	for _, offerID := range offerIDs {
+		action := policy.ActionFor(offerID, nil)
+		byAction[action] = append(byAction[action], offerID)
+	}
+	return byAction, nil
+}
+
+// releaseCommand ручное снятие товаров с карантина
+func releaseCommand(cmdArgs []string) error {
+	fs := newCommandFlags("release")
+	if err := fs.parse(cmdArgs); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return apperrors.New(apperrors.Config, "использование: release <offer_id...>")
+	}
+	cfg, err := loadAccount(fs.account)
+	if err != nil {
+		return err
+	}
+	request := offersRequest{Account: cfg.Account, OfferIDs: fs.Args()}
+	if handled, err := daemonRequest(context.Background(), cfg, "/release", request); handled {
+		if err != nil {
+			return err
+		}
+		return printStoredQuarantines(fs.format, cfg)
+	} else if err != nil {
+		return err
+	}
+	api, err := marketplace.New(cfg)
+	if err != nil {
+		return err
+	}
+	notifier, err := notify.New(cfg.Notify)
+	if err != nil {
+		return err
+	}
+	released, err := api.Release(context.Background(), fs.Args())
+	if len(released) > 0 {
+		notifyCommand(notifier, notify.Event{Type: notify.EventReleased, Account: cfg.Account,
+			OfferIDs: released, Details: "Ручное снятие с карантина"})
+	}
+	if err != nil {
+		return err
+	}
+	if skipped := missing(fs.Args(), released); len(skipped) > 0 {
+		slog.Info("товары пропущены: не находятся на карантине", "offer_ids", skipped)
+	}
+	return printQuarantines(fs.format, api.Quarantines())
+}
+
+// printStoredQuarantines выводит товары на карантине из файла состояния кабинета, не подключаясь к площадке
+func printStoredQuarantines(format string, cfg params.Config) error {
+	store, err := ozon.LoadQuarantineStore(cfg.Quarantine.StatePath, cfg.Account)
+	if err != nil {
+		return err
+	}
+	return printQuarantines(format, store.All())
+}
+
+// notifyCommand отправляет уведомление о ручном действии, ошибка доставки только логируется
+func notifyCommand(notifier *notify.Router, event notify.Event) {
+	if err := notifier.Notify(context.Background(), event); err != nil {
+		slog.Warn("не удалось отправить уведомление", "event", event.Type, "error", err)
+	}
+}
+
+func printQuarantines(format string, records []ozon.QuarantineRecord) error {
+	rows := make([][]string, 0, len(records))
+	for _, record := range records {
+		rows = append(rows, []string{
+			record.OfferID, string(record.Action),
+			record.StartedAt.Format(time.DateTime), record.EndsAt.Format(time.DateTime),
+			strconv.Itoa(len(record.Reviews)),
+		})
+	}
+	return printResult(os.Stdout, format, records,
+		[]string{"OFFER_ID", "ACTION", "STARTED_AT", "ENDS_AT", "REVIEWS"}, rows)
+}
+
+// approvalsCommand выводит товары, ожидающие подтверждения карантина
+func approvalsCommand(cmdArgs []string) error {
+	fs := newCommandFlags("approvals")
+	if err := fs.parse(cmdArgs); err != nil {
+		return err
+	}
+	cfg, err := loadAccount(fs.account)
+	if err != nil {
+		return err
+	}
+	queue, err := loadApprovals(cfg)
+	if err != nil {
+		return err
+	}
+	return printApprovals(fs.format, queue.Pending())
+}
+
+// loadApprovals очередь подтверждения кабинета
+func loadApprovals(cfg params.Config) (*approval.Queue, error) {
+	if !cfg.Quarantine.Approval {
+		return nil, errApprovalDisabled
+	}
+	return approval.Load(cfg.Quarantine.ApprovalPath, cfg.Quarantine.ApprovalTTL)
+}
+
+func printApprovals(format string, entries []approval.Entry) error {
+	rows := make([][]string, 0, len(entries))
+	for _, entry := range entries {
+		rows = append(rows, []string{
+			entry.OfferID, string(entry.Action),
+			entry.CreatedAt.Format(time.DateTime), entry.ExpiresAt.Format(time.DateTime),
+			strconv.Itoa(len(entry.Reviews)),
+		})
+	}
+	return printResult(os.Stdout, format, entries,
+		[]string{"OFFER_ID", "ACTION", "CREATED_AT", "EXPIRES_AT", "REVIEWS"}, rows)
+}
+
+// approveCommand помещает на карантин товары из очереди подтверждения
+func approveCommand(cmdArgs []string) error {
+	fs := newCommandFlags("approve")
+	if err := fs.parse(cmdArgs); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return apperrors.New(apperrors.Config, "использование: approve <offer_id...>")
+	}
+	accounts, err := loadAccounts()
+	if err != nil {
+		return err
+	}
+	cfg, err := findAccount(accounts, fs.account)
+	if err != nil {
+		return err
+	}
+	request := offersRequest{Account: cfg.Account, OfferIDs: fs.Args()}
+	if handled, err := daemonRequest(context.Background(), cfg, "/approvals/approve", request); handled {
+		if err != nil {
+			return err
+		}
+		return printStoredQuarantines(fs.format, cfg)
+	} else if err != nil {
+		return err
+	}
+	linker, err := newLinker(accounts[0])
+	if err != nil {
+		return err
+	}
+	pipelines, err := newPipelines(accounts, linker != nil, cfg.Account)
+	if err != nil {
+		return err
+	}
+	defer closePipelines(pipelines)
+	var pipeline *Pipeline
+	for _, p := range pipelines {
+		if p.account == cfg.Account {
+			pipeline = p
+		}
+	}
+	summary, err := pipeline.Approve(context.Background(), fs.Args())
+	// товары, которые удалось поместить на карантин, распространяются и при частичной ошибке
+	for _, linked := range linker.propagate(context.Background(), pipeline, summary.Quarantined, pipelines) {
+		linked.log()
+	}
+	if err != nil {
+		return err
+	}
+	summary.log()
+	return printQuarantines(fs.format, pipeline.api.Quarantines())
+}
+
+// rejectCommand убирает товары из очереди подтверждения без карантина
+func rejectCommand(cmdArgs []string) error {
+	fs := newCommandFlags("reject")
+	if err := fs.parse(cmdArgs); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return apperrors.New(apperrors.Config, "использование: reject <offer_id...>")
+	}
+	cfg, err := loadAccount(fs.account)
+	if err != nil {
+		return err
+	}
+	request := offersRequest{Account: cfg.Account, OfferIDs: fs.Args()}
+	if handled, err := daemonRequest(context.Background(), cfg, "/approvals/reject", request); handled {
+		if err != nil {
+			return err
+		}
+		queue, err := loadApprovals(cfg)
+		if err != nil {
+			return err
+		}
+		return printApprovals(fs.format, queue.Pending())
+	} else if err != nil {
+		return err
+	}
+	pipeline, err := NewPipeline(cfg)
+	if err != nil {
+		return err
+	}
+	defer pipeline.chat.Close()
+	if err := pipeline.Reject(fs.Args()); err != nil {
+		return err
+	}
+	return printApprovals(fs.format, pipeline.approvals.Pending())
+}
+
+// accountStatus состояние кабинета для команды status
+type accountStatus struct {
+	Account    string                  `json:"account"`
+	Watermark  time.Time               `json:"watermark"`
+	Quarantine []ozon.QuarantineRecord `json:"quarantine"`
+}
+
+// statusCommand выводит время последнего обработанного отзыва и товары на карантине по кабинетам
+func statusCommand(cmdArgs []string) error {
+	fs := newCommandFlags("status")
+	if err := fs.parse(cmdArgs); err != nil {
+		return err
+	}
+	accounts, err := loadAccounts()
+	if err != nil {
+		return err
+	}
+	if fs.account != "" {
+		cfg, err := findAccount(accounts, fs.account)
+		if err != nil {
+			return err
+		}
+		accounts = []params.Config{cfg}
+	}
+	var statuses []accountStatus
+	var rows [][]string
+	for _, cfg := range accounts {
+		watermark, err := readWatermark(cfg.Ozon.WatermarkPath)
+		if err != nil {
+			return err
+		}
+		store, err := ozon.LoadQuarantineStore(cfg.Quarantine.StatePath, cfg.Account)
+		if err != nil {
+			return err
+		}
+		status := accountStatus{Account: cfg.Account, Watermark: watermark, Quarantine: store.All()}
+		statuses = append(statuses, status)
+		for _, record := range status.Quarantine {
+			rows = append(rows, []string{
+				status.Account, status.Watermark.Format(time.DateTime),
+				record.OfferID, string(record.Action), record.EndsAt.Format(time.DateTime),
+			})
+		}
+		if len(status.Quarantine) == 0 {
+			rows = append(rows, []string{status.Account, status.Watermark.Format(time.DateTime), "-", "-", "-"})
+		}
+	}
+	return printResult(os.Stdout, fs.format, statuses,
+		[]string{"ACCOUNT", "WATERMARK", "OFFER_ID", "ACTION", "ENDS_AT"}, rows)
+}
+
+// backfillPath файл выгрузки отзывов по умолчанию, отдельный для каждого кабинета
+func backfillPath(cfg params.Config) string {
+	if account := cfg.Account; account != "" {
+		return account + ".backfill.json"
+	}
+	return "backfill.json"
+}
+
+// backfillCommand сохраняет локально все отзывы за интервал, не меняя цены и время последнего отзыва.
+// Прерванная загрузка продолжается при повторном запуске с теми же границами
+func backfillCommand(cmdArgs []string) error {
+	fs := newCommandFlags("backfill")
+	since := fs.String("since", "", "начало интервала в формате RFC3339 или ГГГГ-ММ-ДД")
+	until := fs.String("until", "", "конец интервала, по умолчанию текущий момент")
+	store := fs.String("store", "", "файл выгрузки, по умолчанию backfill.json с префиксом кабинета")
+	if err := fs.parse(cmdArgs); err != nil {
+		return err
+	}
+	if *since == "" {
+		return apperrors.New(apperrors.Config, "использование: backfill -since <время> [-until <время>]")
+	}
+	from, err := parseTime(*since)
+	if err != nil {
+		return err
+	}
+	var to time.Time
+	if *until != "" {
+		if to, err = parseTime(*until); err != nil {
+			return err
+		}
+		if !to.After(from) {
+			return apperrors.New(apperrors.Config, "конец интервала должен быть позже начала")
+		}
+	}
+	cfg, err := loadAccount(fs.account)
+	if err != nil {
+		return err
+	}
+	if *store == "" {
+		*store = backfillPath(cfg)
+	}
+	api, err := marketplace.OzonApi(cfg)
+	if err != nil {
+		return err
+	}
+	backfill, err := ozon.LoadBackfill(*store)
+	if err != nil {
+		return err
+	}
+	if err := backfill.Fetch(context.Background(), api, from, to); err != nil {
+		return err
+	}
+	reviews := backfill.List()
+	return printResult(os.Stdout, fs.format, reviews,
+		[]string{"ID", "PUBLISHED_AT", "OFFER_ID", "RATING", "TEXT"}, reviewRows(reviews))
+}
+
+// replayCommand повторно классифицирует сохраненные командой backfill отзывы с другим промптом,
+// моделью или политикой карантина и показывает, какие товары попали бы на карантин. Цены не меняются
+func replayCommand(cmdArgs []string) error {
+	fs := newCommandFlags("replay")
+	store := fs.String("store", "", "файл выгрузки, по умолчанию backfill.json с префиксом кабинета")
+	prompt := fs.String("prompt", "", "промпт вместо настроенного")
+	promptFile := fs.String("prompt-file", "", "файл с промптом вместо настроенного")
+	model := fs.String("model", "", "модель вместо настроенной")
+	minNegative := fs.Int("min-negative", 0, "порог отрицательных отзывов вместо настроенного")
+	action := fs.String("action", "", "действие карантина по умолчанию вместо настроенного")
+	baseline := fs.String("baseline", "", "результат предыдущего прогона (replay -format json) для сравнения")
+	if err := fs.parse(cmdArgs); err != nil {
+		return err
+	}
+	cfg, err := loadAccount(fs.account)
+	if err != nil {
+		return err
+	}
+	// конфигурация копируется, чтобы переопределения не затронули настройки кабинета
+	replayCfg := cfg
+	if *promptFile != "" {
+		data, err := os.ReadFile(*promptFile)
+		if err != nil {
+			return apperrors.Wrap(apperrors.Config, err, "не удалось прочитать промпт")
+		}
+		*prompt = strings.TrimSpace(string(data))
+	}
+	if *prompt != "" {
+		replayCfg.Gigachat.Prompt = *prompt
+	}
+	if *model != "" {
+		replayCfg.Gigachat.Model = *model
+	}
+	if *action != "" {
+		replayCfg.Quarantine.Action = *action
+	}
+	if *minNegative > 0 {
+		replayCfg.Quarantine.MinNegativeReviews = *minNegative
+	}
+	if *store == "" {
+		*store = backfillPath(cfg)
+	}
+	backfill, err := ozon.LoadBackfill(*store)
+	if err != nil {
+		return err
+	}
+	if len(backfill.Reviews) == 0 {
+		return apperrors.New(apperrors.Config, "в %s нет отзывов, сначала выполните backfill", *store)
+	}
+	pipeline, err := NewPipeline(replayCfg)
+	if err != nil {
+		return err
+	}
+	defer pipeline.chat.Close()
+	result := pipeline.Replay(context.Background(), backfill.List())
+	if *baseline != "" {
+		previous, err := loadReplayResult(*baseline)
+		if err != nil {
+			return err
+		}
+		result.Compare(previous)
+	}
+	slog.Info("повторная классификация завершена", "model", result.Model, "reviews", result.Reviews,
+		"negative", result.Negative, "errors", result.Errors, "quarantined", result.Quarantined)
+	if result.Diff != nil {
+		slog.Info("сравнение с предыдущим прогоном", "changed_labels", result.Diff.ChangedLabels,
+			"added", result.Diff.Added, "removed", result.Diff.Removed)
+	}
+	rows := make([][]string, 0, len(result.Offers))
+	for _, offer := range result.Offers {
+		rows = append(rows, []string{
+			offer.OfferID, strconv.Itoa(offer.Reviews), strconv.Itoa(offer.Negative), string(offer.Action),
+		})
+	}
+	return printResult(os.Stdout, fs.format, result, []string{"OFFER_ID", "REVIEWS", "NEGATIVE", "ACTION"}, rows)
+}
+
+// searchCommand ищет отзывы в архиве по тексту и индексам
+func searchCommand(cmdArgs []string) error {
+	fs := newCommandFlags("search")
+	offerID := fs.String("offer", "", "offer_id товара")
+	sku := fs.String("sku", "", "SKU товара")
+	label := fs.String("label", "", "метка: negative, positive или error")
+	since := fs.String("since", "", "начало интервала в формате RFC3339 или ГГГГ-ММ-ДД")
+	until := fs.String("until", "", "конец интервала в формате RFC3339 или ГГГГ-ММ-ДД")
+	limit := fs.Int("limit", 50, "максимальное количество результатов, 0 без ограничения")
+	if err := fs.parse(cmdArgs); err != nil {
+		return err
+	}
+	query := archive.Query{
+		Text:    strings.Join(fs.Args(), " "),
+		OfferID: *offerID,
+		SKU:     *sku,
+		Label:   archive.Label(*label),
+		Limit:   *limit,
+	}
+	switch query.Label {
+	case "", archive.LabelNegative, archive.LabelPositive, archive.LabelError:
+	default:
+		return apperrors.New(apperrors.Config, "неизвестная метка %s", *label)
+	}
+	var err error
+	if *since != "" {
+		if query.From, err = parseTime(*since); err != nil {
+			return err
+		}
+	}
+	if *until != "" {
+		if query.To, err = parseTime(*until); err != nil {
+			return err
+		}
+	}
+	cfg, err := loadAccount(fs.account)
+	if err != nil {
+		return err
+	}
+	reviewArchive, err := archive.Open(cfg.Archive.Path)
+	if err != nil {
+		return err
+	}
+	records := reviewArchive.Search(query)
+	rows := make([][]string, 0, len(records))
+	for _, record := range records {
+		review := record.Review
+		rows = append(rows, []string{
+			review.PublishedAt, review.Product.OfferID, review.SKU, strconv.Itoa(review.Rating),
+			string(record.Label), string(record.Decision), review.Text.String(),
+		})
+	}
+	return printResult(os.Stdout, fs.format, records,
+		[]string{"PUBLISHED_AT", "OFFER_ID", "SKU", "RATING", "LABEL", "DECISION", "TEXT"}, rows)
+}
+
+// exportCommand выгружает отзывы из архива за интервал вместе с классификацией, решением и ценами
+func exportCommand(cmdArgs []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	account := fs.String("account", "", "кабинет, по умолчанию первый")
+	formatName := fs.String("format", "csv", "формат: csv, jsonl или xlsx")
+	since := fs.String("since", "", "начало интервала в формате RFC3339 или ГГГГ-ММ-ДД")
+	until := fs.String("until", "", "конец интервала в формате RFC3339 или ГГГГ-ММ-ДД")
+	label := fs.String("label", "", "только отзывы с меткой negative, positive или error")
+	out := fs.String("o", "", "файл выгрузки, по умолчанию стандартный вывод")
+	fs.Parse(cmdArgs)
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+	query := archive.Query{Label: archive.Label(*label)}
+	if *since != "" {
+		if query.From, err = parseTime(*since); err != nil {
+			return err
+		}
+	}
+	if *until != "" {
+		if query.To, err = parseTime(*until); err != nil {
+			return err
+		}
+	}
+	cfg, err := loadAccount(*account)
+	if err != nil {
+		return err
+	}
+	reviewArchive, err := archive.Open(cfg.Archive.Path)
+	if err != nil {
+		return err
+	}
+	records := reviewArchive.Search(query)
+	// в таблицах удобнее хронологический порядок
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("не удалось создать файл выгрузки %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := export.Write(w, format, records); err != nil {
+		return err
+	}
+	slog.Info("выгрузка завершена", "records", len(records), "format", format, "path", *out)
+	return nil
+}
+
+// digestCommand выводит сводку за день или отправляет ее в каналы уведомлений
+func digestCommand(cmdArgs []string) error {
+	fs := flag.NewFlagSet("digest", flag.ExitOnError)
+	account := fs.String("account", "", "кабинет, по умолчанию первый")
+	dayValue := fs.String("day", "", "день в формате ГГГГ-ММ-ДД, по умолчанию вчера")
+	format := fs.String("format", "markdown", "формат вывода: markdown или html")
+	send := fs.Bool("send", false, "отправить сводку в каналы уведомлений вместо вывода")
+	fs.Parse(cmdArgs)
+	day := time.Now().AddDate(0, 0, -1)
+	if *dayValue != "" {
+		var err error
+		if day, err = parseTime(*dayValue); err != nil {
+			return err
+		}
+	}
+	cfg, err := loadAccount(*account)
+	if err != nil {
+		return err
+	}
+	reviewArchive, err := archive.Open(cfg.Archive.Path)
+	if err != nil {
+		return err
+	}
+	report := dayDigest(reviewArchive, cfg.Account, day)
+	if *send {
+		notifier, err := notify.New(cfg.Notify)
+		if err != nil {
+			return err
+		}
+		return sendDigest(context.Background(), notifier, report)
+	}
+	switch *format {
+	case "markdown":
+		_, err = io.WriteString(os.Stdout, report.Markdown())
+	case "html":
+		var html string
+		if html, err = report.HTML(); err == nil {
+			_, err = io.WriteString(os.Stdout, html)
+		}
+	default:
+		return apperrors.New(apperrors.Config, "неизвестный формат %s", *format)
+	}
+	return err
+}
+
+// recoverCommand восстанавливает цены после прерванного прохода
+func recoverCommand(cmdArgs []string) error {
+	fs := newCommandFlags("recover")
+	if err := fs.parse(cmdArgs); err != nil {
+		return err
+	}
+	cfg, err := loadAccount(fs.account)
+	if err != nil {
+		return err
+	}
+	api, err := marketplace.New(cfg)
+	if err != nil {
+		return err
+	}
+	mismatches, err := api.Recover(context.Background())
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(mismatches))
+	for _, m := range mismatches {
+		rows = append(rows, []string{
+			m.OfferID, strconv.FormatFloat(m.Expected, 'f', 2, 64),
+			strconv.FormatFloat(m.Actual, 'f', 2, 64), strconv.FormatBool(m.Found),
+		})
+	}
+	return printResult(os.Stdout, fs.format, mismatches,
+		[]string{"OFFER_ID", "EXPECTED", "ACTUAL", "FOUND"}, rows)
+}
+
+// snapshotCommand сохраняет цены всего каталога в файл
+func snapshotCommand(cmdArgs []string) error {
+	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	out := fs.String("o", fmt.Sprintf("prices-%s.json", time.Now().Format("20060102-150405")), "файл для сохранения снимка")
+	account := fs.String("account", "", "кабинет, по умолчанию первый")
+	fs.Parse(cmdArgs)
+	cfg, err := loadAccount(*account)
+	if err != nil {
+		return err
+	}
+	api, err := marketplace.OzonApi(cfg)
+	if err != nil {
+		return err
+	}
+	snapshot, err := api.TakePriceSnapshot(context.Background(), ozon.PriceFilter{})
+	if err != nil {
+		return err
+	}
+	if err := snapshot.Save(*out); err != nil {
+		return err
+	}
+	slog.Info("снимок цен сохранен", "items", len(snapshot.Items), "path", *out)
+	return nil
+}
+
+// diffCommand сравнивает два снимка цен
+func diffCommand(cmdArgs []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := fs.String("format", "json", "формат вывода: json или csv")
+	fs.Parse(cmdArgs)
+	if fs.NArg() != 2 {
+		return apperrors.New(apperrors.Config, "использование: diff [-format json|csv] <до> <после>")
+	}
+	before, err := ozon.LoadPriceSnapshot(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	after, err := ozon.LoadPriceSnapshot(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	diffs := ozon.DiffPriceSnapshots(before, after)
+	switch *format {
+	case "json":
+		err = ozon.WritePriceDiffJSON(os.Stdout, diffs)
+	case "csv":
+		err = ozon.WritePriceDiffCSV(os.Stdout, diffs)
+	default:
+		return apperrors.New(apperrors.Config, "неизвестный формат %s", *format)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка вывода %w", err)
+	}
+	return nil
+}
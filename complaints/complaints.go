@@ -0,0 +1,68 @@
+package complaints
+
+import (
+	"sort"
+	"strings"
+)
+
+// keywords категории жалоб и начала слов, по которым они определяются.
+// Классификатор возвращает только класс отзыва, поэтому категории определяются по тексту
+var keywords = map[string][]string{
+	"брак":                {"брак", "сломал", "слома", "не работ", "треснул", "дефект", "поломк"},
+	"размер":              {"размер", "маломер", "большемер", "не подош"},
+	"доставка и упаковка": {"доставк", "упаков", "курьер", "помят", "коробк"},
+	"не соответствует":    {"не соответств", "не такой", "другой цвет", "на фото", "описани"},
+	"качество":            {"качеств", "дешев", "хлипк", "тонк", "облез", "порвал"},
+	"запах":               {"запах", "воня", "пахнет"},
+	"подделка":            {"подделк", "фейк", "копия", "не оригинал"},
+	"некомплект":          {"некомплект", "не хватает", "нет в комплекте", "отсутству"},
+}
+
+// Other категория отрицательных отзывов, не подошедших ни под одну из известных
+const Other = "прочее"
+
+// Known известна ли категория, включая Other
+func Known(category string) bool {
+	_, ok := keywords[category]
+	return ok || category == Other
+}
+
+// Categories категории жалоб, упомянутые в тексте отзыва, по алфавиту
+func Categories(text string) []string {
+	text = strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+	var res []string
+	for category, words := range keywords {
+		for _, word := range words {
+			if strings.Contains(text, word) {
+				res = append(res, category)
+				break
+			}
+		}
+	}
+	if len(res) == 0 {
+		return []string{Other}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Rank категории жалоб в текстах от самой частой к самой редкой, при равенстве по алфавиту
+func Rank(texts []string) []string {
+	counts := make(map[string]int)
+	for _, text := range texts {
+		for _, category := range Categories(text) {
+			counts[category]++
+		}
+	}
+	res := make([]string, 0, len(counts))
+	for category := range counts {
+		res = append(res, category)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if counts[res[i]] != counts[res[j]] {
+			return counts[res[i]] > counts[res[j]]
+		}
+		return res[i] < res[j]
+	})
+	return res
+}
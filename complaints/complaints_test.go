@@ -0,0 +1,44 @@
+package complaints
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCategories(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"без совпадений", "просто не понравилось", []string{Other}},
+		{"регистр и ё", "Пришёл БРАК, коробка помята", []string{"брак", "доставка и упаковка"}},
+		{"одна категория", "маломерит на размер", []string{"размер"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Categories(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("получено %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	tests := []struct {
+		name  string
+		texts []string
+		want  []string
+	}{
+		{"пусто", nil, []string{}},
+		{"по частоте", []string{"подделка", "брак", "подделка, копия"}, []string{"подделка", "брак"}},
+		{"при равенстве по алфавиту", []string{"запах", "брак"}, []string{"брак", "запах"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Rank(tt.texts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("получено %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
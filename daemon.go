@@ -0,0 +1,287 @@
+package main
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/notify"
+	"PriceGuardian/ozon"
+	"PriceGuardian/params"
+	"PriceGuardian/statefile"
+	"context"
+	"fmt"
+	"log/slog"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxRunSummaries сколько последних проходов хранится для API
+const maxRunSummaries = 50
+
+// Daemon периодически опрашивает отзывы всех кабинетов и снимает товары с карантина по расписанию
+type Daemon struct {
+	// pipelines по одному на кабинет, первый используется по умолчанию
+	pipelines []*Pipeline
+	// linker распространяет карантин на связанные товары других кабинетов, nil если выключено
+	linker   *Linker
+	interval time.Duration
+	pollNow  chan struct{}
+	// digestAt время отправки сводки за прошедший день, отрицательное, если сводка отключена
+	digestAt time.Duration
+	// digestStatePath файл, в котором сохраняется lastDigest, чтобы перезапуск не повторял
+	// и не пропускал сводку
+	digestStatePath string
+	// lastDigest день последней отправленной сводки по кабинетам
+	lastDigest map[string]string
+
+	// mu не дает ручным операциям выполняться одновременно с проходом
+	mu     sync.Mutex
+	runsMu sync.Mutex
+	runs   []RunSummary
+}
+
+// NewDaemon создает демон для кабинетов accounts. Интервал опроса берется из первого кабинета
+func NewDaemon(accounts []params.Config) (*Daemon, error) {
+	daemon := &Daemon{
+		interval: accounts[0].Schedule.PollInterval,
+		pollNow:  make(chan struct{}, 1),
+		digestAt: -1,
+	}
+	if value := accounts[0].Digest.Time; value != "" {
+		at, err := time.Parse("15:04", value)
+		if err != nil {
+			return nil, apperrors.Wrap(apperrors.Config, err, "неверное время сводки")
+		}
+		daemon.digestAt = time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute
+		daemon.digestStatePath = accounts[0].Digest.StatePath
+		if _, err := statefile.Read(daemon.digestStatePath, &daemon.lastDigest); err != nil {
+			return nil, fmt.Errorf("не удалось прочитать день последней сводки %w", err)
+		}
+	}
+	if daemon.lastDigest == nil {
+		daemon.lastDigest = make(map[string]string)
+	}
+	var err error
+	if daemon.linker, err = newLinker(accounts[0]); err != nil {
+		return nil, err
+	}
+	if daemon.pipelines, err = newPipelines(accounts, true, ""); err != nil {
+		return nil, err
+	}
+	return daemon, nil
+}
+
+// Close закрывает соединения с GigaChat всех кабинетов
+func (d *Daemon) Close() {
+	closePipelines(d.pipelines)
+}
+
+// pipeline обработчик кабинета с именем account или первого кабинета, если имя пустое
+func (d *Daemon) pipeline(account string) (*Pipeline, error) {
+	if account == "" {
+		return d.pipelines[0], nil
+	}
+	for _, pipeline := range d.pipelines {
+		if pipeline.account == account {
+			return pipeline, nil
+		}
+	}
+	return nil, apperrors.New(apperrors.Validation, "кабинет %q не найден", account)
+}
+
+// PollNow запускает внеочередной опрос, не дожидаясь интервала
+func (d *Daemon) PollNow() {
+	select {
+	case d.pollNow <- struct{}{}:
+	default:
+	}
+}
+
+// Run опрашивает отзывы, пока не отменен ctx. Текущий проход всегда доводится до конца,
+// чтобы не оставить цены в промежуточном состоянии
+func (d *Daemon) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	// отмена ctx не прерывает текущий проход, а только не дает начаться следующему
+	pollCtx := context.WithoutCancel(ctx)
+	for {
+		d.poll(pollCtx)
+		select {
+		case <-ctx.Done():
+			slog.Info("получен сигнал завершения, останавливаюсь")
+			return
+		case <-ticker.C:
+		case <-d.pollNow:
+		}
+	}
+}
+
+func (d *Daemon) poll(ctx context.Context) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	slog.Info("начинаю опрос отзывов", "accounts", len(d.pipelines))
+	for _, pipeline := range d.pipelines {
+		summary := pipeline.Process(ctx)
+		summary.log()
+		d.addRun(summary)
+		d.propagate(ctx, pipeline, summary.Quarantined)
+	}
+	d.sendDigestIfDue(ctx)
+}
+
+// sendDigestIfDue отправляет сводку за вчерашний день кабинетам, которым она еще не отправлена,
+// если наступило время сводки. Сводка, которую не удалось отправить, повторяется при следующем опросе
+func (d *Daemon) sendDigestIfDue(ctx context.Context) {
+	now := time.Now()
+	yesterday := now.AddDate(0, 0, -1)
+	day := yesterday.Format(time.DateOnly)
+	if d.digestAt < 0 || sinceMidnight(now) < d.digestAt {
+		return
+	}
+	sent := false
+	for _, pipeline := range d.pipelines {
+		if d.lastDigest[pipeline.account] == day {
+			continue
+		}
+		if err := pipeline.SendDigest(ctx, yesterday); err != nil {
+			slog.Warn("не удалось отправить сводку", "account", pipeline.account, "error", err)
+			continue
+		}
+		d.lastDigest[pipeline.account] = day
+		sent = true
+	}
+	if !sent {
+		return
+	}
+	if err := statefile.Write(d.digestStatePath, d.lastDigest); err != nil {
+		slog.Error("не удалось сохранить день последней сводки", "path", d.digestStatePath, "error", err)
+	}
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+func (d *Daemon) addRun(summary RunSummary) {
+	d.runsMu.Lock()
+	defer d.runsMu.Unlock()
+	d.runs = append(d.runs, summary)
+	if len(d.runs) > maxRunSummaries {
+		d.runs = d.runs[len(d.runs)-maxRunSummaries:]
+	}
+}
+
+// Runs итоги последних проходов, начиная с самого нового
+func (d *Daemon) Runs() []RunSummary {
+	d.runsMu.Lock()
+	defer d.runsMu.Unlock()
+	res := make([]RunSummary, 0, len(d.runs))
+	for i := len(d.runs) - 1; i >= 0; i-- {
+		res = append(res, d.runs[i])
+	}
+	return res
+}
+
+// Quarantine ручное помещение товаров кабинета account на карантин. Возвращает offer_id товаров,
+// помещенных на карантин, остальные уже были на карантине или не найдены на площадке
+func (d *Daemon) Quarantine(ctx context.Context, account string, offerIDs []string, action ozon.QuarantineAction) ([]string, error) {
+	pipeline, err := d.pipeline(account)
+	if err != nil {
+		return nil, err
+	}
+	// отключение клиента не должно прерывать изменение цен на середине, иначе товары останутся
+	// с повышенной ценой или вне акций
+	ctx = context.WithoutCancel(ctx)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	quarantined, err := pipeline.api.Quarantine(ctx, offerIDs, action, nil)
+	if len(quarantined) > 0 {
+		pipeline.notify(ctx, slog.Default(), notify.Event{Type: notify.EventQuarantined, OfferIDs: quarantined,
+			Details: "Ручной карантин, действие: " + string(action)})
+	}
+	return quarantined, err
+}
+
+// Release ручное снятие товаров кабинета account с карантина. Возвращает offer_id снятых с карантина товаров,
+// остальные не были на карантине
+func (d *Daemon) Release(ctx context.Context, account string, offerIDs []string) ([]string, error) {
+	pipeline, err := d.pipeline(account)
+	if err != nil {
+		return nil, err
+	}
+	// отключение клиента не должно прерывать изменение цен на середине, иначе товары останутся
+	// с повышенной ценой или вне акций
+	ctx = context.WithoutCancel(ctx)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	released, err := pipeline.api.Release(ctx, offerIDs)
+	if len(released) > 0 {
+		pipeline.notify(ctx, slog.Default(), notify.Event{Type: notify.EventReleased, OfferIDs: released,
+			Details: "Ручное снятие с карантина"})
+	}
+	return released, err
+}
+
+// Approve помещает на карантин товары кабинета account, подтвержденные оператором
+func (d *Daemon) Approve(ctx context.Context, account string, offerIDs []string) ([]string, error) {
+	pipeline, err := d.pipeline(account)
+	if err != nil {
+		return nil, err
+	}
+	// как и при ручном карантине, подтверждение доводится до конца даже после отключения клиента
+	ctx = context.WithoutCancel(ctx)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	summary, err := pipeline.Approve(ctx, offerIDs)
+	if len(summary.Quarantined) > 0 || len(summary.Errors) > 0 {
+		summary.log()
+		d.addRun(summary)
+	}
+	d.propagate(ctx, pipeline, summary.Quarantined)
+	return summary.Quarantined, err
+}
+
+// propagate помещает на карантин связанные товары других кабинетов
+func (d *Daemon) propagate(ctx context.Context, source *Pipeline, offerIDs []string) {
+	for _, summary := range d.linker.propagate(ctx, source, offerIDs, d.pipelines) {
+		summary.log()
+		d.addRun(summary)
+	}
+}
+
+// Reject отклоняет карантин товаров кабинета account из очереди подтверждения
+func (d *Daemon) Reject(account string, offerIDs []string) error {
+	pipeline, err := d.pipeline(account)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return pipeline.Reject(offerIDs)
+}
+
+// serveCommand запускает долгоживущий режим с опросом по расписанию
+func serveCommand() error {
+	accounts, err := loadAccounts()
+	if err != nil {
+		return err
+	}
+	cfg := accounts[0]
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	daemon, err := NewDaemon(accounts)
+	if err != nil {
+		return err
+	}
+	defer daemon.Close()
+	if cfg.HTTP.Addr != "" {
+		server, err := NewServer(daemon, cfg.HTTP.Addr, cfg.HTTP.Token)
+		if err != nil {
+			return err
+		}
+		go server.ListenAndServe()
+		defer server.Shutdown(context.Background())
+	}
+	daemon.Run(ctx)
+	return nil
+}
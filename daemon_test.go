@@ -0,0 +1,79 @@
+package main
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/ozon"
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestDaemonPipeline(t *testing.T) {
+	first, second := newTestPipeline(t, "ozon"), newTestPipeline(t, "wb")
+	d := &Daemon{pipelines: []*Pipeline{first, second}}
+	tests := []struct {
+		name    string
+		account string
+		want    *Pipeline
+		wantErr bool
+	}{
+		{"по умолчанию первый кабинет", "", first, false},
+		{"кабинет по имени", "wb", second, false},
+		{"неизвестный кабинет", "market", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := d.pipeline(tt.account)
+			if tt.wantErr {
+				if apperrors.KindOf(err) != apperrors.Validation {
+					t.Fatalf("ожидалась ошибка валидации, получено %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("получен кабинет %q, ожидался %q", got.account, tt.want.account)
+			}
+		})
+	}
+}
+
+func TestDaemonRuns(t *testing.T) {
+	d := &Daemon{}
+	for i := 0; i < maxRunSummaries+5; i++ {
+		d.addRun(RunSummary{RunID: strconv.Itoa(i)})
+	}
+	runs := d.Runs()
+	if len(runs) != maxRunSummaries {
+		t.Fatalf("хранится %d проходов, ожидалось %d", len(runs), maxRunSummaries)
+	}
+	newest, oldest := strconv.Itoa(maxRunSummaries+4), "5"
+	if runs[0].RunID != newest || runs[len(runs)-1].RunID != oldest {
+		t.Errorf("проходы от %s до %s, ожидалось от %s до %s", runs[0].RunID, runs[len(runs)-1].RunID, newest, oldest)
+	}
+}
+
+func TestDaemonQuarantineAndRelease(t *testing.T) {
+	d := &Daemon{pipelines: []*Pipeline{newTestPipeline(t, "ozon"), newTestPipeline(t, "wb", "kettle", "cup")}}
+	ctx := context.Background()
+	quarantined, err := d.Quarantine(ctx, "wb", []string{"kettle", "missing"}, ozon.ActionPrice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"kettle"}; !reflect.DeepEqual(quarantined, want) {
+		t.Errorf("на карантине %v, ожидалось %v", quarantined, want)
+	}
+	released, err := d.Release(ctx, "wb", []string{"kettle", "cup"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"kettle"}; !reflect.DeepEqual(released, want) {
+		t.Errorf("сняты с карантина %v, ожидалось %v", released, want)
+	}
+	if _, err := d.Release(ctx, "market", []string{"kettle"}); apperrors.KindOf(err) != apperrors.Validation {
+		t.Errorf("получено %v, ожидалась ошибка валидации", err)
+	}
+}
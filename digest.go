@@ -0,0 +1,37 @@
+package main
+
+import (
+	"PriceGuardian/archive"
+	"PriceGuardian/digest"
+	"PriceGuardian/notify"
+	"context"
+	"time"
+)
+
+// dayDigest сводка по отзывам, сохраненным в архив в день day. Отзывы выбираются по времени загрузки,
+// а не публикации, чтобы опубликованные накануне, но загруженные позже отзывы не выпадали из сводок
+func dayDigest(reviewArchive *archive.Archive, account string, day time.Time) digest.Digest {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	records := reviewArchive.Search(archive.Query{ArchivedFrom: from, ArchivedTo: from.AddDate(0, 0, 1)})
+	return digest.Build(records, account, from)
+}
+
+// sendDigest отправляет сводку в каналы уведомлений, подписанные на daily_digest
+func sendDigest(ctx context.Context, notifier *notify.Router, report digest.Digest) error {
+	html, err := report.HTML()
+	if err != nil {
+		return err
+	}
+	return notifier.Notify(ctx, notify.Event{
+		Type:     notify.EventDailyDigest,
+		Account:  report.Account,
+		Details:  report.Title(),
+		Markdown: report.Markdown(),
+		HTML:     html,
+	})
+}
+
+// SendDigest отправляет сводку кабинета за день day
+func (p *Pipeline) SendDigest(ctx context.Context, day time.Time) error {
+	return sendDigest(ctx, p.notifier, dayDigest(p.archive, p.account, day))
+}
@@ -0,0 +1,149 @@
+package digest
+
+import (
+	"PriceGuardian/archive"
+	"PriceGuardian/complaints"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxQuotes сколько цитат из отрицательных отзывов показывать для товара
+const maxQuotes = 3
+
+// maxQuoteLength длина цитаты в символах
+const maxQuoteLength = 200
+
+// Count количество по ключу для упорядоченного вывода
+type Count struct {
+	Name  string
+	Count int
+}
+
+// Product отзывы о товаре за день
+type Product struct {
+	OfferID    string
+	Title      string
+	Brand      string
+	URL        string
+	Labels     map[archive.Label]int
+	Categories []Count
+	Quotes     []string
+	// Actions принятые решения, например "quarantined: price"
+	Actions []string
+}
+
+// Negative количество отрицательных отзывов
+func (product Product) Negative() int {
+	return product.Labels[archive.LabelNegative]
+}
+
+// Digest сводка отзывов за день
+type Digest struct {
+	Account       string
+	Day           time.Time
+	Total         int
+	Labels        map[archive.Label]int
+	TopCategories []Count
+	Products      []Product
+}
+
+// Build группирует записи архива по товарам. Товары упорядочены по числу отрицательных отзывов
+func Build(records []archive.Record, account string, day time.Time) Digest {
+	digest := Digest{Account: account, Day: day, Total: len(records), Labels: make(map[archive.Label]int)}
+	type productState struct {
+		product    Product
+		categories map[string]int
+		actions    map[string]bool
+	}
+	products := make(map[string]*productState)
+	allCategories := make(map[string]int)
+	for _, record := range records {
+		review := record.Review
+		state, ok := products[review.Product.OfferID]
+		if !ok {
+			state = &productState{
+				product: Product{
+					OfferID: review.Product.OfferID,
+					Labels:  make(map[archive.Label]int),
+				},
+				categories: make(map[string]int),
+				actions:    make(map[string]bool),
+			}
+			products[review.Product.OfferID] = state
+		}
+		// в разных отзывах карточка товара может быть заполнена по-разному
+		fillEmpty(&state.product.Title, review.Product.Title)
+		fillEmpty(&state.product.Brand, review.Product.BrandName)
+		fillEmpty(&state.product.URL, review.Product.URL)
+		digest.Labels[record.Label]++
+		state.product.Labels[record.Label]++
+		if record.Label != archive.LabelNegative {
+			continue
+		}
+		for _, category := range complaints.Categories(review.ComplaintText()) {
+			state.categories[category]++
+			allCategories[category]++
+		}
+		if quote := quoteOf(record); quote != "" && len(state.product.Quotes) < maxQuotes {
+			state.product.Quotes = append(state.product.Quotes, quote)
+		}
+		if record.Decision != archive.DecisionNone {
+			action := string(record.Decision)
+			if record.Action != "" {
+				action += ": " + string(record.Action)
+			}
+			if !state.actions[action] {
+				state.actions[action] = true
+				state.product.Actions = append(state.product.Actions, action)
+			}
+		}
+	}
+	for _, state := range products {
+		state.product.Categories = sortCounts(state.categories)
+		digest.Products = append(digest.Products, state.product)
+	}
+	sort.Slice(digest.Products, func(i, j int) bool {
+		a, b := digest.Products[i], digest.Products[j]
+		if a.Negative() != b.Negative() {
+			return a.Negative() > b.Negative()
+		}
+		return a.OfferID < b.OfferID
+	})
+	digest.TopCategories = sortCounts(allCategories)
+	return digest
+}
+
+// quoteOf наиболее показательная часть отрицательного отзыва
+func quoteOf(record archive.Record) string {
+	text := record.Review.Text
+	quote := strings.TrimSpace(text.Negative)
+	if quote == "" {
+		quote = strings.TrimSpace(text.Comment)
+	}
+	if utf8.RuneCountInString(quote) > maxQuoteLength {
+		quote = string([]rune(quote)[:maxQuoteLength]) + "…"
+	}
+	return quote
+}
+
+func fillEmpty(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+func sortCounts(counts map[string]int) []Count {
+	res := make([]Count, 0, len(counts))
+	for name, count := range counts {
+		res = append(res, Count{Name: name, Count: count})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
@@ -0,0 +1,104 @@
+package digest
+
+import (
+	"PriceGuardian/archive"
+	"PriceGuardian/ozon"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func record(offerID string, label archive.Label, negative string, decision archive.Decision, action ozon.QuarantineAction) archive.Record {
+	return archive.Record{
+		Review: ozon.Review{
+			Text:    ozon.ReviewText{Negative: negative},
+			Product: ozon.Product{OfferID: offerID, Title: "Товар " + offerID},
+		},
+		Label:    label,
+		Decision: decision,
+		Action:   action,
+	}
+}
+
+func testRecords() []archive.Record {
+	return []archive.Record{
+		record("kettle", archive.LabelNegative, "течет, брак", archive.DecisionQuarantined, ozon.ActionPrice),
+		record("kettle", archive.LabelNegative, "сломался через день", archive.DecisionQuarantined, ozon.ActionPrice),
+		record("kettle", archive.LabelPositive, "", archive.DecisionNone, ""),
+		record("cup", archive.LabelNegative, "пахнет краской", archive.DecisionBelowThreshold, ""),
+		record("spoon", archive.LabelPositive, "", archive.DecisionNone, ""),
+		record("spoon", archive.LabelError, "", archive.DecisionNone, ""),
+	}
+}
+
+func TestBuild(t *testing.T) {
+	digest := Build(testRecords(), "main", time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC))
+	if digest.Total != 6 {
+		t.Errorf("всего отзывов %d, ожидалось 6", digest.Total)
+	}
+	wantLabels := map[archive.Label]int{archive.LabelNegative: 3, archive.LabelPositive: 2, archive.LabelError: 1}
+	if !reflect.DeepEqual(digest.Labels, wantLabels) {
+		t.Errorf("метки %v, ожидалось %v", digest.Labels, wantLabels)
+	}
+	var order []string
+	for _, product := range digest.Products {
+		order = append(order, product.OfferID)
+	}
+	if want := []string{"kettle", "cup", "spoon"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("порядок товаров %v, ожидалось %v", order, want)
+	}
+	wantTop := []Count{{"брак", 2}, {"запах", 1}}
+	if !reflect.DeepEqual(digest.TopCategories, wantTop) {
+		t.Errorf("основные жалобы %v, ожидалось %v", digest.TopCategories, wantTop)
+	}
+	kettle := digest.Products[0]
+	if want := []string{"течет, брак", "сломался через день"}; !reflect.DeepEqual(kettle.Quotes, want) {
+		t.Errorf("цитаты %v, ожидалось %v", kettle.Quotes, want)
+	}
+	if want := []string{"quarantined: price"}; !reflect.DeepEqual(kettle.Actions, want) {
+		t.Errorf("действия %v, ожидалось %v", kettle.Actions, want)
+	}
+	if want := []string{"below_threshold"}; !reflect.DeepEqual(digest.Products[1].Actions, want) {
+		t.Errorf("действия cup %v, ожидалось %v", digest.Products[1].Actions, want)
+	}
+}
+
+func TestQuoteOf(t *testing.T) {
+	long := strings.Repeat("я", maxQuoteLength+10)
+	tests := []struct {
+		name string
+		text ozon.ReviewText
+		want string
+	}{
+		{"недостатки", ozon.ReviewText{Negative: " течет ", Comment: "вернул"}, "течет"},
+		{"комментарий без недостатков", ozon.ReviewText{Comment: "вернул"}, "вернул"},
+		{"длинная цитата обрезается", ozon.ReviewText{Negative: long}, strings.Repeat("я", maxQuoteLength) + "…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quoteOf(archive.Record{Review: ozon.Review{Text: tt.text}}); got != tt.want {
+				t.Errorf("получено %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	records := append(testRecords(), record("kettle", archive.LabelNegative, "<script>_x_</script>", archive.DecisionNone, ""))
+	digest := Build(records, "main", time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC))
+	markdown := digest.Markdown()
+	for _, want := range []string{"# Сводка отзывов за 03.05.2024 (main)", "- брак: 2", "offer_id: `kettle`",
+		"Действия: quarantined: price", `\_x\_`} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("в Markdown нет %q:\n%s", want, markdown)
+		}
+	}
+	html, err := digest.HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(html, "<script>") || !strings.Contains(html, "&lt;script&gt;") {
+		t.Errorf("цитата в HTML не экранирована:\n%s", html)
+	}
+}
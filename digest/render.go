@@ -0,0 +1,105 @@
+package digest
+
+import (
+	"PriceGuardian/archive"
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// Title заголовок сводки
+func (digest Digest) Title() string {
+	title := "Сводка отзывов за " + digest.Day.Format("02.01.2006")
+	if digest.Account != "" {
+		title += " (" + digest.Account + ")"
+	}
+	return title
+}
+
+// Markdown сводка в Markdown
+func (digest Digest) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", digest.Title())
+	fmt.Fprintf(&b, "Всего отзывов: %d, отрицательных: %d, положительных: %d, ошибок классификации: %d\n\n",
+		digest.Total, digest.Labels[archive.LabelNegative], digest.Labels[archive.LabelPositive], digest.Labels[archive.LabelError])
+	if len(digest.TopCategories) > 0 {
+		b.WriteString("## Основные жалобы\n\n")
+		for _, category := range digest.TopCategories {
+			fmt.Fprintf(&b, "- %s: %d\n", category.Name, category.Count)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("## Товары\n\n")
+	for _, product := range digest.Products {
+		title := markdownEscape(product.Title)
+		if title == "" {
+			title = product.OfferID
+		}
+		if product.URL != "" {
+			title = "[" + title + "](" + product.URL + ")"
+		}
+		fmt.Fprintf(&b, "### %s\n\n", title)
+		fmt.Fprintf(&b, "offer_id: `%s`", product.OfferID)
+		if product.Brand != "" {
+			fmt.Fprintf(&b, ", бренд: %s", markdownEscape(product.Brand))
+		}
+		fmt.Fprintf(&b, "\n\nОтрицательных: %d, положительных: %d, ошибок: %d\n\n",
+			product.Negative(), product.Labels[archive.LabelPositive], product.Labels[archive.LabelError])
+		if len(product.Categories) > 0 {
+			names := make([]string, 0, len(product.Categories))
+			for _, category := range product.Categories {
+				names = append(names, fmt.Sprintf("%s (%d)", category.Name, category.Count))
+			}
+			fmt.Fprintf(&b, "Жалобы: %s\n\n", strings.Join(names, ", "))
+		}
+		for _, quote := range product.Quotes {
+			fmt.Fprintf(&b, "> %s\n\n", strings.ReplaceAll(markdownEscape(quote), "\n", " "))
+		}
+		if len(product.Actions) > 0 {
+			fmt.Fprintf(&b, "Действия: %s\n\n", strings.Join(product.Actions, ", "))
+		}
+	}
+	return b.String()
+}
+
+func markdownEscape(text string) string {
+	return strings.NewReplacer("*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`).Replace(text)
+}
+
+var htmlTemplate = template.Must(template.New("digest").Funcs(template.FuncMap{
+	"label": func(labels map[archive.Label]int, label string) int { return labels[archive.Label(label)] },
+}).Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Всего отзывов: {{.Total}}, отрицательных: {{label .Labels "negative"}}, положительных: {{label .Labels "positive"}}, ошибок классификации: {{label .Labels "error"}}</p>
+{{if .TopCategories}}<h2>Основные жалобы</h2>
+<ul>{{range .TopCategories}}<li>{{.Name}}: {{.Count}}</li>{{end}}</ul>{{end}}
+<h2>Товары</h2>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Товар</th><th>offer_id</th><th>Бренд</th><th>Отрицательных</th><th>Положительных</th><th>Жалобы</th><th>Цитаты</th><th>Действия</th></tr>
+{{range .Products}}<tr>
+<td>{{if .URL}}<a href="{{.URL}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}</td>
+<td>{{.OfferID}}</td>
+<td>{{.Brand}}</td>
+<td>{{.Negative}}</td>
+<td>{{label .Labels "positive"}}</td>
+<td>{{range $i, $c := .Categories}}{{if $i}}, {{end}}{{$c.Name}} ({{$c.Count}}){{end}}</td>
+<td>{{range .Quotes}}<blockquote>{{.}}</blockquote>{{end}}</td>
+<td>{{range $i, $a := .Actions}}{{if $i}}, {{end}}{{$a}}{{end}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// HTML сводка в HTML для писем и просмотра в браузере
+func (digest Digest) HTML() (string, error) {
+	var b bytes.Buffer
+	if err := htmlTemplate.Execute(&b, digest); err != nil {
+		return "", fmt.Errorf("ошибка формирования HTML сводки %w", err)
+	}
+	return b.String(), nil
+}
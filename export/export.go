@@ -0,0 +1,117 @@
+package export
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/archive"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Format формат выгрузки
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatJSONL Format = "jsonl"
+	FormatXLSX  Format = "xlsx"
+)
+
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(value); format {
+	case FormatCSV, FormatJSONL, FormatXLSX:
+		return format, nil
+	default:
+		return "", apperrors.New(apperrors.Config, "неизвестный формат выгрузки %q, ожидается csv, jsonl или xlsx", value)
+	}
+}
+
+// Columns колонки табличных выгрузок
+var Columns = []string{
+	"review_id", "published_at", "rating", "author_name",
+	"text_positive", "text_negative", "text_comment",
+	"sku", "offer_id", "product_title", "product_url", "brand", "company",
+	"account", "label", "verdict", "reason", "decision", "action",
+	"price_before", "price_after", "run_id",
+}
+
+// numericColumns колонки с числами. Остальные колонки текстовые
+var numericColumns = map[string]bool{"rating": true, "price_before": true, "price_after": true}
+
+// Row значения колонок Columns для записи
+func Row(record archive.Record) []string {
+	review := record.Review
+	return []string{
+		review.ID, review.PublishedAt, strconv.Itoa(review.Rating), review.AuthorName,
+		review.Text.Positive, review.Text.Negative, review.Text.Comment,
+		review.SKU, review.Product.OfferID, review.Product.Title, review.Product.URL,
+		review.Product.BrandName, review.Product.CompanyName,
+		record.Account, string(record.Label), record.Verdict, record.Reason,
+		string(record.Decision), string(record.Action),
+		formatPrice(record.PriceBefore), formatPrice(record.PriceAfter), record.RunID,
+	}
+}
+
+func formatPrice(price float64) string {
+	if price == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(price, 'f', 2, 64)
+}
+
+// Write выгружает записи в выбранном формате
+func Write(w io.Writer, format Format, records []archive.Record) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, records)
+	case FormatJSONL:
+		return WriteJSONL(w, records)
+	case FormatXLSX:
+		return WriteXLSX(w, records)
+	default:
+		return apperrors.New(apperrors.Config, "неизвестный формат выгрузки %q", format)
+	}
+}
+
+func WriteCSV(w io.Writer, records []archive.Record) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(Columns); err != nil {
+		return fmt.Errorf("ошибка записи CSV %w", err)
+	}
+	for _, record := range records {
+		row := Row(record)
+		for i, value := range row {
+			if !numericColumns[Columns[i]] {
+				row[i] = escapeFormula(value)
+			}
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("ошибка записи CSV %w", err)
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// escapeFormula экранирует значение, которое табличный редактор принял бы за формулу:
+// текст отзыва пишет покупатель, и открытие выгрузки не должно выполнять его команды
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// WriteJSONL записывает каждую запись архива отдельной строкой JSON
+func WriteJSONL(w io.Writer, records []archive.Record) error {
+	encoder := json.NewEncoder(w)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("ошибка записи JSONL %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package export
+
+import (
+	"PriceGuardian/archive"
+	"PriceGuardian/ozon"
+	"bytes"
+	"encoding/csv"
+	"github.com/xuri/excelize/v2"
+	"testing"
+)
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", ""},
+		{"обычный текст", "обычный текст"},
+		{"=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"+7 999", "'+7 999"},
+		{"-1+1", "'-1+1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		if got := escapeFormula(tt.value); got != tt.want {
+			t.Errorf("escapeFormula(%q) = %q, ожидалось %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func testRecord(comment string, price float64) archive.Record {
+	return archive.Record{
+		Review:      ozon.Review{ID: "r1", Rating: 1, Text: ozon.ReviewText{Comment: comment}},
+		Account:     "main",
+		Label:       archive.LabelNegative,
+		PriceBefore: price,
+	}
+}
+
+func column(name string) int {
+	for i, column := range Columns {
+		if column == name {
+			return i
+		}
+	}
+	panic(name)
+}
+
+func TestWriteCSV(t *testing.T) {
+	tests := []struct {
+		name        string
+		record      archive.Record
+		wantComment string
+		wantPrice   string
+	}{
+		{"текст без изменений", testRecord("плохо", 0), "плохо", ""},
+		{"формула экранируется", testRecord("=cmd|' /C calc'!A0", 0), "'=cmd|' /C calc'!A0", ""},
+		{"цена не экранируется", testRecord("-", 1234.5), "'-", "1234.50"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := WriteCSV(&b, []archive.Record{tt.record}); err != nil {
+				t.Fatal(err)
+			}
+			rows, err := csv.NewReader(&b).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 2 {
+				t.Fatalf("получено %d строк, ожидалось 2", len(rows))
+			}
+			if got := rows[1][column("text_comment")]; got != tt.wantComment {
+				t.Errorf("text_comment = %q, ожидалось %q", got, tt.wantComment)
+			}
+			if got := rows[1][column("price_before")]; got != tt.wantPrice {
+				t.Errorf("price_before = %q, ожидалось %q", got, tt.wantPrice)
+			}
+		})
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	tests := []struct {
+		name        string
+		record      archive.Record
+		wantComment string
+		wantPrice   string
+	}{
+		{"строка", testRecord("плохо", 0), "плохо", ""},
+		{"формула остается строкой", testRecord("=1+1", 99.9), "=1+1", "99.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := WriteXLSX(&b, []archive.Record{tt.record}); err != nil {
+				t.Fatal(err)
+			}
+			book, err := excelize.OpenReader(&b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer book.Close()
+			commentCell, _ := excelize.CoordinatesToCellName(column("text_comment")+1, 2)
+			if formula, _ := book.GetCellFormula(sheetName, commentCell); formula != "" {
+				t.Errorf("в %s записана формула %q", commentCell, formula)
+			}
+			if got, _ := book.GetCellValue(sheetName, commentCell); got != tt.wantComment {
+				t.Errorf("text_comment = %q, ожидалось %q", got, tt.wantComment)
+			}
+			priceCell, _ := excelize.CoordinatesToCellName(column("price_before")+1, 2)
+			if got, _ := book.GetCellValue(sheetName, priceCell); got != tt.wantPrice {
+				t.Errorf("price_before = %q, ожидалось %q", got, tt.wantPrice)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package export
+
+import (
+	"PriceGuardian/archive"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"strconv"
+)
+
+// sheetName имя листа с отзывами
+const sheetName = "reviews"
+
+// WriteXLSX записывает книгу Excel с одним листом. Цены и рейтинг записываются числами, остальное строками,
+// поэтому значения не вычисляются как формулы
+func WriteXLSX(w io.Writer, records []archive.Record) error {
+	book := excelize.NewFile()
+	defer book.Close()
+	if err := book.SetSheetName(book.GetSheetName(0), sheetName); err != nil {
+		return fmt.Errorf("ошибка записи XLSX %w", err)
+	}
+	stream, err := book.NewStreamWriter(sheetName)
+	if err != nil {
+		return fmt.Errorf("ошибка записи XLSX %w", err)
+	}
+	header := make([]interface{}, len(Columns))
+	for i, column := range Columns {
+		header[i] = column
+	}
+	if err := stream.SetRow("A1", header); err != nil {
+		return fmt.Errorf("ошибка записи XLSX %w", err)
+	}
+	for i, record := range records {
+		values := Row(record)
+		row := make([]interface{}, len(values))
+		for j, value := range values {
+			row[j] = value
+			if numericColumns[Columns[j]] {
+				// пустое число остается пустой ячейкой
+				row[j] = nil
+				if number, err := strconv.ParseFloat(value, 64); err == nil {
+					row[j] = number
+				}
+			}
+		}
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return fmt.Errorf("ошибка записи XLSX %w", err)
+		}
+		if err := stream.SetRow(cell, row); err != nil {
+			return fmt.Errorf("ошибка записи XLSX %w", err)
+		}
+	}
+	if err := stream.Flush(); err != nil {
+		return fmt.Errorf("ошибка записи XLSX %w", err)
+	}
+	if err := book.Write(w); err != nil {
+		return fmt.Errorf("ошибка записи XLSX %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/links"
+	"PriceGuardian/marketplace"
+	"PriceGuardian/ozon"
+	"PriceGuardian/params"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// Linker распространяет карантин товара на тот же товар в других кабинетах
+type Linker struct {
+	table   *links.Table
+	matches []links.Match
+}
+
+// newLinker nil, если распространение карантина выключено. Таблица связей общая, поэтому
+// настройки берутся из первого кабинета
+func newLinker(cfg params.Config) (*Linker, error) {
+	if !cfg.Links.Propagate {
+		return nil, nil
+	}
+	matches, err := links.ParseMatches(cfg.Links.Match)
+	if err != nil {
+		return nil, err
+	}
+	table, err := links.Load(cfg.Links.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &Linker{table: table, matches: matches}, nil
+}
+
+// propagate помещает на карантин товары других кабинетов, связанные с товарами offerIDs кабинета source,
+// и возвращает итоги по каждому затронутому кабинету. Связанный товар получает действие по политике своего
+// кабинета и отзывы исходного товара. Если в кабинете включено подтверждение, товар ставится в очередь
+func (linker *Linker) propagate(ctx context.Context, source *Pipeline, offerIDs []string, pipelines []*Pipeline) []RunSummary {
+	if linker == nil || len(offerIDs) == 0 || source.account == "" {
+		return nil
+	}
+	targets := make(map[string]*Pipeline, len(pipelines))
+	accounts := make([]string, 0, len(pipelines))
+	for _, pipeline := range pipelines {
+		if pipeline != source && pipeline.account != "" {
+			targets[pipeline.account] = pipeline
+			accounts = append(accounts, pipeline.account)
+		}
+	}
+	// связанные товары по кабинетам вместе с отзывами исходного товара
+	linked := make(map[string]map[string][]ozon.Review)
+	for _, offerID := range offerIDs {
+		var reviews []ozon.Review
+		if record, ok := source.api.QuarantineRecord(offerID); ok {
+			reviews = record.Reviews
+		}
+		for _, link := range linker.table.Linked(links.Listing{Account: source.account, OfferID: offerID}, accounts, linker.matches) {
+			target, ok := targets[link.Account]
+			if !ok {
+				continue
+			}
+			if _, ok := target.api.QuarantineRecord(link.OfferID); ok {
+				continue
+			}
+			slog.Info("карантин распространяется на связанный товар", "account", source.account, "offer_id", offerID,
+				"linked_account", link.Account, "linked_offer_id", link.OfferID, "match", link.Match)
+			if linked[link.Account] == nil {
+				linked[link.Account] = make(map[string][]ozon.Review)
+			}
+			linked[link.Account][link.OfferID] = append(linked[link.Account][link.OfferID], reviews...)
+		}
+	}
+	var summaries []RunSummary
+	for _, account := range accounts {
+		if len(linked[account]) == 0 {
+			continue
+		}
+		summaries = append(summaries, targets[account].quarantineLinked(ctx, source.account, linked[account]))
+	}
+	return summaries
+}
+
+// quarantineLinked помещает на карантин товары, связанные с товарами кабинета source
+func (p *Pipeline) quarantineLinked(ctx context.Context, source string, reviews map[string][]ozon.Review) RunSummary {
+	summary := RunSummary{RunID: newRunID(), Account: p.account, StartedAt: time.Now()}
+	logger := slog.With("run_id", summary.RunID, "account", p.account, "marketplace", p.api.Name(),
+		"source_account", source, "dry_run", p.dryRun)
+	offerIdsByAction := make(map[ozon.QuarantineAction][]string)
+	for offerID, offerReviews := range reviews {
+		action := p.policy.ActionFor(offerID, ozon.ComplaintCategories(offerReviews))
+		offerIdsByAction[action] = append(offerIdsByAction[action], offerID)
+	}
+	switch {
+	case p.dryRun:
+		for action, offerIds := range offerIdsByAction {
+			logger.Info("связанные товары были бы помещены на карантин", "action", action, "offer_ids", offerIds)
+		}
+	case p.approvals != nil:
+		if err := p.requestApproval(ctx, logger, summary.RunID, offerIdsByAction, reviews); err != nil {
+			summary.addError(logger, "ошибка при постановке связанных товаров в очередь подтверждения", err)
+		}
+	default:
+		p.quarantine(ctx, logger, &summary, offerIdsByAction, reviews)
+	}
+	summary.FinishedAt = time.Now()
+	return summary
+}
+
+// linksCommand таблица связей одинаковых товаров разных кабинетов
+func linksCommand(cmdArgs []string) error {
+	const linksUsage = "использование: links sync|show [кабинет:offer_id]|add <кабинет:offer_id...>|remove <кабинет:offer_id>"
+	if len(cmdArgs) == 0 {
+		return apperrors.New(apperrors.Config, linksUsage)
+	}
+	fs := newCommandFlags("links " + cmdArgs[0])
+	if err := fs.parse(cmdArgs[1:]); err != nil {
+		return err
+	}
+	accounts, err := loadAccounts()
+	if err != nil {
+		return err
+	}
+	cfg := accounts[0]
+	table, err := links.Load(cfg.Links.Path)
+	if err != nil {
+		return err
+	}
+	listings := make([]links.Listing, 0, fs.NArg())
+	for _, arg := range fs.Args() {
+		listing, err := links.ParseListing(arg)
+		if err != nil {
+			return err
+		}
+		if _, err := findAccount(accounts, listing.Account); err != nil || listing.Account == "" {
+			return apperrors.New(apperrors.Validation, "кабинет %q не найден", listing.Account)
+		}
+		listings = append(listings, listing)
+	}
+	switch cmdArgs[0] {
+	case "sync":
+		return syncLinks(context.Background(), table, accounts, fs.account)
+	case "add":
+		if err := table.Add(listings); err != nil {
+			return err
+		}
+		return printManualLinks(fs.format, table.Manual)
+	case "remove":
+		if len(listings) != 1 {
+			return apperrors.New(apperrors.Config, linksUsage)
+		}
+		if err := table.Remove(listings[0]); err != nil {
+			return err
+		}
+		return printManualLinks(fs.format, table.Manual)
+	case "show":
+		if len(listings) == 0 {
+			return printManualLinks(fs.format, table.Manual)
+		}
+		matches, err := links.ParseMatches(cfg.Links.Match)
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(accounts))
+		for _, account := range accounts {
+			names = append(names, account.Account)
+		}
+		linked := table.Linked(listings[0], names, matches)
+		rows := make([][]string, 0, len(linked))
+		for _, link := range linked {
+			rows = append(rows, []string{link.Account, link.OfferID, string(link.Match)})
+		}
+		return printResult(os.Stdout, fs.format, linked, []string{"ACCOUNT", "OFFER_ID", "MATCH"}, rows)
+	default:
+		return apperrors.New(apperrors.Config, linksUsage)
+	}
+}
+
+// syncLinks загружает штрихкоды товаров всех кабинетов или кабинета account в таблицу связей
+func syncLinks(ctx context.Context, table *links.Table, accounts []params.Config, account string) error {
+	var problems []string
+	for _, cfg := range accounts {
+		name := cfg.Account
+		if account != "" && name != account {
+			continue
+		}
+		api, err := marketplace.New(cfg)
+		if err != nil {
+			return err
+		}
+		barcodes, err := api.Barcodes(ctx)
+		if err != nil {
+			slog.Error("не удалось загрузить штрихкоды товаров", "account", name, "error", err)
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		if err := table.SetBarcodes(name, barcodes); err != nil {
+			return err
+		}
+		slog.Info("штрихкоды товаров загружены", "account", name, "offers", len(barcodes))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("не удалось загрузить каталоги кабинетов: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// printManualLinks выводит группы товаров, связанных вручную
+func printManualLinks(format string, groups [][]links.Listing) error {
+	rows := make([][]string, 0, len(groups))
+	for i, group := range groups {
+		for _, listing := range group {
+			rows = append(rows, []string{fmt.Sprint(i + 1), listing.Account, listing.OfferID})
+		}
+	}
+	if groups == nil {
+		groups = [][]links.Listing{}
+	}
+	return printResult(os.Stdout, format, groups, []string{"GROUP", "ACCOUNT", "OFFER_ID"}, rows)
+}
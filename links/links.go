@@ -0,0 +1,254 @@
+package links
+
+import (
+	"PriceGuardian/apperrors"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Match способ найти тот же товар в другом кабинете
+type Match string
+
+const (
+	// MatchOfferID одинаковый offer_id (артикул продавца)
+	MatchOfferID Match = "offer_id"
+	// MatchBarcode общий штрихкод. Штрихкоды загружаются из каталогов кабинетов командой links sync
+	MatchBarcode Match = "barcode"
+	// MatchManual ручная связь из таблицы
+	MatchManual Match = "manual"
+)
+
+// ParseMatches разбирает список способов через запятую
+func ParseMatches(s string) ([]Match, error) {
+	var res []Match
+	for _, item := range strings.Split(s, ",") {
+		switch match := Match(strings.TrimSpace(item)); match {
+		case "":
+		case MatchOfferID, MatchBarcode, MatchManual:
+			res = append(res, match)
+		default:
+			return nil, apperrors.New(apperrors.Config, "неизвестный способ связи товаров %q", item)
+		}
+	}
+	return res, nil
+}
+
+// Listing товар в кабинете
+type Listing struct {
+	Account string `json:"account"`
+	OfferID string `json:"offer_id"`
+}
+
+// ParseListing разбирает товар в виде кабинет:offer_id
+func ParseListing(s string) (Listing, error) {
+	account, offerID, ok := strings.Cut(s, ":")
+	if !ok || offerID == "" {
+		return Listing{}, apperrors.New(apperrors.Validation, "ожидается кабинет:offer_id, получено %q", s)
+	}
+	return Listing{Account: account, OfferID: offerID}, nil
+}
+
+func (listing Listing) String() string {
+	return listing.Account + ":" + listing.OfferID
+}
+
+// Link связанный товар и способ, которым найдена связь
+type Link struct {
+	Listing
+	Match Match `json:"match"`
+}
+
+// Table таблица связей товаров разных кабинетов, сохраняемая в файл между запусками
+type Table struct {
+	path string
+	mu   sync.Mutex
+	// Manual группы товаров, связанных вручную
+	Manual [][]Listing `json:"manual"`
+	// Barcodes штрихкоды товаров по кабинетам и offer_id
+	Barcodes map[string]map[string][]string `json:"barcodes"`
+	// SyncedAt время последней загрузки штрихкодов по кабинетам
+	SyncedAt map[string]time.Time `json:"synced_at"`
+}
+
+func Load(path string) (*Table, error) {
+	table := &Table{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("не удалось прочитать таблицу связей товаров %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, table); err != nil {
+			return nil, fmt.Errorf("не удалось распарсить таблицу связей товаров %w", err)
+		}
+	}
+	if table.Barcodes == nil {
+		table.Barcodes = make(map[string]map[string][]string)
+	}
+	if table.SyncedAt == nil {
+		table.SyncedAt = make(map[string]time.Time)
+	}
+	return table, nil
+}
+
+// SetBarcodes заменяет штрихкоды товаров кабинета account
+func (table *Table) SetBarcodes(account string, barcodes map[string][]string) error {
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	table.Barcodes[account] = barcodes
+	table.SyncedAt[account] = time.Now()
+	return table.save()
+}
+
+// Add связывает товары вручную. Если какой-то из них уже связан, группы объединяются
+func (table *Table) Add(listings []Listing) error {
+	if len(listings) < 2 {
+		return apperrors.New(apperrors.Validation, "для связи нужно хотя бы два товара")
+	}
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	merged := append([]Listing(nil), listings...)
+	var rest [][]Listing
+	for _, group := range table.Manual {
+		if overlaps(group, listings) {
+			merged = append(merged, group...)
+		} else {
+			rest = append(rest, group)
+		}
+	}
+	table.Manual = append(rest, unique(merged))
+	return table.save()
+}
+
+// Remove убирает товар из ручных связей
+func (table *Table) Remove(listing Listing) error {
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	var groups [][]Listing
+	found := false
+	for _, group := range table.Manual {
+		var kept []Listing
+		for _, item := range group {
+			if item == listing {
+				found = true
+				continue
+			}
+			kept = append(kept, item)
+		}
+		if len(kept) > 1 {
+			groups = append(groups, kept)
+		}
+	}
+	if !found {
+		return apperrors.New(apperrors.Validation, "товар %s не связан вручную", listing)
+	}
+	table.Manual = groups
+	return table.save()
+}
+
+// Linked товары других кабинетов из accounts, связанные с listing выбранными способами.
+// Совпадение по offer_id учитывается, только если товар есть в загруженном каталоге кабинета
+// или каталог кабинета еще не загружался
+func (table *Table) Linked(listing Listing, accounts []string, matches []Match) []Link {
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	seen := map[Listing]bool{listing: true}
+	var res []Link
+	add := func(target Listing, match Match) {
+		if !seen[target] {
+			seen[target] = true
+			res = append(res, Link{Listing: target, Match: match})
+		}
+	}
+	for _, match := range matches {
+		switch match {
+		case MatchManual:
+			for _, group := range table.Manual {
+				if !overlaps(group, []Listing{listing}) {
+					continue
+				}
+				for _, item := range group {
+					add(item, MatchManual)
+				}
+			}
+		case MatchOfferID:
+			for _, account := range accounts {
+				catalog, synced := table.Barcodes[account]
+				if _, ok := catalog[listing.OfferID]; ok || !synced {
+					add(Listing{Account: account, OfferID: listing.OfferID}, MatchOfferID)
+				}
+			}
+		case MatchBarcode:
+			barcodes := make(map[string]bool)
+			for _, barcode := range table.Barcodes[listing.Account][listing.OfferID] {
+				barcodes[barcode] = true
+			}
+			if len(barcodes) == 0 {
+				continue
+			}
+			for _, account := range accounts {
+				offerIDs := make([]string, 0)
+				for offerID, offerBarcodes := range table.Barcodes[account] {
+					for _, barcode := range offerBarcodes {
+						if barcodes[barcode] {
+							offerIDs = append(offerIDs, offerID)
+							break
+						}
+					}
+				}
+				sort.Strings(offerIDs)
+				for _, offerID := range offerIDs {
+					add(Listing{Account: account, OfferID: offerID}, MatchBarcode)
+				}
+			}
+		}
+	}
+	// связи внутри одного кабинета не нужны: товар кабинета и так обрабатывается его проходом
+	filtered := res[:0]
+	for _, link := range res {
+		if link.Account != listing.Account {
+			filtered = append(filtered, link)
+		}
+	}
+	return filtered
+}
+
+func overlaps(group, listings []Listing) bool {
+	for _, a := range group {
+		for _, b := range listings {
+			if a == b {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func unique(listings []Listing) []Listing {
+	seen := make(map[Listing]bool, len(listings))
+	var res []Listing
+	for _, listing := range listings {
+		if !seen[listing] {
+			seen[listing] = true
+			res = append(res, listing)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].String() < res[j].String() })
+	return res
+}
+
+func (table *Table) save() error {
+	data, err := json.MarshalIndent(table, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации таблицы связей товаров %w", err)
+	}
+	if err := os.WriteFile(table.path, data, 0644); err != nil {
+		return fmt.Errorf("не удалось сохранить таблицу связей товаров %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package links
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTable(t *testing.T) *Table {
+	table, err := Load(filepath.Join(t.TempDir(), "links.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+func TestLinked(t *testing.T) {
+	table := newTable(t)
+	if err := table.SetBarcodes("ozon", map[string][]string{"kettle": {"460001"}, "cup": {"460002"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.SetBarcodes("wb", map[string][]string{"wb-kettle": {"460001"}, "cup": {}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Add([]Listing{{"ozon", "cup"}, {"market", "mug"}}); err != nil {
+		t.Fatal(err)
+	}
+	accounts := []string{"ozon", "wb", "market"}
+	tests := []struct {
+		name    string
+		listing Listing
+		matches []Match
+		want    []Link
+	}{
+		{
+			name:    "по штрихкоду",
+			listing: Listing{"ozon", "kettle"},
+			matches: []Match{MatchBarcode},
+			want:    []Link{{Listing{"wb", "wb-kettle"}, MatchBarcode}},
+		},
+		{
+			name:    "по offer_id только в загруженных каталогах или незагруженных кабинетах",
+			listing: Listing{"ozon", "kettle"},
+			matches: []Match{MatchOfferID},
+			want:    []Link{{Listing{"market", "kettle"}, MatchOfferID}},
+		},
+		{
+			name:    "ручная связь и offer_id без повторов",
+			listing: Listing{"ozon", "cup"},
+			matches: []Match{MatchManual, MatchOfferID},
+			want:    []Link{{Listing{"market", "mug"}, MatchManual}, {Listing{"wb", "cup"}, MatchOfferID}, {Listing{"market", "cup"}, MatchOfferID}},
+		},
+		{
+			name:    "способ связи не выбран",
+			listing: Listing{"ozon", "kettle"},
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := table.Linked(tt.listing, accounts, tt.matches)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("получено %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddMergesGroups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.json")
+	table, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Add([]Listing{{"ozon", "a"}, {"wb", "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Add([]Listing{{"wb", "a"}, {"market", "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]Listing{{{"market", "a"}, {"ozon", "a"}, {"wb", "a"}}}
+	if !reflect.DeepEqual(table.Manual, want) {
+		t.Errorf("получено %v, ожидалось %v", table.Manual, want)
+	}
+	if err := table.Remove(Listing{"ozon", "a"}); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = [][]Listing{{{"market", "a"}, {"wb", "a"}}}
+	if !reflect.DeepEqual(loaded.Manual, want) {
+		t.Errorf("после удаления %v, ожидалось %v", loaded.Manual, want)
+	}
+	if err := table.Remove(Listing{"ozon", "a"}); err == nil {
+		t.Error("ожидалась ошибка удаления несвязанного товара")
+	}
+}
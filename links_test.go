@@ -0,0 +1,78 @@
+package main
+
+import (
+	"PriceGuardian/approval"
+	"PriceGuardian/links"
+	"PriceGuardian/ozon"
+	"context"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLinkerPropagate(t *testing.T) {
+	source := newTestPipeline(t, "ozon", "kettle", "cup")
+	wb := newTestPipeline(t, "wb", "wb-kettle", "cup")
+	market := newTestPipeline(t, "market", "kettle")
+	approvals, err := approval.Load(filepath.Join(t.TempDir(), "approvals.json"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	market.approvals = approvals
+	pipelines := []*Pipeline{source, wb, market}
+
+	table, err := links.Load(filepath.Join(t.TempDir(), "links.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Add([]links.Listing{{Account: "ozon", OfferID: "kettle"}, {Account: "wb", OfferID: "wb-kettle"}}); err != nil {
+		t.Fatal(err)
+	}
+	// каталог маркета загружен, поэтому cup по offer_id с ним не связывается
+	if err := table.SetBarcodes("market", map[string][]string{"kettle": {}}); err != nil {
+		t.Fatal(err)
+	}
+	linker := &Linker{table: table, matches: []links.Match{links.MatchManual, links.MatchOfferID}}
+
+	ctx := context.Background()
+	reviews := map[string][]ozon.Review{"kettle": {{ID: "r1"}}}
+	// cup уже на карантине в wb, повторно он не помещается
+	if _, err := wb.api.Quarantine(ctx, []string{"cup"}, ozon.ActionPrice, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.api.Quarantine(ctx, []string{"kettle", "cup"}, ozon.ActionPrice, reviews); err != nil {
+		t.Fatal(err)
+	}
+
+	summaries := linker.propagate(ctx, source, []string{"kettle", "cup"}, pipelines)
+	var accounts []string
+	for _, summary := range summaries {
+		accounts = append(accounts, summary.Account)
+		if len(summary.Errors) > 0 {
+			t.Errorf("ошибки кабинета %s: %v", summary.Account, summary.Errors)
+		}
+	}
+	sort.Strings(accounts)
+	if want := []string{"market", "wb"}; !reflect.DeepEqual(accounts, want) {
+		t.Errorf("затронуты кабинеты %v, ожидалось %v", accounts, want)
+	}
+	record, ok := wb.api.QuarantineRecord("wb-kettle")
+	if !ok {
+		t.Fatal("связанный товар wb не помещен на карантин")
+	}
+	if !reflect.DeepEqual(record.Reviews, reviews["kettle"]) {
+		t.Errorf("отзывы связанного товара %v, ожидалось %v", record.Reviews, reviews["kettle"])
+	}
+	if _, ok := market.api.QuarantineRecord("kettle"); ok {
+		t.Error("товар кабинета с подтверждением помещен на карантин без подтверждения")
+	}
+	if pending := market.approvals.Pending(); len(pending) != 1 || pending[0].OfferID != "kettle" {
+		t.Errorf("в очереди подтверждения %v, ожидался kettle", pending)
+	}
+
+	if got := (*Linker)(nil).propagate(ctx, source, []string{"kettle"}, pipelines); got != nil {
+		t.Errorf("выключенное распространение вернуло %v", got)
+	}
+}
@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// redactedKeys атрибуты, значения которых не должны попадать в логи
+var redactedKeys = map[string]bool{
+	"author_name":   true,
+	"authorization": true,
+	"access_token":  true,
+	"api_key":       true,
+	"auth_data":     true,
+	"cookie":        true,
+	"password":      true,
+	"token":         true,
+}
+
+const redacted = "***"
+
+// Setup настраивает логгер по умолчанию. format text или json, level debug, info, warn или error
+func Setup(w io.Writer, level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("неизвестный уровень логирования %q", level)
+	}
+	options := &slog.HandlerOptions{Level: lvl, ReplaceAttr: redact}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	case "json":
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return fmt.Errorf("неизвестный формат логов %q", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if redactedKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redacted)
+	}
+	return attr
+}
@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestSetupRedacts(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	var buf bytes.Buffer
+	if err := Setup(&buf, "info", "json"); err != nil {
+		t.Fatal(err)
+	}
+	slog.Debug("не попадет в лог")
+	slog.Info("запрос", "offer_id", "a", "Authorization", "Bearer secret", "token", "123",
+		slog.Group("request", "api_key", "key"))
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("ожидалась одна запись json: %v, %s", err, buf.String())
+	}
+	want := map[string]interface{}{
+		"offer_id":      "a",
+		"Authorization": redacted,
+		"token":         redacted,
+		"request":       map[string]interface{}{"api_key": redacted},
+	}
+	for key, value := range want {
+		if !reflect.DeepEqual(got[key], value) {
+			t.Errorf("%s: получено %v, ожидалось %v", key, got[key], value)
+		}
+	}
+}
+
+func TestSetupErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		level  string
+		format string
+	}{
+		{"неизвестный уровень", "verbose", "text"},
+		{"неизвестный формат", "info", "xml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Setup(&bytes.Buffer{}, tt.level, tt.format); err == nil {
+				t.Error("ожидалась ошибка")
+			}
+		})
+	}
+}
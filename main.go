@@ -1,36 +1,58 @@
 package main
 
 import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/approval"
+	"PriceGuardian/archive"
 	pb "PriceGuardian/gigachat"
+	"PriceGuardian/logging"
+	"PriceGuardian/marketplace"
+	"PriceGuardian/metrics"
+	"PriceGuardian/notify"
 	"PriceGuardian/ozon"
 	"PriceGuardian/params"
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	uuid "github.com/nu7hatch/gouuid"
-	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
 	"time"
+	"unicode"
 )
 
 // 746 токенов на 10 товаров 894 891 в месяц ~ 11_995
 
 type ChatClient struct {
 	client    pb.ChatServiceClient
-	ctx       context.Context
-	args      params.Params
+	md        metadata.MD
+	config    params.GigachatConfig
+	timeout   time.Duration
 	expiresAt time.Time
+	// tokenBudget суточный лимит токенов, 0 без ограничения. usedTokens расход за день budgetDay
+	tokenBudget int
+	usedTokens  int
+	budgetDay   string
+	// verdicts ответы модели за день budgetDay по промпту и тексту: одинаковые тексты, например "Все отлично",
+	// повторно не отправляются
+	verdicts map[string]Verdict
 }
 
+// errTokenBudgetExceeded суточный бюджет токенов израсходован, классификация возобновится на следующий день
+var errTokenBudgetExceeded = apperrors.New(apperrors.RateLimit, "израсходован суточный бюджет токенов GigaChat")
+
 func (chat *ChatClient) Close() {
 	if closer, ok := chat.client.(io.Closer); ok {
 		closer.Close()
@@ -38,79 +60,660 @@ func (chat *ChatClient) Close() {
 }
 
 // NewChatClient создает новый экземпляр ChatClient
-func NewChatClient(args params.Params) *ChatClient {
+func NewChatClient(cfg params.GigachatConfig) (*ChatClient, error) {
 	// Устанавливаем соединение с gRPC сервером
 	creds := credentials.NewClientTLSFromCert(nil, "")
-	conn, err := grpc.NewClient("gigachat.devices.sberbank.ru", grpc.WithTransportCredentials(creds))
+	conn, err := grpc.NewClient(cfg.URL, grpc.WithTransportCredentials(creds))
 	if err != nil {
-		log.Fatalf("%v", err)
+		return nil, apperrors.Wrap(apperrors.Transport, err, "не удалось создать gRPC клиент")
 	}
-	// Создаем контекст с тайм-аутом
-	ctx := context.Background()
 	return &ChatClient{
-		client:    pb.NewChatServiceClient(conn),
-		ctx:       ctx,
-		args:      args,
-		expiresAt: time.Now().Add(-time.Hour),
-	}
+		client:      pb.NewChatServiceClient(conn),
+		config:      cfg,
+		timeout:     cfg.Timeout,
+		expiresAt:   time.Now().Add(-time.Hour),
+		tokenBudget: cfg.TokenBudget,
+	}, nil
 }
 
 func main() {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	args := params.LoadParams()
+	if len(os.Args) > 1 {
+		exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+	exit(runOnce(""))
+}
+
+// exit завершает программу с кодом, соответствующим категории ошибки
+func exit(err error) {
+	if err == nil {
+		os.Exit(0)
+	}
+	slog.Error("завершение с ошибкой", "error", err, "kind", apperrors.KindOf(err))
+	codes := map[apperrors.Kind]int{
+		apperrors.Config:     2,
+		apperrors.Auth:       3,
+		apperrors.RateLimit:  4,
+		apperrors.Validation: 5,
+		apperrors.Transport:  6,
+	}
+	if code, ok := codes[apperrors.KindOf(err)]; ok {
+		os.Exit(code)
+	}
+	os.Exit(1)
+}
+
+// runOnce один проход по кабинету account или по всем кабинетам, если имя пустое
+func runOnce(account string) error {
+	accounts, err := loadAccounts()
+	if err != nil {
+		return err
+	}
+	if _, err := findAccount(accounts, account); err != nil {
+		return err
+	}
+	linker, err := newLinker(accounts[0])
+	if err != nil {
+		return err
+	}
+	// связанные товары могут быть в любом кабинете, поэтому при распространении карантина
+	// обработчики нужны для всех кабинетов, даже если проход выполняется по одному
+	pipelines, err := newPipelines(accounts, linker != nil || account == "", account)
+	if err != nil {
+		return err
+	}
+	defer closePipelines(pipelines)
+	failed := 0
+	var errs []error
+	for _, pipeline := range pipelines {
+		if account != "" && pipeline.account != account {
+			continue
+		}
+		summary := pipeline.Process(context.Background())
+		summary.log()
+		if len(summary.Errors) > 0 {
+			failed++
+			errs = append(errs, summary.errs...)
+		}
+		for _, linked := range linker.propagate(context.Background(), pipeline, summary.Quarantined, pipelines) {
+			linked.log()
+			if len(linked.Errors) > 0 {
+				failed++
+				errs = append(errs, linked.errs...)
+			}
+		}
+	}
+	if failed > 0 {
+		// код завершения определяется самой серьезной из ошибок кабинетов
+		return &apperrors.Error{Kind: apperrors.MostSevere(errs...),
+			Msg: fmt.Sprintf("проход завершился с ошибками в %d кабинетах", failed), Err: errors.Join(errs...)}
+	}
+	return nil
+}
+
+// newPipelines обработчики всех кабинетов, если all, иначе только кабинета account
+func newPipelines(accounts []params.Config, all bool, account string) ([]*Pipeline, error) {
+	var pipelines []*Pipeline
+	for _, cfg := range accounts {
+		if !all && cfg.Account != account {
+			continue
+		}
+		pipeline, err := NewPipeline(cfg)
+		if err != nil {
+			closePipelines(pipelines)
+			return nil, err
+		}
+		pipelines = append(pipelines, pipeline)
+	}
+	return pipelines, nil
+}
+
+// closePipelines закрывает соединения с GigaChat
+func closePipelines(pipelines []*Pipeline) {
+	for _, pipeline := range pipelines {
+		pipeline.chat.Close()
+	}
+}
+
+// Pipeline классификация новых отзывов и карантин товаров
+type Pipeline struct {
+	account string
+	chat    *ChatClient
+	api     marketplace.Adapter
+	policy  ozon.QuarantinePolicy
+	// minNegative сколько отрицательных отзывов за проход нужно, чтобы поместить товар на карантин
+	minNegative int
+	// dryRun только логировать решения, не меняя цены и остатки
+	dryRun        bool
+	watermarkPath string
+	// questions источник вопросов покупателей, nil если анализ вопросов выключен
+	questions              marketplace.QuestionSource
+	questionsWatermarkPath string
+	// archive все обработанные отзывы с результатом классификации и решением
+	archive  *archive.Archive
+	notifier *notify.Router
+	// approvals очередь товаров, ожидающих подтверждения карантина, nil если подтверждение выключено
+	approvals *approval.Queue
+	// budgetNotifiedDay день, за который уже отправлено уведомление о бюджете токенов
+	budgetNotifiedDay string
+	// cookiesNotifiedDay день, за который уже отправлено уведомление об устаревших cookies.
+	// Сбрасывается, когда отзывы снова загружаются, чтобы следующее устаревание не пропустить
+	cookiesNotifiedDay string
+}
+
+func NewPipeline(cfg params.Config) (*Pipeline, error) {
 	//Устанавливаем соединение с gRPC сервером
-	chat := NewChatClient(args)
-	api := ozon.NewApi(args)
-	reviews := loadNewReviews(api)
-	idsToQuarantine := make(map[string]bool, len(reviews)) // no sets in go
+	chat, err := NewChatClient(cfg.Gigachat)
+	if err != nil {
+		return nil, err
+	}
+	api, err := marketplace.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := ozon.ParseQuarantinePolicy(
+		cfg.Quarantine.Action,
+		cfg.Quarantine.OfferActions,
+		cfg.Quarantine.CategoryActions,
+	)
+	if err != nil {
+		return nil, err
+	}
+	reviewArchive, err := archive.Open(cfg.Archive.Path)
+	if err != nil {
+		return nil, err
+	}
+	notifier, err := notify.New(cfg.Notify)
+	if err != nil {
+		return nil, err
+	}
+	var approvals *approval.Queue
+	if cfg.Quarantine.Approval {
+		if approvals, err = approval.Load(cfg.Quarantine.ApprovalPath, cfg.Quarantine.ApprovalTTL); err != nil {
+			return nil, err
+		}
+	}
+	var questions marketplace.QuestionSource
+	if cfg.Ozon.Questions {
+		source, ok := api.(marketplace.QuestionSource)
+		if !ok {
+			return nil, apperrors.New(apperrors.Config, "площадка %s не поддерживает вопросы покупателей", api.Name())
+		}
+		questions = source
+	}
+	return &Pipeline{
+		questions:              questions,
+		questionsWatermarkPath: cfg.Ozon.QuestionsWatermarkPath,
+		approvals:              approvals,
+		notifier:               notifier,
+		archive:                reviewArchive,
+		account:                cfg.Account,
+		watermarkPath:          cfg.Ozon.WatermarkPath,
+		chat:                   chat,
+		api:                    api,
+		policy:                 policy,
+		minNegative:            cfg.Quarantine.MinNegativeReviews,
+		dryRun:                 cfg.Quarantine.DryRun,
+	}, nil
+}
+
+// RunSummary итоги одного прохода
+type RunSummary struct {
+	RunID       string    `json:"run_id"`
+	Account     string    `json:"account"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	Reviews     int       `json:"reviews"`
+	Questions   int       `json:"questions"`
+	Negative    int       `json:"negative"`
+	Quarantined []string  `json:"quarantined"`
+	// Skipped товары, которые уже были на карантине или не найдены на площадке
+	Skipped []string `json:"skipped"`
+	Errors  []string `json:"errors"`
+	// errs ошибки прохода с категориями для выбора кода завершения
+	errs []error
+}
+
+// log отчет о проходе
+func (summary RunSummary) log() {
+	slog.Info("проход завершен", "run_id", summary.RunID, "account", summary.Account,
+		"duration", summary.FinishedAt.Sub(summary.StartedAt), "reviews", summary.Reviews,
+		"questions", summary.Questions, "negative", summary.Negative, "quarantined", len(summary.Quarantined), "skipped", len(summary.Skipped), "errors", len(summary.Errors))
+}
+
+func (summary *RunSummary) addError(logger *slog.Logger, msg string, err error, attrs ...any) {
+	logger.Error(msg, append(attrs, "error", err)...)
+	summary.Errors = append(summary.Errors, fmt.Sprintf("%s: %v", msg, err))
+	summary.errs = append(summary.errs, err)
+}
+
+// loadAccounts загружает конфигурацию всех кабинетов и настраивает логирование по первому из них
+func loadAccounts() ([]params.Config, error) {
+	accounts, err := params.LoadAccounts()
+	if err != nil {
+		return nil, err
+	}
+	cfg := accounts[0]
+	if err := logging.Setup(os.Stderr, cfg.Log.Level, cfg.Log.Format); err != nil {
+		return nil, apperrors.Wrap(apperrors.Config, err, "ошибка настройки логирования")
+	}
+	return accounts, nil
+}
+
+// loadAccount конфигурация кабинета с именем name или первого кабинета, если имя пустое
+func loadAccount(name string) (params.Config, error) {
+	accounts, err := loadAccounts()
+	if err != nil {
+		return params.Config{}, err
+	}
+	return findAccount(accounts, name)
+}
+
+func findAccount(accounts []params.Config, name string) (params.Config, error) {
+	if name == "" {
+		return accounts[0], nil
+	}
+	for _, cfg := range accounts {
+		if cfg.Account == name {
+			return cfg, nil
+		}
+	}
+	return params.Config{}, apperrors.New(apperrors.Config, "кабинет %q не найден", name)
+}
+
+func newRunID() string {
+	id, _ := uuid.NewV4()
+	return id.String()
+}
+
+// Process один проход: загрузка новых отзывов, классификация, карантин и снятие с карантина
+func (p *Pipeline) Process(ctx context.Context) RunSummary {
+	chat, api := p.chat, p.api
+	summary := RunSummary{RunID: newRunID(), Account: p.account, StartedAt: time.Now()}
+	logger := slog.With("run_id", summary.RunID, "account", p.account, "marketplace", p.api.Name(), "dry_run", p.dryRun)
+	// в режиме dry-run время последнего отзыва не сохраняется, чтобы настоящий запуск обработал те же отзывы
+	reviews, err := p.loadNewReviews(ctx, !p.dryRun)
+	if err != nil {
+		summary.addError(logger, "ошибка при загрузке отзывов", err)
+		// отзывы озона загружаются через кабинет продавца, и ошибка авторизации означает устаревшие cookies
+		if day := time.Now().Format(time.DateOnly); errors.Is(err, apperrors.ErrAuth) && p.api.Name() == marketplace.Ozon &&
+			p.cookiesNotifiedDay != day {
+			p.cookiesNotifiedDay = day
+			p.notify(ctx, logger, notify.Event{Type: notify.EventCookiesExpired, Details: err.Error()})
+		}
+	} else {
+		p.cookiesNotifiedDay = ""
+	}
+	summary.Reviews = len(reviews)
+	metrics.ReviewsFetched.Add(float64(len(reviews)))
+	// вопросы о браке учитываются в политике карантина наравне с отрицательными отзывами
+	questions, err := p.loadNewQuestions(ctx, !p.dryRun)
+	if err != nil {
+		summary.addError(logger, "ошибка при загрузке вопросов покупателей", err)
+	}
+	summary.Questions = len(questions)
+	metrics.QuestionsFetched.Add(float64(len(questions)))
+	reviews = append(reviews, questions...)
+	reviewsToQuarantine := make(map[string][]ozon.Review, len(reviews))
+	labels := make(map[string]archive.Label, len(reviews))
+	verdicts := make(map[string]Verdict, len(reviews))
 	for _, review := range reviews {
-		fullReview := strings.Join([]string{review.Text.Negative, review.Text.Positive, review.Text.Comment}, " ")
-		isNegative, err := chat.isNegativeResponse(fullReview)
+		verdict, err := p.classify(ctx, *review)
+		isNegative := verdict.Negative
+		verdicts[review.ID] = verdict
 		if err != nil {
-			log.Printf("ошибка при обработке отзыва %v %v", review, err)
+			labels[review.ID] = archive.LabelError
+			summary.addError(logger, "ошибка при классификации отзыва", err, "review_id", review.ID)
+			if day := time.Now().Format(time.DateOnly); errors.Is(err, errTokenBudgetExceeded) && p.budgetNotifiedDay != day {
+				p.budgetNotifiedDay = day
+				p.notify(ctx, logger, notify.Event{Type: notify.EventTokenBudgetExceeded,
+					Details: fmt.Sprintf("Лимит %d токенов в сутки", chat.tokenBudget)})
+			}
+		} else if isNegative {
+			labels[review.ID] = archive.LabelNegative
+		} else {
+			labels[review.ID] = archive.LabelPositive
+		}
+		// отзывы, которые не удалось классифицировать, не учитываются
+		if err == nil {
+			metrics.ReviewsClassified.Inc(string(labels[review.ID]), verdict.Path)
 		}
 		if isNegative {
-			log.Printf("%v добавлен в список для обновления цен", review)
-			idsToQuarantine[review.Product.OfferID] = true
+			if review.Source == ozon.SourceQuestion {
+				logger.Info("покупатель сообщает о браке в вопросе, товар будет помещен на карантин", "review_id", review.ID, "offer_id", review.Product.OfferID)
+			} else {
+				logger.Info("отрицательный отзыв, товар будет помещен на карантин", "review_id", review.ID, "offer_id", review.Product.OfferID)
+			}
+			summary.Negative++
+			offerID := review.Product.OfferID
+			reviewsToQuarantine[offerID] = append(reviewsToQuarantine[offerID], *review)
 		} else {
-			log.Printf("%v является положительным отзывом", review)
+			logger.Debug("положительный отзыв", "review_id", review.ID, "offer_id", review.Product.OfferID)
+		}
+	}
+	offerIdsByAction := p.decide(logger, reviewsToQuarantine)
+	decisions := make(map[string]archive.Decision, len(reviewsToQuarantine))
+	actions := make(map[string]ozon.QuarantineAction, len(reviewsToQuarantine))
+	for offerID := range reviewsToQuarantine {
+		decisions[offerID] = archive.DecisionBelowThreshold
+	}
+	for action, offerIds := range offerIdsByAction {
+		for _, offerID := range offerIds {
+			actions[offerID] = action
+		}
+	}
+	if p.dryRun {
+		for action, offerIds := range offerIdsByAction {
+			logger.Info("товары были бы помещены на карантин", "action", action, "offer_ids", offerIds)
+			for _, offerID := range offerIds {
+				decisions[offerID] = archive.DecisionDryRun
+			}
+		}
+		p.archiveReviews(&summary, logger, reviews, labels, verdicts, decisions, actions)
+		summary.FinishedAt = time.Now()
+		return summary
+	}
+	if p.approvals != nil {
+		// в режиме подтверждения товары попадают на карантин только после решения оператора
+		if err := p.requestApproval(ctx, logger, summary.RunID, offerIdsByAction, reviewsToQuarantine); err != nil {
+			summary.addError(logger, "ошибка при постановке товаров в очередь подтверждения", err)
+		}
+		for _, offerIds := range offerIdsByAction {
+			for _, offerID := range offerIds {
+				decisions[offerID] = archive.DecisionPending
+			}
 		}
+	} else {
+		for offerID, decision := range p.quarantine(ctx, logger, &summary, offerIdsByAction, reviewsToQuarantine) {
+			decisions[offerID] = decision
+		}
+	}
+	p.archiveReviews(&summary, logger, reviews, labels, verdicts, decisions, actions)
+	p.expireApprovals(&summary, logger)
+	released, err := api.ReleaseDue(ctx)
+	if len(released) > 0 {
+		p.notify(ctx, logger, notify.Event{Type: notify.EventReleased, OfferIDs: released, Details: "Срок карантина истек"})
+	}
+	if err != nil {
+		summary.addError(logger, "ошибка при снятии с карантина", err)
+		p.notify(ctx, logger, notify.Event{Type: notify.EventPriceChangeFailed, Details: err.Error()})
+	}
+	mismatches, err := api.CheckJournal(ctx)
+	if err != nil {
+		summary.addError(logger, "ошибка при сверке цен с журналом", err)
+	}
+	var mismatched []string
+	for _, m := range mismatches {
+		logger.Warn("цена на площадке отличается от журнала",
+			"offer_id", m.OfferID, "expected", m.Expected, "actual", m.Actual, "found", m.Found)
+		mismatched = append(mismatched, m.OfferID)
 	}
-	offerIds := make([]string, 0, len(idsToQuarantine))
-	for id := range idsToQuarantine {
-		offerIds = append(offerIds, id)
+	if len(mismatched) > 0 {
+		p.notify(ctx, logger, notify.Event{Type: notify.EventPriceChangeFailed, OfferIDs: mismatched,
+			Details: "Цена на площадке отличается от установленной"})
 	}
+	summary.FinishedAt = time.Now()
+	return summary
+}
+
+// quarantine помещает товары на карантин и возвращает решение по каждому товару
+func (p *Pipeline) quarantine(ctx context.Context, logger *slog.Logger, summary *RunSummary,
+	offerIdsByAction map[ozon.QuarantineAction][]string, reviews map[string][]ozon.Review) map[string]archive.Decision {
+	decisions := make(map[string]archive.Decision)
 	chunkSize := 1000
-	for i := 0; i < len(offerIds); i += chunkSize {
-		end := min(i+chunkSize, len(offerIds))
-		if err := api.PlaceToQuarantine(offerIds[i:end]); err != nil {
-			log.Printf("ошибка при обновлении цен %v", err)
+	for action, offerIds := range offerIdsByAction {
+		for i := 0; i < len(offerIds); i += chunkSize {
+			chunk := offerIds[i:min(i+chunkSize, len(offerIds))]
+			quarantined, err := p.api.Quarantine(ctx, chunk, action, reviews)
+			rest := missing(chunk, quarantined)
+			if err != nil {
+				summary.addError(logger, "ошибка при помещении товаров на карантин", err, "action", action)
+				if action == ozon.ActionPrice {
+					p.notify(ctx, logger, notify.Event{Type: notify.EventPriceChangeFailed,
+						OfferIDs: rest, Details: err.Error()})
+				}
+			}
+			if len(quarantined) > 0 {
+				summary.Quarantined = append(summary.Quarantined, quarantined...)
+				p.notify(ctx, logger, notify.Event{Type: notify.EventQuarantined,
+					OfferIDs: quarantined, Details: "Действие: " + string(action)})
+			}
+			for _, offerID := range quarantined {
+				decisions[offerID] = archive.DecisionQuarantined
+			}
+			// без ошибки не помещенные на карантин товары пропущены, а не упали
+			decision := archive.DecisionSkipped
+			if err != nil {
+				decision = archive.DecisionFailed
+			} else if len(rest) > 0 {
+				summary.Skipped = append(summary.Skipped, rest...)
+				logger.Info("товары пропущены: уже на карантине или не найдены на площадке", "action", action, "offer_ids", rest)
+			}
+			for _, offerID := range rest {
+				decisions[offerID] = decision
+			}
 		}
 	}
+	return decisions
+}
+
+// missing offer_id из offerIDs, которых нет в present
+func missing(offerIDs, present []string) []string {
+	found := make(map[string]bool, len(present))
+	for _, offerID := range present {
+		found[offerID] = true
+	}
+	res := make([]string, 0)
+	for _, offerID := range offerIDs {
+		if !found[offerID] {
+			res = append(res, offerID)
+		}
+	}
+	return res
+}
+
+// notify отправляет уведомление. Ошибка доставки только логируется, чтобы не прерывать проход
+func (p *Pipeline) notify(ctx context.Context, logger *slog.Logger, event notify.Event) {
+	event.Account = p.account
+	if err := p.notifier.Notify(ctx, event); err != nil {
+		logger.Warn("не удалось отправить уведомление", "event", event.Type, "error", err)
+	}
+}
+
+// archiveReviews сохраняет отзывы прохода в архив вместе с меткой и решением по товару
+func (p *Pipeline) archiveReviews(summary *RunSummary, logger *slog.Logger, reviews []*ozon.Review,
+	labels map[string]archive.Label, verdicts map[string]Verdict,
+	decisions map[string]archive.Decision, actions map[string]ozon.QuarantineAction) {
+	records := make([]archive.Record, 0, len(reviews))
+	for _, review := range reviews {
+		offerID := review.Product.OfferID
+		record := archive.Record{
+			Review:  *review,
+			Account: p.account,
+			RunID:   summary.RunID,
+			Label:   labels[review.ID],
+			Verdict: verdicts[review.ID].Answer,
+			Reason:  verdicts[review.ID].Reason,
+		}
+		if record.Label == archive.LabelNegative {
+			record.Decision = decisions[offerID]
+			record.Action = actions[offerID]
+			if quarantine, ok := p.api.QuarantineRecord(offerID); ok && record.Decision == archive.DecisionQuarantined {
+				record.PriceBefore = quarantine.Price.Price
+				record.PriceAfter = quarantine.QuarantinePrice
+			}
+		}
+		records = append(records, record)
+	}
+	if err := p.archive.Put(records); err != nil {
+		summary.addError(logger, "ошибка при сохранении отзывов в архив", err)
+	}
+}
+
+// decide распределяет товары с достаточным числом отрицательных отзывов по действиям карантина
+func (p *Pipeline) decide(logger *slog.Logger, negativeByOffer map[string][]ozon.Review) map[ozon.QuarantineAction][]string {
+	offerIdsByAction := make(map[ozon.QuarantineAction][]string)
+	for id, negative := range negativeByOffer {
+		if len(negative) < p.minNegative {
+			logger.Info("недостаточно отрицательных отзывов для карантина", "offer_id", id, "negative", len(negative))
+			continue
+		}
+		action := p.policy.ActionFor(id, ozon.ComplaintCategories(negative))
+		offerIdsByAction[action] = append(offerIdsByAction[action], id)
+	}
+	return offerIdsByAction
+}
+
+// classify классифицирует отзыв или вопрос покупателя промптом, подходящим для источника.
+// Текст отзыва в лог не пишется, только его идентификатор
+func (p *Pipeline) classify(ctx context.Context, review ozon.Review) (Verdict, error) {
+	classify := p.chat.classify
+	if review.Source == ozon.SourceQuestion {
+		classify = p.chat.classifyQuestion
+	}
+	verdict, err := classify(ctx, reviewText(review))
+	if err == nil {
+		slog.Debug("ответ модели", "review_id", review.ID, "negative", verdict.Negative, "path", verdict.Path)
+	}
+	return verdict, err
+}
+
+// reviewText текст отзыва, который отправляется на классификацию
+func reviewText(review ozon.Review) string {
+	return strings.Join([]string{review.Text.Negative, review.Text.Positive, review.Text.Comment}, " ")
 }
 
 // TODO better write after all processing is done. save last processed time at the and of main. or take the time of last successful price change
-func loadNewReviews(api *ozon.Api) []*ozon.Review {
-	start, err := os.ReadFile("start.txt")
+func (p *Pipeline) loadNewReviews(ctx context.Context, saveWatermark bool) ([]*ozon.Review, error) {
+	t, err := readWatermark(p.watermarkPath)
 	if err != nil {
-		log.Fatalf("failed to read start time from file")
+		return nil, err
+	}
+	reviews, nextStart, fetchErr := p.api.GetReviewsTillTime(ctx, t)
+	if !saveWatermark {
+		return reviews, fetchErr
+	}
+	if err := os.WriteFile(p.watermarkPath, []byte(nextStart.Format(time.RFC3339Nano)), 0644); err != nil {
+		return nil, fmt.Errorf("failed to save next start time in file: %w", err)
+	}
+	metrics.SetWatermark(p.account, nextStart)
+	return reviews, fetchErr
+}
+
+// loadNewQuestions загружает вопросы покупателей после времени последнего обработанного вопроса в виде отзывов.
+// При первом запуске файла со временем еще нет, и обрабатываются только вопросы, заданные после запуска
+func (p *Pipeline) loadNewQuestions(ctx context.Context, saveWatermark bool) ([]*ozon.Review, error) {
+	if p.questions == nil {
+		return nil, nil
 	}
-	t, _ := time.Parse(time.RFC3339Nano, string(start))
-	reviews, nextStart := api.GetReviewsTillTime(t)
-	if err := os.WriteFile("start.txt", []byte(nextStart.Format(time.RFC3339Nano)), 0644); err != nil {
-		log.Fatal("failed to save next start time in file")
+	t, err := readWatermark(p.questionsWatermarkPath)
+	if errors.Is(err, os.ErrNotExist) {
+		t = time.Now()
+		slog.Info("время последнего вопроса не сохранено, загружаются только новые вопросы",
+			"account", p.account, "path", p.questionsWatermarkPath)
+	} else if err != nil {
+		return nil, err
 	}
-	return reviews
+	questions, nextStart, fetchErr := p.questions.GetQuestionsTillTime(ctx, t)
+	reviews := make([]*ozon.Review, 0, len(questions))
+	for _, question := range questions {
+		review := question.Review()
+		reviews = append(reviews, &review)
+	}
+	if !saveWatermark {
+		return reviews, fetchErr
+	}
+	if err := os.WriteFile(p.questionsWatermarkPath, []byte(nextStart.Format(time.RFC3339Nano)), 0644); err != nil {
+		return nil, fmt.Errorf("не удалось сохранить время последнего вопроса: %w", err)
+	}
+	return reviews, fetchErr
+}
+
+// readWatermark время последнего обработанного отзыва
+func readWatermark(path string) (time.Time, error) {
+	start, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, apperrors.Wrap(apperrors.Config, err, "failed to read start time from file")
+	}
+	t, _ := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(start)))
+	return t, nil
 }
 
-func (chat *ChatClient) isNegativeResponse(userResponse string) (bool, error) {
-	chat.updateAccessTokenIfNecessary()
+// Verdict результат классификации отзыва
+type Verdict struct {
+	Negative bool `json:"negative"`
+	// Answer ответ модели как есть
+	Answer string `json:"answer"`
+	// Reason пояснение, если модель добавила его после класса
+	Reason string `json:"reason,omitempty"`
+	// Path откуда получен ответ: pathLLM или pathCache
+	Path string `json:"path,omitempty"`
+}
+
+const (
+	// pathLLM ответ получен от модели
+	pathLLM = "llm"
+	// pathCache ответ модели на такой же текст, полученный ранее в этот день
+	pathCache = "cache"
+)
+
+const (
+	// negativeClass класс отзыва, из-за которого товар попадает на карантин
+	negativeClass = "отрицательный"
+	// defectClass класс вопроса, в котором покупатель сообщает о браке
+	defectClass = "дефект"
+)
+
+// parseVerdict разбирает ответ модели: первое слово класс, остальное пояснение.
+// Negative выставляется, если класс совпадает с negative
+func parseVerdict(answer, negative string) Verdict {
+	answer = strings.TrimSpace(answer)
+	isSeparator := func(r rune) bool { return unicode.IsSpace(r) || unicode.IsPunct(r) }
+	class, reason := answer, ""
+	if i := strings.IndexFunc(answer, isSeparator); i >= 0 {
+		class, reason = answer[:i], strings.TrimLeftFunc(answer[i:], isSeparator)
+	}
+	return Verdict{
+		Negative: strings.ToLower(class) == negative,
+		Answer:   answer,
+		Reason:   reason,
+	}
+}
+
+// classify отправляет текст отзыва модели и разбирает ее ответ
+func (chat *ChatClient) classify(ctx context.Context, userResponse string) (Verdict, error) {
+	return chat.complete(ctx, chat.config.Prompt, userResponse, negativeClass)
+}
+
+// classifyQuestion отправляет модели вопрос покупателя. Negative означает, что покупатель сообщает о браке
+func (chat *ChatClient) classifyQuestion(ctx context.Context, question string) (Verdict, error) {
+	return chat.complete(ctx, chat.config.QuestionPrompt, question, defectClass)
+}
+
+// complete отправляет текст модели с системным промптом prompt и разбирает ее ответ
+func (chat *ChatClient) complete(ctx context.Context, prompt, userResponse, negative string) (Verdict, error) {
+	if day := time.Now().Format(time.DateOnly); day != chat.budgetDay {
+		chat.budgetDay, chat.usedTokens = day, 0
+		chat.verdicts = make(map[string]Verdict)
+	}
+	key := prompt + "\x00" + userResponse
+	if verdict, ok := chat.verdicts[key]; ok {
+		verdict.Path = pathCache
+		return verdict, nil
+	}
+	if chat.tokenBudget > 0 && chat.usedTokens >= chat.tokenBudget {
+		return Verdict{}, errTokenBudgetExceeded
+	}
+	if err := chat.updateAccessTokenIfNecessary(ctx); err != nil {
+		return Verdict{}, err
+	}
+	ctx, cancel := context.WithTimeout(metadata.NewOutgoingContext(ctx, chat.md), chat.timeout)
+	defer cancel()
 	request := &pb.ChatRequest{
-		Model: "GigaChat",
+		Model: chat.config.Model,
 		Messages: []*pb.Message{
 			{
 				Role:    "system",
-				Content: chat.args[params.GIGACHAT_PROMPT],
+				Content: prompt,
 			},
 			{
 				Role:    "user",
@@ -118,16 +721,22 @@ func (chat *ChatClient) isNegativeResponse(userResponse string) (bool, error) {
 			},
 		},
 	}
-	response, err := chat.client.Chat(chat.ctx, request)
+	response, err := chat.client.Chat(ctx, request)
 	if err != nil {
-		return false, fmt.Errorf("Ошибка при вызове метода Chat: %v", err)
+		return Verdict{}, grpcError(err, "ошибка при вызове метода Chat")
+	}
+	if response.Usage != nil {
+		metrics.GigachatTokens.Add(float64(response.Usage.PromptTokens), "prompt")
+		metrics.GigachatTokens.Add(float64(response.Usage.CompletionTokens), "completion")
+		chat.usedTokens += int(response.Usage.TotalTokens)
 	}
 	if len(response.Alternatives) < 1 {
-		return false, fmt.Errorf("не получен ответ от модели")
+		return Verdict{}, apperrors.New(apperrors.Transport, "не получен ответ от модели")
 	}
-	class := response.Alternatives[0].Message.Content
-	log.Printf("prompt:%s\nотзыв '%s' ответы: %v", chat.args[params.GIGACHAT_PROMPT], userResponse, response.Alternatives)
-	return strings.ToLower(class) == "отрицательный", nil
+	verdict := parseVerdict(response.Alternatives[0].Message.Content, negative)
+	verdict.Path = pathLLM
+	chat.verdicts[key] = verdict
+	return verdict, nil
 }
 
 type AuthResponse struct {
@@ -135,27 +744,42 @@ type AuthResponse struct {
 	ExpiresAt   int64  `json:"expires_at"`   // Время истечения токена в миллисекундах
 }
 
-func (chat *ChatClient) updateAccessTokenIfNecessary() {
+// grpcError ошибка вызова GigaChat с категорией по коду gRPC
+func grpcError(err error, msg string) error {
+	kinds := map[codes.Code]apperrors.Kind{
+		codes.Unauthenticated:   apperrors.Auth,
+		codes.PermissionDenied:  apperrors.Auth,
+		codes.ResourceExhausted: apperrors.RateLimit,
+		codes.InvalidArgument:   apperrors.Validation,
+		codes.Unavailable:       apperrors.Transport,
+		codes.DeadlineExceeded:  apperrors.Transport,
+	}
+	return apperrors.Wrap(kinds[status.Code(err)], err, msg)
+}
+
+func (chat *ChatClient) updateAccessTokenIfNecessary(ctx context.Context) error {
 	if time.Now().Before(chat.expiresAt.Add(-time.Minute)) {
-		return
+		return nil
 	}
-	log.Println("обновляю access токен")
+	slog.Info("обновляю access токен")
 	// URL для запроса
-	apiURL := "https://ngw.devices.sberbank.ru:9443/api/v2/oauth"
+	apiURL := chat.config.AuthURL
 	// Создаем данные для запроса
 	data := url.Values{}
 	data.Set("scope", "GIGACHAT_API_PERS")
 	// Создаем новый HTTP-запрос
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBufferString(data.Encode()))
+	ctx, cancel := context.WithTimeout(ctx, chat.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBufferString(data.Encode()))
 	if err != nil {
-		log.Fatalf("Ошибка при создании запроса: %v", err)
+		return fmt.Errorf("ошибка при создании запроса: %w", err)
 	}
 	// Устанавливаем заголовки
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "application/json")
 	id, _ := uuid.NewV4()
 	req.Header.Set("RqUID", id.String())
-	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", chat.args[params.GIGACHAT_AUTH_DATA]))
+	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", chat.config.AuthData))
 	// Отправляем запрос
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
@@ -163,18 +787,19 @@ func (chat *ChatClient) updateAccessTokenIfNecessary() {
 	client := &http.Client{Transport: tr}
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Fatalf("Ошибка при отправке запроса: %v", err)
+		return apperrors.Wrap(apperrors.Transport, err, "ошибка при получении access токена")
 	}
 	defer resp.Body.Close()
 	// Проверяем статус-код ответа
 	if resp.StatusCode != http.StatusOK {
-		log.Fatalf("Ошибка: статус-код %d %v", resp.StatusCode, err)
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("ошибка при получении access токена: %w", apperrors.FromStatus(resp.StatusCode, string(body)))
 	}
 	var authResponse AuthResponse
 	if err := json.NewDecoder(resp.Body).Decode(&authResponse); err != nil {
-		log.Fatalf("Ошибка декодирования %v", err)
+		return fmt.Errorf("ошибка декодирования access токена: %w", err)
 	}
 	chat.expiresAt = time.UnixMilli(authResponse.ExpiresAt)
-	md := metadata.Pairs("Authorization", "Bearer "+authResponse.AccessToken)
-	chat.ctx = metadata.NewOutgoingContext(chat.ctx, md)
+	chat.md = metadata.Pairs("Authorization", "Bearer "+authResponse.AccessToken)
+	return nil
 }
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"PriceGuardian/archive"
+	pb "PriceGuardian/gigachat"
+	"PriceGuardian/marketplace"
+	"PriceGuardian/notify"
+	"PriceGuardian/ozon"
+	"PriceGuardian/params"
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// fakeChat отвечает answers[text] и считает обращения к модели
+type fakeChat struct {
+	pb.ChatServiceClient
+	answers map[string]string
+	calls   int
+}
+
+func (chat *fakeChat) Chat(ctx context.Context, in *pb.ChatRequest, opts ...grpc.CallOption) (*pb.ChatResponse, error) {
+	chat.calls++
+	answer, ok := chat.answers[in.Messages[1].Content]
+	if !ok {
+		return nil, errors.New("модель недоступна")
+	}
+	return &pb.ChatResponse{Alternatives: []*pb.Alternative{{Message: &pb.Message{Content: answer}}}}, nil
+}
+
+func newTestChat(answers map[string]string) (*ChatClient, *fakeChat) {
+	client := &fakeChat{answers: answers}
+	return &ChatClient{client: client, timeout: time.Second,
+		expiresAt: time.Now().Add(time.Hour)}, client
+}
+
+func TestParseVerdict(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   Verdict
+	}{
+		{"отрицательный", Verdict{Negative: true, Answer: "отрицательный"}},
+		{" Отрицательный. Товар пришел сломанным ", Verdict{Negative: true, Answer: "Отрицательный. Товар пришел сломанным", Reason: "Товар пришел сломанным"}},
+		{"положительный", Verdict{Answer: "положительный"}},
+		{"отрицательныйй", Verdict{Answer: "отрицательныйй"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.answer, func(t *testing.T) {
+			if got := parseVerdict(tt.answer, negativeClass); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("получено %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyPath(t *testing.T) {
+	chat, client := newTestChat(map[string]string{"сломан": "отрицательный", "отлично": "положительный"})
+	tests := []struct {
+		text      string
+		wantPath  string
+		wantErr   bool
+		wantCalls int
+	}{
+		{"сломан", pathLLM, false, 1},
+		{"отлично", pathLLM, false, 2},
+		{"сломан", pathCache, false, 2},
+		{"ошибка", "", true, 3},
+		{"ошибка", "", true, 4},
+	}
+	for _, tt := range tests {
+		verdict, err := chat.classify(context.Background(), tt.text)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: ошибка %v", tt.text, err)
+		}
+		if verdict.Path != tt.wantPath || client.calls != tt.wantCalls {
+			t.Errorf("%s: путь %q, обращений %d, ожидалось %q и %d", tt.text, verdict.Path, client.calls, tt.wantPath, tt.wantCalls)
+		}
+	}
+	chat.tokenBudget, chat.usedTokens = 1, 1
+	if _, err := chat.classify(context.Background(), "отлично"); err != nil {
+		t.Errorf("ответ из кэша не должен зависеть от бюджета токенов: %v", err)
+	}
+}
+
+// fakeMarket площадка, цены и штрихкоды которой хранятся в памяти
+type fakeMarket struct {
+	prices   map[string]ozon.Price
+	barcodes map[string][]string
+}
+
+func (market *fakeMarket) Name() string {
+	return "fake"
+}
+
+func (market *fakeMarket) GetReviewsTillTime(ctx context.Context, startTime time.Time) ([]*ozon.Review, time.Time, error) {
+	return nil, startTime, nil
+}
+
+func (market *fakeMarket) GetPrices(ctx context.Context, offerIDs []string) (map[string]ozon.Price, error) {
+	res := make(map[string]ozon.Price)
+	for _, offerID := range offerIDs {
+		if price, ok := market.prices[offerID]; ok {
+			res[offerID] = price
+		}
+	}
+	return res, nil
+}
+
+func (market *fakeMarket) SetPrices(ctx context.Context, prices map[string]ozon.Price) ([]string, error) {
+	var updated []string
+	for offerID, price := range prices {
+		if _, ok := market.prices[offerID]; ok {
+			market.prices[offerID] = price
+			updated = append(updated, offerID)
+		}
+	}
+	sort.Strings(updated)
+	return updated, nil
+}
+
+func (market *fakeMarket) Reply(ctx context.Context, review ozon.Review, text string) error {
+	return nil
+}
+
+func (market *fakeMarket) Barcodes(ctx context.Context) (map[string][]string, error) {
+	return market.barcodes, nil
+}
+
+// testAdapter площадка с карантином повышением цены, как у wildberries и маркета
+type testAdapter struct {
+	*fakeMarket
+	*marketplace.PriceGuard
+}
+
+// newTestPipeline обработчик кабинета account, на площадке которого есть товары offerIDs
+func newTestPipeline(t *testing.T, account string, offerIDs ...string) *Pipeline {
+	dir := t.TempDir()
+	cfg := params.DefaultConfig()
+	cfg.Account = account
+	cfg.Prices.JournalPath = filepath.Join(dir, "journal.json")
+	cfg.Quarantine.StatePath = filepath.Join(dir, "quarantine.json")
+	market := &fakeMarket{prices: make(map[string]ozon.Price)}
+	for _, offerID := range offerIDs {
+		market.prices[offerID] = ozon.Price{Price: 100, CurrencyCode: "RUB"}
+	}
+	guard, err := marketplace.NewPriceGuard(market, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reviewArchive, err := archive.Open(filepath.Join(dir, "reviews.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier, err := notify.New(cfg.Notify)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Pipeline{
+		account:  account,
+		api:      testAdapter{fakeMarket: market, PriceGuard: guard},
+		policy:   ozon.QuarantinePolicy{Default: ozon.ActionPrice},
+		archive:  reviewArchive,
+		notifier: notifier,
+	}
+}
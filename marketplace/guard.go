@@ -0,0 +1,230 @@
+package marketplace
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/ozon"
+	"PriceGuardian/params"
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// PriceGuard карантин повышением цены для площадок, у которых нет собственного ценового карантина.
+// В отличие от озона повышенная цена держится до конца карантина, после чего восстанавливается сохраненная
+type PriceGuard struct {
+	market      Marketplace
+	store       *ozon.QuarantineStore
+	journal     *ozon.Journal
+	duration    time.Duration
+	coefficient float64
+}
+
+func NewPriceGuard(market Marketplace, cfg params.Config) (*PriceGuard, error) {
+	guard := &PriceGuard{market: market, duration: cfg.Quarantine.Duration, coefficient: cfg.Quarantine.PriceCoefficient}
+	var err error
+	if guard.journal, err = ozon.LoadJournal(cfg.Prices.JournalPath, cfg.Prices.JournalRetention); err != nil {
+		return nil, err
+	}
+	if guard.store, err = ozon.LoadQuarantineStore(cfg.Quarantine.StatePath, cfg.Account); err != nil {
+		return nil, err
+	}
+	return guard, nil
+}
+
+func (guard *PriceGuard) Quarantines() []ozon.QuarantineRecord {
+	return guard.store.All()
+}
+
+func (guard *PriceGuard) QuarantineRecord(offerID string) (ozon.QuarantineRecord, bool) {
+	return guard.store.Get(offerID)
+}
+
+// Quarantine повышает цены товаров и запоминает исходные цены для восстановления
+func (guard *PriceGuard) Quarantine(ctx context.Context, offerIDs []string, action ozon.QuarantineAction, reviews map[string][]ozon.Review) ([]string, error) {
+	if action != ozon.ActionPrice {
+		return nil, apperrors.New(apperrors.Validation, "на площадке %s поддерживается только карантин ценой, получено %q",
+			guard.market.Name(), action)
+	}
+	// повторный карантин перезаписал бы сохраненные цены уже повышенными
+	fresh := make([]string, 0, len(offerIDs))
+	for _, offerID := range offerIDs {
+		if _, ok := guard.store.Get(offerID); ok {
+			slog.Info("товар уже находится на карантине", "offer_id", offerID)
+			continue
+		}
+		fresh = append(fresh, offerID)
+	}
+	if len(fresh) == 0 {
+		return nil, nil
+	}
+	prices, err := guard.market.GetPrices(ctx, fresh)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении цен %w", err)
+	}
+	raised := make(map[string]ozon.Price, len(prices))
+	for offerID, price := range prices {
+		raised[offerID] = ozon.QuarantinePrice(price, guard.coefficient)
+	}
+	if len(raised) == 0 {
+		return nil, apperrors.New(apperrors.Validation, "товары %v не найдены на площадке %s", fresh, guard.market.Name())
+	}
+	for _, offerID := range fresh {
+		if _, ok := prices[offerID]; !ok {
+			slog.Warn("товар не найден на площадке", "offer_id", offerID, "marketplace", guard.market.Name())
+		}
+	}
+	updated, err := guard.market.SetPrices(ctx, raised)
+	// цены, принятые до ошибки, нужно запомнить, чтобы их можно было восстановить
+	now := time.Now()
+	records := make([]ozon.QuarantineRecord, 0, len(updated))
+	journal := make(map[string]ozon.Price, len(updated))
+	for _, offerID := range updated {
+		records = append(records, ozon.QuarantineRecord{
+			OfferID:         offerID,
+			Action:          action,
+			Price:           prices[offerID],
+			QuarantinePrice: raised[offerID].Price,
+			Reviews:         reviews[offerID],
+			StartedAt:       now,
+			EndsAt:          now.Add(guard.duration),
+		})
+		journal[offerID] = raised[offerID]
+	}
+	if err := guard.journal.RecordPrices(journal); err != nil {
+		slog.Error("ошибка записи в журнал цен", "error", err)
+	}
+	if saveErr := guard.store.Add(records); saveErr != nil {
+		return nil, saveErr
+	}
+	if err != nil {
+		return updated, fmt.Errorf("ошибка при повышении цен %w", err)
+	}
+	if len(updated) < len(raised) {
+		return updated, fmt.Errorf("цены повышены только у %d из %d товаров", len(updated), len(raised))
+	}
+	return updated, nil
+}
+
+// Release восстанавливает сохраненные цены товаров и возвращает offer_id товаров, цены которых восстановлены.
+// При частичной ошибке товары с восстановленной ценой тоже снимаются с карантина
+func (guard *PriceGuard) Release(ctx context.Context, offerIDs []string) ([]string, error) {
+	prices := make(map[string]ozon.Price, len(offerIDs))
+	for _, offerID := range offerIDs {
+		record, ok := guard.store.Get(offerID)
+		if !ok {
+			slog.Warn("товар не находится на карантине", "offer_id", offerID)
+			continue
+		}
+		prices[offerID] = record.Price
+	}
+	if len(prices) == 0 {
+		return nil, nil
+	}
+	updated, err := guard.market.SetPrices(ctx, prices)
+	journal := make(map[string]ozon.Price, len(updated))
+	for _, offerID := range updated {
+		journal[offerID] = prices[offerID]
+	}
+	if err := guard.journal.RecordPrices(journal); err != nil {
+		slog.Error("ошибка записи в журнал цен", "error", err)
+	}
+	if saveErr := guard.store.Remove(updated); saveErr != nil {
+		return nil, saveErr
+	}
+	if err != nil {
+		return updated, fmt.Errorf("ошибка при снятии с карантина %w", err)
+	}
+	return updated, nil
+}
+
+// ReleaseDue снимает с карантина товары, срок карантина которых истек, и возвращает их offer_id
+func (guard *PriceGuard) ReleaseDue(ctx context.Context) ([]string, error) {
+	due := guard.store.Due(time.Now())
+	if len(due) == 0 {
+		return nil, nil
+	}
+	slog.Info("снимаю с карантина товары с истекшим сроком", "offers", len(due), "marketplace", guard.market.Name())
+	return guard.Release(ctx, due)
+}
+
+// CheckJournal сравнивает цены на площадке с ценами из журнала
+func (guard *PriceGuard) CheckJournal(ctx context.Context) ([]ozon.PriceMismatch, error) {
+	expected := guard.journal.Expected()
+	if len(expected) == 0 {
+		return nil, nil
+	}
+	offerIDs := make([]string, 0, len(expected))
+	for offerID := range expected {
+		offerIDs = append(offerIDs, offerID)
+	}
+	actual, err := guard.market.GetPrices(ctx, offerIDs)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении цен для сверки %w", err)
+	}
+	var mismatches []ozon.PriceMismatch
+	for offerID, want := range expected {
+		got, found := actual[offerID]
+		// площадка может округлять цены до рубля
+		if !found || math.Abs(got.Price-want) >= 1 {
+			mismatches = append(mismatches, ozon.PriceMismatch{OfferID: offerID, Expected: want, Actual: got.Price, Found: found})
+		}
+	}
+	return mismatches, nil
+}
+
+// Recover повторно отправляет цены из журнала, которые не совпадают с ценами на площадке,
+// и снимает с карантина товары с истекшим сроком. Возвращает расхождения, которые не удалось устранить.
+// Площадка может применять цены асинхронно, поэтому результат повторной отправки виден только при следующей сверке
+func (guard *PriceGuard) Recover(ctx context.Context) ([]ozon.PriceMismatch, error) {
+	mismatches, err := guard.CheckJournal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// восстанавливается полная цена из журнала, чтобы не сбросить зачеркнутую цену.
+	// Для записей без полной цены меняется только цена продажи текущей цены на площадке
+	states := guard.journal.States()
+	restore := make(map[string]ozon.Price, len(mismatches))
+	expected := make(map[string]float64)
+	var remaining []ozon.PriceMismatch
+	for _, m := range mismatches {
+		if !m.Found {
+			slog.Warn("товар из журнала не найден на площадке", "offer_id", m.OfferID)
+			remaining = append(remaining, m)
+			continue
+		}
+		if state, ok := states[m.OfferID]; ok {
+			restore[m.OfferID] = state
+			continue
+		}
+		expected[m.OfferID] = m.Expected
+	}
+	if len(expected) > 0 {
+		offerIDs := make([]string, 0, len(expected))
+		for offerID := range expected {
+			offerIDs = append(offerIDs, offerID)
+		}
+		current, err := guard.market.GetPrices(ctx, offerIDs)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при получении цен для восстановления %w", err)
+		}
+		for offerID, price := range current {
+			price.Price = expected[offerID]
+			if price.OldPrice != 0 && price.Price >= price.OldPrice {
+				price.OldPrice = 0
+			}
+			restore[offerID] = price
+		}
+	}
+	if len(restore) > 0 {
+		slog.Info("восстанавливаю цены из журнала", "offers", len(restore), "marketplace", guard.market.Name())
+		if _, err := guard.market.SetPrices(ctx, restore); err != nil {
+			return nil, fmt.Errorf("ошибка при восстановлении цен %w", err)
+		}
+	}
+	if _, err := guard.ReleaseDue(ctx); err != nil {
+		return remaining, err
+	}
+	return remaining, nil
+}
@@ -0,0 +1,141 @@
+package marketplace
+
+import (
+	"PriceGuardian/ozon"
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeMarket площадка, цены которой хранятся в памяти
+type fakeMarket struct {
+	prices map[string]ozon.Price
+}
+
+func (market *fakeMarket) Name() string {
+	return "fake"
+}
+
+func (market *fakeMarket) GetReviewsTillTime(ctx context.Context, startTime time.Time) ([]*ozon.Review, time.Time, error) {
+	return nil, startTime, nil
+}
+
+func (market *fakeMarket) GetPrices(ctx context.Context, offerIDs []string) (map[string]ozon.Price, error) {
+	res := make(map[string]ozon.Price)
+	for _, offerID := range offerIDs {
+		if price, ok := market.prices[offerID]; ok {
+			res[offerID] = price
+		}
+	}
+	return res, nil
+}
+
+func (market *fakeMarket) SetPrices(ctx context.Context, prices map[string]ozon.Price) ([]string, error) {
+	var updated []string
+	for offerID, price := range prices {
+		if _, ok := market.prices[offerID]; ok {
+			market.prices[offerID] = price
+			updated = append(updated, offerID)
+		}
+	}
+	return updated, nil
+}
+
+func (market *fakeMarket) Reply(ctx context.Context, review ozon.Review, text string) error {
+	return nil
+}
+
+func newTestGuard(t *testing.T, market *fakeMarket) *PriceGuard {
+	dir := t.TempDir()
+	journal, err := ozon.LoadJournal(filepath.Join(dir, "journal.json"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := ozon.LoadQuarantineStore(filepath.Join(dir, "quarantine.json"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &PriceGuard{market: market, store: store, journal: journal, duration: time.Hour, coefficient: 2}
+}
+
+func TestQuarantineAndRelease(t *testing.T) {
+	original := ozon.Price{Price: 900, OldPrice: 1200, CurrencyCode: "RUB"}
+	market := &fakeMarket{prices: map[string]ozon.Price{"a": original}}
+	guard := newTestGuard(t, market)
+	quarantined, err := guard.Quarantine(context.Background(), []string{"a", "missing"}, ozon.ActionPrice, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(quarantined, []string{"a"}) {
+		t.Errorf("на карантине %v, ожидалось [a]", quarantined)
+	}
+	if market.prices["a"].Price <= original.Price {
+		t.Errorf("цена не повышена: %+v", market.prices["a"])
+	}
+	released, err := guard.Release(context.Background(), []string{"a", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(released, []string{"a"}) {
+		t.Errorf("сняты с карантина %v, ожидалось [a]", released)
+	}
+	if got := market.prices["a"]; got != original {
+		t.Errorf("цена после карантина %+v, ожидалось %+v", got, original)
+	}
+	if got := guard.journal.States()["a"]; got != original {
+		t.Errorf("в журнале %+v, ожидалось %+v", got, original)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   map[string]ozon.Price
+		journal map[string]float64
+		current ozon.Price
+		want    ozon.Price
+	}{
+		{
+			name:    "полная цена из журнала",
+			state:   map[string]ozon.Price{"a": {Price: 900, OldPrice: 1200, CurrencyCode: "RUB"}},
+			current: ozon.Price{Price: 1800, CurrencyCode: "RUB"},
+			want:    ozon.Price{Price: 900, OldPrice: 1200, CurrencyCode: "RUB"},
+		},
+		{
+			name:    "в журнале только цена продажи",
+			journal: map[string]float64{"a": 900},
+			current: ozon.Price{Price: 1800, OldPrice: 1200, CurrencyCode: "RUB"},
+			want:    ozon.Price{Price: 900, OldPrice: 1200, CurrencyCode: "RUB"},
+		},
+		{
+			name:    "зачеркнутая цена не ниже цены продажи сбрасывается",
+			journal: map[string]float64{"a": 1500},
+			current: ozon.Price{Price: 1800, OldPrice: 1200, CurrencyCode: "RUB"},
+			want:    ozon.Price{Price: 1500, CurrencyCode: "RUB"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			market := &fakeMarket{prices: map[string]ozon.Price{"a": tt.current}}
+			guard := newTestGuard(t, market)
+			if err := guard.journal.RecordPrices(tt.state); err != nil {
+				t.Fatal(err)
+			}
+			if err := guard.journal.Record(tt.journal); err != nil {
+				t.Fatal(err)
+			}
+			remaining, err := guard.Recover(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(remaining) != 0 {
+				t.Errorf("остались расхождения %v", remaining)
+			}
+			if got := market.prices["a"]; got != tt.want {
+				t.Errorf("получено %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package marketplace
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/ozon"
+	"PriceGuardian/params"
+	"PriceGuardian/wildberries"
+	"PriceGuardian/yandex"
+	"context"
+	"time"
+)
+
+// Marketplace площадка, с которой загружаются отзывы и на которой меняются цены.
+// Отзывы и цены всех площадок приводятся к типам пакета ozon
+type Marketplace interface {
+	// Name название площадки для логов
+	Name() string
+	// GetReviewsTillTime загружает отзывы, опубликованные после startTime, и время самого нового из них.
+	// При ошибке возвращаются отзывы, полученные до нее, вместе с ошибкой
+	GetReviewsTillTime(ctx context.Context, startTime time.Time) ([]*ozon.Review, time.Time, error)
+	// GetPrices текущие цены товаров по offer_id. Товары, не найденные на площадке, в ответ не попадают
+	GetPrices(ctx context.Context, offerIDs []string) (map[string]ozon.Price, error)
+	// SetPrices устанавливает цены и возвращает offer_id товаров, цены которых приняты площадкой
+	SetPrices(ctx context.Context, prices map[string]ozon.Price) ([]string, error)
+	// Reply публикует ответ продавца на отзыв
+	Reply(ctx context.Context, review ozon.Review, text string) error
+}
+
+// Guard карантин товаров площадки с сохранением состояния для восстановления
+type Guard interface {
+	// Quarantine останавливает продажи товаров и возвращает offer_id товаров, помещенных на карантин.
+	// Товары, уже находящиеся на карантине или не найденные на площадке, пропускаются.
+	// reviews отзывы, из-за которых товар попал на карантин
+	Quarantine(ctx context.Context, offerIDs []string, action ozon.QuarantineAction, reviews map[string][]ozon.Review) ([]string, error)
+	// Release снимает товары с карантина и возвращает offer_id снятых. Товары не на карантине пропускаются
+	Release(ctx context.Context, offerIDs []string) ([]string, error)
+	// ReleaseDue снимает с карантина товары с истекшим сроком и возвращает offer_id снятых
+	ReleaseDue(ctx context.Context) ([]string, error)
+	Quarantines() []ozon.QuarantineRecord
+	QuarantineRecord(offerID string) (ozon.QuarantineRecord, bool)
+	// CheckJournal сравнивает цены на площадке с ценами из журнала
+	CheckJournal(ctx context.Context) ([]ozon.PriceMismatch, error)
+	// Recover повторно отправляет цены из журнала и снимает с карантина товары с истекшим сроком
+	Recover(ctx context.Context) ([]ozon.PriceMismatch, error)
+}
+
+// Catalog каталог товаров кабинета для поиска одинаковых товаров в разных кабинетах
+type Catalog interface {
+	// Barcodes штрихкоды всех товаров кабинета по offer_id
+	Barcodes(ctx context.Context) (map[string][]string, error)
+}
+
+// Adapter площадка вместе с каталогом и карантином ее товаров
+type Adapter interface {
+	Marketplace
+	Catalog
+	Guard
+}
+
+// QuestionSource площадка, с которой загружаются вопросы покупателей о товарах
+type QuestionSource interface {
+	// GetQuestionsTillTime загружает вопросы, опубликованные после startTime, и время самого нового из них
+	GetQuestionsTillTime(ctx context.Context, startTime time.Time) ([]*ozon.Question, time.Time, error)
+}
+
+var (
+	_ Adapter        = (*ozon.Api)(nil)
+	_ QuestionSource = (*ozon.Api)(nil)
+)
+
+const (
+	Ozon        = "ozon"
+	Wildberries = "wildberries"
+	Yandex      = "yandex"
+)
+
+// catalogMarketplace площадка с каталогом, но без собственного карантина
+type catalogMarketplace interface {
+	Marketplace
+	Catalog
+}
+
+// priceAdapter площадка без собственного карантина, товары которой защищаются повышением цены
+type priceAdapter struct {
+	catalogMarketplace
+	*PriceGuard
+}
+
+// New адаптер площадки кабинета cfg
+func New(cfg params.Config) (Adapter, error) {
+	switch cfg.Marketplace {
+	case "", Ozon:
+		api, err := ozon.NewApi(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return api, nil
+	case Wildberries:
+		api := wildberries.NewApi(cfg.Wildberries)
+		guard, err := NewPriceGuard(api, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return priceAdapter{catalogMarketplace: api, PriceGuard: guard}, nil
+	case Yandex:
+		api := yandex.NewApi(cfg.Yandex)
+		guard, err := NewPriceGuard(api, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return priceAdapter{catalogMarketplace: api, PriceGuard: guard}, nil
+	default:
+		return nil, apperrors.New(apperrors.Config, "неизвестная площадка %q", cfg.Marketplace)
+	}
+}
+
+// OzonApi клиент озона для команд, которые используют возможности, доступные только на озоне
+func OzonApi(cfg params.Config) (*ozon.Api, error) {
+	if name := cfg.Marketplace; name != "" && name != Ozon {
+		return nil, apperrors.New(apperrors.Config, "команда доступна только для кабинетов озона, кабинет %q на площадке %s",
+			cfg.Account, name)
+	}
+	return ozon.NewApi(cfg)
+}
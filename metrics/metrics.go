@@ -0,0 +1,203 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metric семейство значений с одинаковым именем и набором меток
+type metric struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]float64
+	// labelValues значения меток по ключу из values
+	labelValues map[string][]string
+	// scrape если задана, вычисляет отдаваемое значение из сохраненного в момент запроса метрик
+	scrape func(value float64) float64
+}
+
+func (m *metric) key(labelValues []string) string {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("метрика %s ожидает %d меток, получено %d", m.name, len(m.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+func (m *metric) add(delta float64, labelValues []string) {
+	k := m.key(labelValues)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[k] += delta
+	m.labelValues[k] = labelValues
+}
+
+func (m *metric) set(value float64, labelValues []string) {
+	k := m.key(labelValues)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[k] = value
+	m.labelValues[k] = labelValues
+}
+
+func (m *metric) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+	keys := make([]string, 0, len(m.values))
+	for k := range m.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		value := m.values[k]
+		if m.scrape != nil {
+			value = m.scrape(value)
+		}
+		fmt.Fprintf(w, "%s%s %g\n", m.name, m.formatLabels(m.labelValues[k]), value)
+	}
+}
+
+func (m *metric) formatLabels(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(values))
+	for i, v := range values {
+		v = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+		pairs[i] = fmt.Sprintf(`%s="%s"`, m.labels[i], v)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []*metric
+)
+
+func register(name, help, kind string, labels []string) *metric {
+	m := &metric{
+		name:        name,
+		help:        help,
+		kind:        kind,
+		labels:      labels,
+		values:      make(map[string]float64),
+		labelValues: make(map[string][]string),
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, m)
+	return m
+}
+
+// Counter монотонно растущий счетчик
+type Counter struct{ m *metric }
+
+func NewCounter(name, help string, labels ...string) Counter {
+	return Counter{register(name, help, "counter", labels)}
+}
+
+func (c Counter) Inc(labelValues ...string) {
+	c.m.add(1, labelValues)
+}
+
+func (c Counter) Add(delta float64, labelValues ...string) {
+	c.m.add(delta, labelValues)
+}
+
+// Gauge значение, которое может как расти, так и уменьшаться
+type Gauge struct{ m *metric }
+
+func NewGauge(name, help string, labels ...string) Gauge {
+	return Gauge{register(name, help, "gauge", labels)}
+}
+
+func (g Gauge) Set(value float64, labelValues ...string) {
+	g.m.set(value, labelValues)
+}
+
+// NewAgeGauge gauge, которому передается время в секундах unix, а отдается его возраст на момент запроса метрик
+func NewAgeGauge(name, help string, labels ...string) Gauge {
+	g := NewGauge(name, help, labels...)
+	g.m.scrape = func(value float64) float64 {
+		return float64(time.Now().UnixNano())/1e9 - value
+	}
+	return g
+}
+
+// Handler отдает все метрики в текстовом формате prometheus
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		for _, m := range registry {
+			m.write(w)
+		}
+	})
+}
+
+var (
+	ReviewsFetched = NewCounter("priceguardian_reviews_fetched_total",
+		"Количество загруженных отзывов")
+	QuestionsFetched = NewCounter("priceguardian_questions_fetched_total",
+		"Количество загруженных вопросов покупателей")
+	ReviewsClassified = NewCounter("priceguardian_reviews_classified_total",
+		"Количество классифицированных отзывов", "label", "path")
+	GigachatTokens = NewCounter("priceguardian_gigachat_tokens_total",
+		"Количество токенов, потраченных GigaChat", "kind")
+	OzonAPICalls = NewCounter("priceguardian_ozon_api_calls_total",
+		"Количество запросов к API озона", "endpoint", "status")
+	WildberriesAPICalls = NewCounter("priceguardian_wildberries_api_calls_total",
+		"Количество запросов к API wildberries", "endpoint", "status")
+	YandexAPICalls = NewCounter("priceguardian_yandex_api_calls_total",
+		"Количество запросов к Партнерскому API Яндекс Маркета", "endpoint", "status")
+	PriceChanges = NewCounter("priceguardian_price_changes_total",
+		"Количество изменений цен", "result")
+	QuarantinedOffers = NewGauge("priceguardian_quarantined_offers",
+		"Количество товаров на карантине", "account")
+	ReviewWatermark = NewGauge("priceguardian_review_watermark_timestamp_seconds",
+		"Время последнего обработанного отзыва в секундах unix", "account")
+	ReviewWatermarkAge = NewAgeGauge("priceguardian_review_watermark_age_seconds",
+		"Возраст времени последнего обработанного отзыва на момент запроса метрик", "account")
+)
+
+// Transport считает запросы к API площадки по пути и коду ответа
+type Transport struct {
+	Base http.RoundTripper
+	// Calls счетчик запросов, по умолчанию OzonAPICalls
+	Calls *Counter
+}
+
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	status := "error"
+	if err == nil {
+		status = fmt.Sprint(resp.StatusCode)
+	}
+	calls := t.Calls
+	if calls == nil {
+		calls = &OzonAPICalls
+	}
+	calls.Inc(req.URL.Path, status)
+	return resp, err
+}
+
+// SetWatermark обновляет время последнего обработанного отзыва. Возраст считается при запросе метрик,
+// поэтому растет и между проходами
+func SetWatermark(account string, t time.Time) {
+	ts := float64(t.UnixNano()) / 1e9
+	ReviewWatermark.Set(ts, account)
+	ReviewWatermarkAge.Set(ts, account)
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		gauge  Gauge
+		value  float64
+		labels []string
+		want   string
+	}{
+		{
+			name:   "gauge с меткой",
+			gauge:  NewGauge("test_gauge", "Тестовый gauge", "account"),
+			value:  3,
+			labels: []string{`sh"op`},
+			want:   "# HELP test_gauge Тестовый gauge\n# TYPE test_gauge gauge\ntest_gauge{account=\"sh\\\"op\"} 3\n",
+		},
+		{
+			name:   "возраст считается при запросе",
+			gauge:  NewAgeGauge("test_age", "Тестовый возраст"),
+			value:  float64(time.Now().Add(-time.Hour).Unix()),
+			labels: nil,
+			want:   "# HELP test_age Тестовый возраст\n# TYPE test_age gauge\ntest_age 3600\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.gauge.Set(tt.value, tt.labels...)
+			var b bytes.Buffer
+			tt.gauge.m.write(&b)
+			got := b.String()
+			if tt.gauge.m.scrape != nil {
+				// возраст округляется до секунд, чтобы тест не зависел от времени выполнения
+				var age float64
+				prefix := fmt.Sprintf("# HELP %s %s\n# TYPE %s gauge\n%s ", tt.gauge.m.name, tt.gauge.m.help, tt.gauge.m.name, tt.gauge.m.name)
+				if _, err := fmt.Sscanf(got[len(prefix):], "%g", &age); err != nil {
+					t.Fatalf("не удалось разобрать %q: %v", got, err)
+				}
+				got = fmt.Sprintf("%s%d\n", prefix, int(age))
+			}
+			if got != tt.want {
+				t.Errorf("получено %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
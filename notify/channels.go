@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"PriceGuardian/apperrors"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+)
+
+// Telegram отправляет уведомления через Bot API. baseURL можно заменить на локальную заглушку
+type Telegram struct {
+	client  *http.Client
+	baseURL string
+	token   string
+	chatID  string
+}
+
+// maxTelegramMessage ограничение Bot API на длину сообщения в символах
+const maxTelegramMessage = 4096
+
+func (telegram *Telegram) Notify(ctx context.Context, event Event) error {
+	text := []rune(event.Text())
+	if len(text) > maxTelegramMessage {
+		text = append(text[:maxTelegramMessage-1], '…')
+	}
+	body := map[string]string{"chat_id": telegram.chatID, "text": string(text)}
+	return postJSON(ctx, telegram.client, telegram.baseURL+"/bot"+telegram.token+"/sendMessage", body)
+}
+
+// Webhook отправляет событие как есть в JSON на произвольный адрес
+type Webhook struct {
+	client *http.Client
+	url    string
+}
+
+func (webhook *Webhook) Notify(ctx context.Context, event Event) error {
+	return postJSON(ctx, webhook.client, webhook.url, event)
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации уведомления %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("ошибка при подготовке запроса %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		// в тексте ошибки может оказаться адрес с токеном бота
+		return apperrors.New(apperrors.Transport, "ошибка при отправке уведомления")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return apperrors.FromStatus(resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// SMTP отправляет уведомления письмом. Если задан username, используется аутентификация PLAIN
+type SMTP struct {
+	addr     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func (s *SMTP) Notify(ctx context.Context, event Event) error {
+	var auth smtp.Auth
+	if s.username != "" {
+		host, _, err := net.SplitHostPort(s.addr)
+		if err != nil {
+			return apperrors.Wrap(apperrors.Config, err, "неверный адрес SMTP сервера")
+		}
+		auth = smtp.PlainAuth("", s.username, s.password, host)
+	}
+	headers := []string{
+		"From: " + s.from,
+		"To: " + strings.Join(s.to, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", "[PriceGuardian] "+event.Title()),
+		"MIME-Version: 1.0",
+	}
+	body := event.Text()
+	if event.HTML != "" {
+		headers = append(headers, "Content-Type: text/html; charset=utf-8")
+		body = event.HTML
+	} else {
+		headers = append(headers, "Content-Type: text/plain; charset=utf-8")
+	}
+	message := strings.Join(headers, "\r\n") + "\r\n\r\n" + strings.ReplaceAll(body, "\n", "\r\n") + "\r\n"
+	// net/smtp не принимает контекст, поэтому отмена учитывается только до начала отправки
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := smtp.SendMail(s.addr, auth, s.from, s.to, []byte(message)); err != nil {
+		return apperrors.Wrap(apperrors.Transport, err, "ошибка при отправке письма")
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package notify
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/params"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// EventType тип события, по которому выбираются каналы уведомлений
+type EventType string
+
+const (
+	EventQuarantined         EventType = "offer_quarantined"
+	EventReleased            EventType = "offer_released"
+	EventPriceChangeFailed   EventType = "price_change_failed"
+	EventCookiesExpired      EventType = "cookies_expired"
+	EventTokenBudgetExceeded EventType = "token_budget_exceeded"
+	EventDailyDigest         EventType = "daily_digest"
+	EventApprovalRequested   EventType = "approval_requested"
+)
+
+var eventTitles = map[EventType]string{
+	EventQuarantined:         "Товары помещены на карантин",
+	EventReleased:            "Товары сняты с карантина",
+	EventPriceChangeFailed:   "Не удалось изменить цены",
+	EventCookiesExpired:      "Cookies кабинета озон устарели",
+	EventTokenBudgetExceeded: "Израсходован суточный бюджет токенов GigaChat",
+	EventDailyDigest:         "Сводка отзывов за день",
+	EventApprovalRequested:   "Товары ожидают подтверждения карантина",
+}
+
+// Event событие для уведомления
+type Event struct {
+	Type     EventType `json:"type"`
+	Account  string    `json:"account,omitempty"`
+	OfferIDs []string  `json:"offer_ids,omitempty"`
+	// Details подробности, например действие карантина или текст ошибки
+	Details string `json:"details,omitempty"`
+	// Markdown и HTML отчет, приложенный к событию, например ежедневная сводка
+	Markdown string    `json:"markdown,omitempty"`
+	HTML     string    `json:"html,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Title заголовок события для людей
+func (event Event) Title() string {
+	title := eventTitles[event.Type]
+	if title == "" {
+		title = string(event.Type)
+	}
+	if event.Account != "" {
+		title += " (" + event.Account + ")"
+	}
+	return title
+}
+
+// Text текст уведомления для людей
+func (event Event) Text() string {
+	lines := []string{event.Title()}
+	if len(event.OfferIDs) > 0 {
+		lines = append(lines, "Товары: "+strings.Join(event.OfferIDs, ", "))
+	}
+	if event.Details != "" {
+		lines = append(lines, event.Details)
+	}
+	if event.Markdown != "" {
+		lines = append(lines, "", event.Markdown)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Notifier канал доставки уведомлений
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// route канал и события, которые в него отправляются. Пустой список событий означает все события
+type route struct {
+	name     string
+	notifier Notifier
+	events   map[EventType]bool
+}
+
+// Router рассылает событие по каналам, подписанным на его тип
+type Router struct {
+	routes []route
+}
+
+// New создает маршрутизатор с каналами, включенными в параметрах. Без каналов уведомления никуда не отправляются
+func New(cfg params.NotifyConfig) (*Router, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	router := &Router{}
+	if cfg.Telegram.Token != "" {
+		telegram := &Telegram{
+			client:  client,
+			baseURL: strings.TrimSuffix(cfg.Telegram.URL, "/"),
+			token:   cfg.Telegram.Token,
+			chatID:  cfg.Telegram.ChatID,
+		}
+		if err := router.add("telegram", telegram, cfg.Telegram.Events); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Webhook.URL != "" {
+		webhook := &Webhook{client: client, url: cfg.Webhook.URL}
+		if err := router.add("webhook", webhook, cfg.Webhook.Events); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.SMTP.Addr != "" {
+		smtp := &SMTP{
+			addr:     cfg.SMTP.Addr,
+			username: cfg.SMTP.Username,
+			password: cfg.SMTP.Password,
+			from:     cfg.SMTP.From,
+			to:       splitList(cfg.SMTP.To),
+		}
+		if err := router.add("smtp", smtp, cfg.SMTP.Events); err != nil {
+			return nil, err
+		}
+	}
+	return router, nil
+}
+
+// Add подключает канал name для событий events. Без событий канал получает все события
+func (router *Router) Add(name string, notifier Notifier, events ...EventType) {
+	subscribed := make(map[EventType]bool, len(events))
+	for _, event := range events {
+		subscribed[event] = true
+	}
+	router.routes = append(router.routes, route{name: name, notifier: notifier, events: subscribed})
+}
+
+func (router *Router) add(name string, notifier Notifier, events string) error {
+	var types []EventType
+	for _, event := range splitList(events) {
+		if _, ok := eventTitles[EventType(event)]; !ok {
+			return apperrors.New(apperrors.Config, "неизвестное событие %q в настройках канала %s", event, name)
+		}
+		types = append(types, EventType(event))
+	}
+	router.Add(name, notifier, types...)
+	return nil
+}
+
+// Notify отправляет событие во все подписанные каналы. Ошибка одного канала не мешает отправке в остальные
+func (router *Router) Notify(ctx context.Context, event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	var errs []error
+	for _, route := range router.routes {
+		if len(route.events) > 0 && !route.events[event.Type] {
+			continue
+		}
+		if err := route.notifier.Notify(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("канал %s: %w", route.name, err))
+			continue
+		}
+		slog.Debug("уведомление отправлено", "channel", route.name, "event", event.Type)
+	}
+	return errors.Join(errs...)
+}
+
+func splitList(value string) []string {
+	var res []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
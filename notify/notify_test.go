@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/params"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// recorder канал, запоминающий полученные события
+type recorder struct {
+	events []EventType
+	err    error
+}
+
+func (recorder *recorder) Notify(ctx context.Context, event Event) error {
+	recorder.events = append(recorder.events, event.Type)
+	return recorder.err
+}
+
+func TestRouterNotify(t *testing.T) {
+	tests := []struct {
+		name string
+		send []EventType
+		want map[string][]EventType
+	}{
+		{
+			name: "карантин получают все подписанные каналы",
+			send: []EventType{EventQuarantined},
+			want: map[string][]EventType{"all": {EventQuarantined}, "quarantine": {EventQuarantined}},
+		},
+		{
+			name: "сводка только в каналы без фильтра и с подпиской на сводку",
+			send: []EventType{EventDailyDigest, EventReleased},
+			want: map[string][]EventType{"all": {EventDailyDigest, EventReleased}, "digest": {EventDailyDigest},
+				"quarantine": {EventReleased}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channels := map[string]*recorder{"all": {}, "quarantine": {}, "digest": {}}
+			router := &Router{}
+			router.Add("all", channels["all"])
+			router.Add("quarantine", channels["quarantine"], EventQuarantined, EventReleased)
+			router.Add("digest", channels["digest"], EventDailyDigest)
+			for _, event := range tt.send {
+				if err := router.Notify(context.Background(), Event{Type: event}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for name, channel := range channels {
+				if !reflect.DeepEqual(channel.events, tt.want[name]) {
+					t.Errorf("канал %s получил %v, ожидалось %v", name, channel.events, tt.want[name])
+				}
+			}
+		})
+	}
+}
+
+func TestRouterNotifyError(t *testing.T) {
+	failed := errors.New("канал недоступен")
+	broken, working := &recorder{err: failed}, &recorder{}
+	router := &Router{}
+	router.Add("broken", broken)
+	router.Add("working", working)
+	err := router.Notify(context.Background(), Event{Type: EventPriceChangeFailed})
+	if !errors.Is(err, failed) {
+		t.Errorf("получено %v, ожидалась ошибка канала", err)
+	}
+	if want := []EventType{EventPriceChangeFailed}; !reflect.DeepEqual(working.events, want) {
+		t.Errorf("рабочий канал получил %v, ожидалось %v", working.events, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     params.NotifyConfig
+		want    int
+		wantErr bool
+	}{
+		{"без каналов", params.NotifyConfig{}, 0, false},
+		{"webhook с фильтром", params.NotifyConfig{Webhook: params.WebhookConfig{URL: "http://localhost",
+			Events: "offer_quarantined, offer_released"}}, 1, false},
+		{"неизвестное событие", params.NotifyConfig{Webhook: params.WebhookConfig{URL: "http://localhost",
+			Events: "offer_quarantined,bogus"}}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, err := New(tt.cfg)
+			if tt.wantErr {
+				if apperrors.KindOf(err) != apperrors.Config {
+					t.Fatalf("ожидалась ошибка настроек, получено %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(router.routes) != tt.want {
+				t.Errorf("каналов %d, ожидалось %d", len(router.routes), tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("неверное тело запроса %v", err)
+		}
+	}))
+	defer server.Close()
+	webhook := &Webhook{client: server.Client(), url: server.URL}
+	event := Event{Type: EventQuarantined, Account: "main", OfferIDs: []string{"a", "b"}}
+	if err := webhook.Notify(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, event) {
+		t.Errorf("получено %+v, ожидалось %+v", got, event)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer failing.Close()
+	webhook = &Webhook{client: failing.Client(), url: failing.URL}
+	if err := webhook.Notify(context.Background(), event); apperrors.KindOf(err) != apperrors.Auth {
+		t.Errorf("получено %v, ожидалась ошибка авторизации", err)
+	}
+}
+
+func TestEventText(t *testing.T) {
+	event := Event{Type: EventQuarantined, Account: "main", OfferIDs: []string{"a", "b"}, Details: "цена 999999"}
+	want := "Товары помещены на карантин (main)\nТовары: a, b\nцена 999999"
+	if got := event.Text(); got != want {
+		t.Errorf("получено %q, ожидалось %q", got, want)
+	}
+}
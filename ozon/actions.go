@@ -0,0 +1,147 @@
+package ozon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// Action акция озона
+type Action struct {
+	ID                         int64  `json:"id"`
+	Title                      string `json:"title"`
+	ParticipatingProductsCount int    `json:"participating_products_count"`
+}
+
+// ActionProduct товар, участвующий в акции
+type ActionProduct struct {
+	ID          int64   `json:"id"`
+	Price       float64 `json:"price"`
+	ActionPrice float64 `json:"action_price"`
+	Stock       int     `json:"stock"`
+}
+
+// ActionMemberships товары, снятые с акций, по идентификатору акции
+type ActionMemberships map[int64][]ActionProduct
+
+func (api *Api) GetActions(ctx context.Context) ([]Action, error) {
+	var response struct {
+		Result []Action `json:"result"`
+	}
+	if err := api.sellerRequest(ctx, "GET", "/v1/actions", nil, &response); err != nil {
+		return nil, fmt.Errorf("ошибка при получении списка акций %w", err)
+	}
+	return response.Result, nil
+}
+
+func (api *Api) GetActionProducts(ctx context.Context, actionID int64) ([]ActionProduct, error) {
+	type Request struct {
+		ActionID int64 `json:"action_id"`
+		Limit    int   `json:"limit"`
+		Offset   int   `json:"offset"`
+	}
+	var products []ActionProduct
+	for offset := 0; ; {
+		var response struct {
+			Result struct {
+				Products []ActionProduct `json:"products"`
+				Total    int             `json:"total"`
+			} `json:"result"`
+		}
+		err := api.sellerRequest(ctx, "POST", "/v1/actions/products", Request{ActionID: actionID, Limit: 100, Offset: offset}, &response)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при получении товаров акции %d %w", actionID, err)
+		}
+		products = append(products, response.Result.Products...)
+		offset += len(response.Result.Products)
+		if len(response.Result.Products) == 0 || offset >= response.Result.Total {
+			return products, nil
+		}
+	}
+}
+
+func (api *Api) DeactivateActionProducts(ctx context.Context, actionID int64, productIDs []int64) error {
+	type Request struct {
+		ActionID   int64   `json:"action_id"`
+		ProductIDs []int64 `json:"product_ids"`
+	}
+	if err := api.sellerRequest(ctx, "POST", "/v1/actions/products/deactivate", Request{actionID, productIDs}, nil); err != nil {
+		return fmt.Errorf("ошибка при удалении товаров из акции %d %w", actionID, err)
+	}
+	return nil
+}
+
+func (api *Api) ActivateActionProducts(ctx context.Context, actionID int64, products []ActionProduct) error {
+	type Product struct {
+		ProductID   int64   `json:"product_id"`
+		ActionPrice float64 `json:"action_price"`
+		Stock       int     `json:"stock"`
+	}
+	type Request struct {
+		ActionID int64     `json:"action_id"`
+		Products []Product `json:"products"`
+	}
+	request := Request{ActionID: actionID}
+	for _, p := range products {
+		request.Products = append(request.Products, Product{p.ID, p.ActionPrice, p.Stock})
+	}
+	if err := api.sellerRequest(ctx, "POST", "/v1/actions/products/activate", request, nil); err != nil {
+		return fmt.Errorf("ошибка при добавлении товаров в акцию %d %w", actionID, err)
+	}
+	return nil
+}
+
+// RemoveFromActions снимает товары со всех активных акций и возвращает,
+// в каких акциях и по каким ценам они участвовали, чтобы потом вернуть их обратно
+func (api *Api) RemoveFromActions(ctx context.Context, productIDs []int64) (ActionMemberships, error) {
+	wanted := make(map[int64]bool, len(productIDs))
+	for _, id := range productIDs {
+		wanted[id] = true
+	}
+	actions, err := api.GetActions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	memberships := make(ActionMemberships)
+	for _, action := range actions {
+		if action.ParticipatingProductsCount == 0 {
+			continue
+		}
+		products, err := api.GetActionProducts(ctx, action.ID)
+		if err != nil {
+			return memberships, err
+		}
+		var removed []ActionProduct
+		var ids []int64
+		for _, p := range products {
+			if wanted[p.ID] {
+				removed = append(removed, p)
+				ids = append(ids, p.ID)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		if err := api.DeactivateActionProducts(ctx, action.ID, ids); err != nil {
+			return memberships, err
+		}
+		slog.Info("товары сняты с акции", "action_id", action.ID, "action_title", action.Title, "products", len(ids))
+		memberships[action.ID] = removed
+	}
+	return memberships, nil
+}
+
+// RestoreActions возвращает товары в акции, с которых они были сняты
+func (api *Api) RestoreActions(ctx context.Context, memberships ActionMemberships) error {
+	var errs []error
+	for actionID, products := range memberships {
+		if err := api.ActivateActionProducts(ctx, actionID, products); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("не удалось вернуть товары в акции %w", errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package ozon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+)
+
+// ReviewCursor позиция постраничного обхода отзывов
+type ReviewCursor struct {
+	LastTimestamp string `json:"pagination_last_timestamp"`
+	LastUUID      string `json:"pagination_last_uuid"`
+}
+
+// GetReviewsInRange обходит отзывы от новых к старым, начиная с позиции cursor (пустая позиция означает
+// самый новый отзыв), и передает в onPage отзывы страницы, опубликованные в интервале [from, to),
+// вместе с позицией следующей страницы. Нулевой to означает отсутствие верхней границы.
+// Обход прекращается на первом отзыве старше from. Отзывы с неразборчивым временем публикации пропускаются.
+// Позиция обхода своя у каждого вызова, поэтому обход не мешает одновременной загрузке новых отзывов.
+// В отличие от опроса новых отзывов, обходятся и просмотренные отзывы, т.к. история в основном состоит из них
+func (api *Api) GetReviewsInRange(ctx context.Context, from, to time.Time, cursor ReviewCursor, onPage func([]Review, ReviewCursor) error) error {
+	request := api.loadMoreParams
+	request.PaginationLastTimestamp, request.PaginationLastUuid = nil, nil
+	request.Filter = map[string]interface{}{}
+	if cursor.LastUUID != "" {
+		request.PaginationLastTimestamp = &cursor.LastTimestamp
+		request.PaginationLastUuid = &cursor.LastUUID
+	}
+	for {
+		res, err := api.getReviewsPage(ctx, request)
+		if err != nil {
+			return err
+		}
+		request.PaginationLastTimestamp = &res.PaginationLastTimestamp
+		request.PaginationLastUuid = &res.PaginationLastUUID
+		page := make([]Review, 0, len(res.Result))
+		stop := false
+		for _, rev := range res.Result {
+			t, err := time.Parse(time.RFC3339Nano, rev.PublishedAt)
+			if err != nil {
+				slog.Warn("неверное время публикации отзыва, отзыв пропущен", "review_id", rev.ID, "published_at", rev.PublishedAt)
+				continue
+			}
+			if t.Before(from) {
+				stop = true
+				break
+			}
+			if !to.IsZero() && !t.Before(to) {
+				continue
+			}
+			page = append(page, rev)
+		}
+		next := ReviewCursor{LastTimestamp: res.PaginationLastTimestamp, LastUUID: res.PaginationLastUUID}
+		if err := onPage(page, next); err != nil {
+			return err
+		}
+		if stop || !res.HasNext {
+			return nil
+		}
+	}
+}
+
+// Backfill отзывы за интервал [From, To), сохраненные локально. Позиция обхода сохраняется
+// после каждой страницы, поэтому прерванную загрузку можно продолжить
+type Backfill struct {
+	path    string
+	From    time.Time         `json:"from"`
+	To      time.Time         `json:"to"`
+	Cursor  ReviewCursor      `json:"cursor"`
+	Done    bool              `json:"done"`
+	Reviews map[string]Review `json:"reviews"`
+}
+
+// LoadBackfill загружает сохраненные отзывы из path. Если файла нет, возвращается пустая выгрузка
+func LoadBackfill(path string) (*Backfill, error) {
+	backfill := &Backfill{path: path, Reviews: make(map[string]Review)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return backfill, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать выгрузку отзывов %w", err)
+	}
+	if err := json.Unmarshal(data, backfill); err != nil {
+		return nil, fmt.Errorf("не удалось распарсить выгрузку отзывов %w", err)
+	}
+	if backfill.Reviews == nil {
+		backfill.Reviews = make(map[string]Review)
+	}
+	return backfill, nil
+}
+
+// Fetch загружает отзывы за интервал [from, to). Если выгрузка уже содержит отзывы за тот же интервал,
+// загрузка продолжается с сохраненной позиции, иначе начинается заново
+func (backfill *Backfill) Fetch(ctx context.Context, api *Api, from, to time.Time) error {
+	if !backfill.From.Equal(from) || !backfill.To.Equal(to) {
+		backfill.From, backfill.To = from, to
+		backfill.Cursor = ReviewCursor{}
+		backfill.Done = false
+		backfill.Reviews = make(map[string]Review)
+	}
+	if backfill.Done {
+		slog.Info("отзывы за интервал уже загружены", "from", from, "to", to, "reviews", len(backfill.Reviews))
+		return nil
+	}
+	err := api.GetReviewsInRange(ctx, from, to, backfill.Cursor, func(page []Review, next ReviewCursor) error {
+		for _, review := range page {
+			backfill.Reviews[review.ID] = review
+		}
+		backfill.Cursor = next
+		slog.Debug("сохранена страница отзывов", "reviews", len(page), "total", len(backfill.Reviews))
+		return backfill.save()
+	})
+	if err != nil {
+		return fmt.Errorf("загрузка прервана, ее можно продолжить повторным запуском: %w", err)
+	}
+	backfill.Done = true
+	return backfill.save()
+}
+
+// List отзывы выгрузки от новых к старым
+func (backfill *Backfill) List() []Review {
+	res := make([]Review, 0, len(backfill.Reviews))
+	for _, review := range backfill.Reviews {
+		res = append(res, review)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].PublishedTime().After(res[j].PublishedTime()) })
+	return res
+}
+
+func (backfill *Backfill) save() error {
+	data, err := json.MarshalIndent(backfill, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации выгрузки отзывов %w", err)
+	}
+	if err := os.WriteFile(backfill.path, data, 0644); err != nil {
+		return fmt.Errorf("не удалось сохранить выгрузку отзывов %w", err)
+	}
+	return nil
+}
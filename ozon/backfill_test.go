@@ -0,0 +1,83 @@
+package ozon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// reviewPages отдает страницы отзывов по позиции pagination_last_uuid из запроса
+func reviewPages(t *testing.T, pages map[string]ReviewsList) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request loadParams
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("неверный запрос %v", err)
+		}
+		if _, ok := request.Filter["interaction_status"]; ok {
+			t.Errorf("обход отфильтрован по статусу отзыва: %v", request.Filter)
+		}
+		cursor := ""
+		if request.PaginationLastUuid != nil {
+			cursor = *request.PaginationLastUuid
+		}
+		json.NewEncoder(w).Encode(pages[cursor])
+	}))
+}
+
+func TestGetReviewsInRange(t *testing.T) {
+	pages := map[string]ReviewsList{
+		"": {HasNext: true, PaginationLastUUID: "p2", Result: []Review{
+			{ID: "r5", PublishedAt: "2024-05-05T10:00:00Z"},
+			{ID: "bad", PublishedAt: "вчера"},
+			{ID: "r4", PublishedAt: "2024-05-04T10:00:00Z"},
+		}},
+		"p2": {HasNext: true, PaginationLastUUID: "p3", Result: []Review{
+			{ID: "r3", PublishedAt: "2024-05-03T10:00:00Z"},
+			{ID: "r2", PublishedAt: "2024-05-02T10:00:00Z"},
+		}},
+		"p3": {HasNext: false, PaginationLastUUID: "p4", Result: []Review{
+			{ID: "r1", PublishedAt: "2024-05-01T10:00:00Z"},
+		}},
+	}
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name   string
+		from   time.Time
+		to     time.Time
+		cursor ReviewCursor
+		want   []string
+	}{
+		{"все отзывы, неразборчивое время пропускается", time.Time{}, time.Time{}, ReviewCursor{}, []string{"r5", "r4", "r3", "r2", "r1"}},
+		{"интервал", day(2), day(5), ReviewCursor{}, []string{"r4", "r3", "r2"}},
+		{"продолжение с позиции", time.Time{}, time.Time{}, ReviewCursor{LastUUID: "p2"}, []string{"r3", "r2", "r1"}},
+	}
+	server := reviewPages(t, pages)
+	defer server.Close()
+	api := &Api{session: server.Client(), url: server.URL, defaultTimeout: time.Second, loadMoreParams: loadParams{
+		Filter: map[string]interface{}{"interaction_status": []string{"NOT_VIEWED"}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := api.GetReviewsInRange(context.Background(), tt.from, tt.to, tt.cursor, func(page []Review, next ReviewCursor) error {
+				for _, review := range page {
+					got = append(got, review.ID)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("получено %v, ожидалось %v", got, tt.want)
+			}
+			if api.loadMoreParams.PaginationLastUuid != nil {
+				t.Errorf("обход изменил общую позицию загрузки отзывов")
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package ozon
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PriceDiff изменение цены товара между двумя снимками.
+// Before или After равны nil, если товар отсутствует в соответствующем снимке
+type PriceDiff struct {
+	OfferID string   `json:"offer_id"`
+	Fields  []string `json:"fields"`
+	Before  *Price   `json:"before"`
+	After   *Price   `json:"after"`
+}
+
+// DiffPriceSnapshots возвращает товары, у которых изменились price, old_price, min_price или auto_action_enabled
+func DiffPriceSnapshots(before, after PriceSnapshot) []PriceDiff {
+	beforeByOffer := make(map[string]Price, len(before.Items))
+	for _, item := range before.Items {
+		beforeByOffer[item.OfferID] = item.Price
+	}
+	afterByOffer := make(map[string]Price, len(after.Items))
+	for _, item := range after.Items {
+		afterByOffer[item.OfferID] = item.Price
+	}
+	var diffs []PriceDiff
+	for offerID, b := range beforeByOffer {
+		b := b
+		a, ok := afterByOffer[offerID]
+		if !ok {
+			diffs = append(diffs, PriceDiff{OfferID: offerID, Fields: []string{"removed"}, Before: &b})
+			continue
+		}
+		if fields := changedPriceFields(b, a); len(fields) > 0 {
+			diffs = append(diffs, PriceDiff{OfferID: offerID, Fields: fields, Before: &b, After: &a})
+		}
+	}
+	for offerID, a := range afterByOffer {
+		a := a
+		if _, ok := beforeByOffer[offerID]; !ok {
+			diffs = append(diffs, PriceDiff{OfferID: offerID, Fields: []string{"added"}, After: &a})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].OfferID < diffs[j].OfferID })
+	return diffs
+}
+
+func changedPriceFields(before, after Price) []string {
+	var fields []string
+	if before.Price != after.Price {
+		fields = append(fields, "price")
+	}
+	if before.OldPrice != after.OldPrice {
+		fields = append(fields, "old_price")
+	}
+	if before.MinPrice != after.MinPrice {
+		fields = append(fields, "min_price")
+	}
+	if before.AutoActionEnabled != after.AutoActionEnabled {
+		fields = append(fields, "auto_action_enabled")
+	}
+	return fields
+}
+
+func WritePriceDiffJSON(w io.Writer, diffs []PriceDiff) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(diffs)
+}
+
+func WritePriceDiffCSV(w io.Writer, diffs []PriceDiff) error {
+	writer := csv.NewWriter(w)
+	header := []string{
+		"offer_id", "fields",
+		"price_before", "price_after",
+		"old_price_before", "old_price_after",
+		"min_price_before", "min_price_after",
+		"auto_action_before", "auto_action_after",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, diff := range diffs {
+		b, a := priceColumns(diff.Before), priceColumns(diff.After)
+		row := []string{diff.OfferID, strings.Join(diff.Fields, ";"), b[0], a[0], b[1], a[1], b[2], a[2], b[3], a[3]}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func priceColumns(price *Price) [4]string {
+	if price == nil {
+		return [4]string{}
+	}
+	return [4]string{
+		fmt.Sprintf("%.2f", price.Price),
+		fmt.Sprintf("%.2f", price.OldPrice),
+		fmt.Sprintf("%.2f", price.MinPrice),
+		strconv.FormatBool(price.AutoActionEnabled),
+	}
+}
@@ -0,0 +1,140 @@
+package ozon
+
+import (
+	"PriceGuardian/statefile"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// JournalEntry последняя цена, которую мы установили для товара
+type JournalEntry struct {
+	OfferID string  `json:"offer_id"`
+	Price   float64 `json:"price"`
+	// State все цены товара (зачеркнутая, минимальная, автоприменение акций), если они известны.
+	// Карантин ценой сбрасывает зачеркнутую цену и акции, поэтому для восстановления одной Price недостаточно
+	State     *Price    `json:"state,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// Journal журнал цен, которые должны действовать на площадке
+type Journal struct {
+	path string
+	// retention сколько хранится запись о товаре, цена которого больше не менялась, 0 без ограничения
+	retention time.Duration
+	mu        sync.Mutex
+	Entries   map[string]JournalEntry `json:"entries"`
+}
+
+func LoadJournal(path string, retention time.Duration) (*Journal, error) {
+	journal := &Journal{path: path, retention: retention, Entries: make(map[string]JournalEntry)}
+	if err := journal.reload(); err != nil {
+		return nil, err
+	}
+	return journal, nil
+}
+
+// reload перечитывает журнал из файла: его могли изменить демон или команда CLI в другом процессе
+func (journal *Journal) reload() error {
+	var state struct {
+		Entries map[string]JournalEntry `json:"entries"`
+	}
+	found, err := statefile.Read(journal.path, &state)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать журнал цен %w", err)
+	}
+	if !found {
+		return nil
+	}
+	if state.Entries == nil {
+		state.Entries = make(map[string]JournalEntry)
+	}
+	journal.Entries = state.Entries
+	return nil
+}
+
+// refresh перечитывает файл перед чтением. Если файл прочитать не удалось, остаются записи из памяти
+func (journal *Journal) refresh() {
+	if err := journal.reload(); err != nil {
+		slog.Warn("используется журнал цен из памяти", "path", journal.path, "error", err)
+	}
+}
+
+// update изменяет журнал под блокировкой файла, перечитав его перед изменением,
+// чтобы не затереть цены, записанные другим процессом
+func (journal *Journal) update(change func(now time.Time)) error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	unlock, err := statefile.Lock(journal.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := journal.reload(); err != nil {
+		return err
+	}
+	change(time.Now())
+	return journal.save()
+}
+
+// Record записывает цены в журнал и сохраняет его на диск
+func (journal *Journal) Record(prices map[string]float64) error {
+	return journal.update(func(now time.Time) {
+		for offerID, price := range prices {
+			journal.Entries[offerID] = JournalEntry{OfferID: offerID, Price: price, ChangedAt: now}
+		}
+	})
+}
+
+// RecordPrices записывает в журнал цены вместе со всеми сопутствующими ценами товаров
+func (journal *Journal) RecordPrices(prices map[string]Price) error {
+	return journal.update(func(now time.Time) {
+		for offerID, price := range prices {
+			state := price
+			journal.Entries[offerID] = JournalEntry{OfferID: offerID, Price: price.Price, State: &state, ChangedAt: now}
+		}
+	})
+}
+
+// States полные цены из журнала для товаров, у которых они записаны
+func (journal *Journal) States() map[string]Price {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	journal.refresh()
+	res := make(map[string]Price, len(journal.Entries))
+	for offerID, entry := range journal.Entries {
+		if entry.State != nil {
+			res[offerID] = *entry.State
+		}
+	}
+	return res
+}
+
+// Expected возвращает цены из журнала для всех записанных товаров
+func (journal *Journal) Expected() map[string]float64 {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	journal.refresh()
+	res := make(map[string]float64, len(journal.Entries))
+	for offerID, entry := range journal.Entries {
+		res[offerID] = entry.Price
+	}
+	return res
+}
+
+// save удаляет устаревшие записи и сохраняет журнал на диск
+func (journal *Journal) save() error {
+	if journal.retention > 0 {
+		threshold := time.Now().Add(-journal.retention)
+		for offerID, entry := range journal.Entries {
+			if entry.ChangedAt.Before(threshold) {
+				delete(journal.Entries, offerID)
+			}
+		}
+	}
+	if err := statefile.Write(journal.path, journal); err != nil {
+		return fmt.Errorf("не удалось сохранить журнал цен %w", err)
+	}
+	return nil
+}
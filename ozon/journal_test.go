@@ -0,0 +1,58 @@
+package ozon
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestJournalSharedFile проверяет, что журнал не затирает цены, записанные через другой экземпляр,
+// как при снятии с карантина командой CLI во время работы демона
+func TestJournalSharedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.json")
+	daemon, err := LoadJournal(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, err := LoadJournal(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := daemon.RecordPrices(map[string]Price{"a": {Price: 1000, AutoActionEnabled: false}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.RecordPrices(map[string]Price{"a": {Price: 100, AutoActionEnabled: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := daemon.Record(map[string]float64{"b": 200}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"a": 100, "b": 200}
+	for name, journal := range map[string]*Journal{"демон": daemon, "CLI": cli} {
+		if got := journal.Expected(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: получено %v, ожидалось %v", name, got, want)
+		}
+		if got := journal.States()["a"]; got != (Price{Price: 100, AutoActionEnabled: true}) {
+			t.Errorf("%s: состояние %+v", name, got)
+		}
+	}
+}
+
+func TestJournalRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.json")
+	journal, err := LoadJournal(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal.Entries["old"] = JournalEntry{OfferID: "old", Price: 1, ChangedAt: time.Now().Add(-2 * time.Hour)}
+	if err := journal.save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.Record(map[string]float64{"new": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := journal.Expected(), map[string]float64{"new": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("получено %v, ожидалось %v", got, want)
+	}
+}
@@ -0,0 +1,154 @@
+package ozon
+
+import (
+	"PriceGuardian/apperrors"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+)
+
+// Name название площадки
+func (api *Api) Name() string {
+	return "ozon"
+}
+
+// GetPrices текущие цены товаров по offer_id. Товары, не найденные на площадке, в ответ не попадают
+func (api *Api) GetPrices(ctx context.Context, offerIDs []string) (map[string]Price, error) {
+	res := make(map[string]Price, len(offerIDs))
+	for i := 0; i < len(offerIDs); i += maxOffersPerRequest {
+		end := min(i+maxOffersPerRequest, len(offerIDs))
+		prices, err := api.GetPrice(ctx, offerIDs[i:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range prices.Items {
+			res[item.OfferID] = item.Price
+		}
+	}
+	return res, nil
+}
+
+// SetPrices устанавливает цены и возвращает offer_id товаров, цены которых приняты площадкой
+func (api *Api) SetPrices(ctx context.Context, prices map[string]Price) ([]string, error) {
+	var updated []string
+	chunk := make(map[string]Price, min(len(prices), maxOffersPerRequest))
+	flush := func() error {
+		res, err := api.ChangePrice(ctx, chunk)
+		if err != nil {
+			return err
+		}
+		journal := make(map[string]Price, len(res.Result))
+		for _, item := range res.Result {
+			if item.Updated {
+				updated = append(updated, item.OfferID)
+				journal[item.OfferID] = chunk[item.OfferID]
+			}
+		}
+		if err := api.journal.RecordPrices(journal); err != nil {
+			slog.Error("ошибка записи в журнал цен", "error", err)
+		}
+		chunk = make(map[string]Price, maxOffersPerRequest)
+		return nil
+	}
+	for offerID, price := range prices {
+		chunk[offerID] = price
+		if len(chunk) == maxOffersPerRequest {
+			if err := flush(); err != nil {
+				return updated, err
+			}
+		}
+	}
+	if len(chunk) > 0 {
+		if err := flush(); err != nil {
+			return updated, err
+		}
+	}
+	return updated, nil
+}
+
+// Barcodes штрихкоды всех товаров кабинета по offer_id
+func (api *Api) Barcodes(ctx context.Context) (map[string][]string, error) {
+	type Request struct {
+		Filter struct {
+			Visibility string `json:"visibility"`
+		} `json:"filter"`
+		LastID string `json:"last_id"`
+		Limit  int    `json:"limit"`
+	}
+	request := Request{Limit: maxOffersPerRequest}
+	request.Filter.Visibility = "ALL"
+	res := make(map[string][]string)
+	for {
+		var response struct {
+			Result []struct {
+				OfferID  string   `json:"offer_id"`
+				Barcodes []string `json:"barcodes"`
+			} `json:"result"`
+			LastID string `json:"last_id"`
+		}
+		if err := api.sellerRequest(ctx, "POST", "/v4/product/info/attributes", request, &response); err != nil {
+			return nil, fmt.Errorf("ошибка при получении штрихкодов товаров %w", err)
+		}
+		for _, item := range response.Result {
+			res[item.OfferID] = item.Barcodes
+		}
+		if len(response.Result) < request.Limit || response.LastID == "" {
+			break
+		}
+		request.LastID = response.LastID
+	}
+	return res, nil
+}
+
+// Reply публикует ответ продавца на отзыв через кабинет продавца
+func (api *Api) Reply(ctx context.Context, review Review, text string) error {
+	body := map[string]string{
+		"review_uuid":  review.UUID,
+		"text":         text,
+		"company_id":   api.companyID,
+		"company_type": "seller",
+	}
+	return api.cookieRequest(ctx, api.answerURL, body, nil)
+}
+
+// cookieRequest отправляет запрос в кабинет продавца с сохраненными cookies и декодирует ответ в out
+func (api *Api) cookieRequest(ctx context.Context, endpoint string, body interface{}, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации данных: %w", err)
+	}
+	path := endpoint
+	if u, err := url.Parse(endpoint); err == nil {
+		path = u.Path
+	}
+	ctx, cancel := api.withTimeout(ctx, path)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("ошибка при подготовке запроса %w", err)
+	}
+	for k, v := range api.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := api.session.Do(req)
+	if err != nil {
+		return apperrors.Wrap(apperrors.Transport, err, "ошибка запроса к кабинету продавца")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return apperrors.FromStatus(resp.StatusCode, string(respBody))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("ошибка декодирования %w", err)
+	}
+	return nil
+}
@@ -1,12 +1,15 @@
 package ozon
 
 import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/metrics"
 	"PriceGuardian/params"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -47,6 +50,32 @@ type Review struct {
 	UUID              string     `json:"uuid"`
 	OrderDeliveryType string     `json:"orderDeliveryType"`
 	IsPinned          bool       `json:"is_pinned"`
+	// Source пустой для отзыва, SourceQuestion для вопроса покупателя
+	Source string `json:"source,omitempty"`
+}
+
+// LogValue атрибуты отзыва для структурированных логов
+func (review Review) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("review_id", review.ID),
+		slog.String("sku", review.SKU),
+		slog.String("offer_id", review.Product.OfferID),
+		slog.Int("rating", review.Rating),
+		slog.String("author_name", review.AuthorName),
+		slog.String("published_at", review.PublishedAt),
+		slog.String("source", review.Source),
+	)
+}
+
+// PublishedTime время публикации отзыва, нулевое, если его не удалось разобрать
+func (review Review) PublishedTime() time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, review.PublishedAt)
+	return t
+}
+
+// ComplaintText текст, по которому определяются категории жалоб: достоинства в нем не учитываются
+func (review Review) ComplaintText() string {
+	return review.Text.Negative + " " + review.Text.Comment
 }
 
 func (review Review) String() string {
@@ -112,33 +141,47 @@ type PriceResponse struct {
 	Total  int32  `json:"total"`
 }
 
+// maxOffersPerRequest ограничение озона на количество товаров в одном запросе цен
+const maxOffersPerRequest = 1000
+
 type Api struct {
-	session           *http.Client
-	url               string
-	answerURL         string
-	commentAnswersURL string
-	companyID         string
-	loadMoreParams    loadParams
-	headers           map[string]string
-	arguments         params.Params
+	session            *http.Client
+	url                string
+	answerURL          string
+	commentAnswersURL  string
+	apiURL             string
+	companyID          string
+	loadMoreParams     loadParams
+	headers            map[string]string
+	config             params.OzonConfig
+	journal            *Journal
+	reconcileDelay     time.Duration
+	reconcileRetries   int
+	quarantine         *QuarantineStore
+	quarantineDuration time.Duration
+	defaultTimeout     time.Duration
+	timeouts           map[string]time.Duration
+	priceCoefficient   float64
 }
 
-func NewApi(args params.Params) *Api {
+func NewApi(cfg params.Config) (*Api, error) {
 	jar, _ := cookiejar.New(nil)
 	api := &Api{
 		session: &http.Client{
-			Jar: jar,
+			Jar:       jar,
+			Transport: metrics.Transport{},
 		},
-		url:               "https://seller.ozon.ru/api/v3/review/list",
-		answerURL:         "https://seller.ozon.ru/api/review/comment/create",
-		commentAnswersURL: "https://seller.ozon.ru/api/review/comment/list",
-		companyID:         args[params.COMPANY_ID],
+		url:               cfg.Ozon.SellerURL + "/api/v3/review/list",
+		answerURL:         cfg.Ozon.SellerURL + "/api/review/comment/create",
+		commentAnswersURL: cfg.Ozon.SellerURL + "/api/review/comment/list",
+		apiURL:            cfg.Ozon.APIURL,
+		companyID:         cfg.Ozon.CompanyID,
 		loadMoreParams: loadParams{
 			WithCounters:            false,
 			Sort:                    map[string]string{"sort_by": "PUBLISHED_AT", "sort_direction": "DESC"},
 			CompanyType:             "seller",
 			Filter:                  map[string]interface{}{"interaction_status": []string{"NOT_VIEWED"}},
-			CompanyId:               args[params.COMPANY_ID],
+			CompanyId:               cfg.Ozon.CompanyID,
 			PaginationLastTimestamp: nil,
 			PaginationLastUuid:      nil,
 		},
@@ -151,26 +194,41 @@ func NewApi(args params.Params) *Api {
 			"referer":         "https://seller.ozon.ru/app/reviews",
 			"user-agent":      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36",
 			"x-o3-app-name":   "seller-ui",
-			"x-o3-company-id": args[params.COMPANY_ID],
+			"x-o3-company-id": cfg.Ozon.CompanyID,
 			"x-o3-language":   "ru",
 			"x-o3-page-type":  "review",
 			"accept-encoding": "*",
 		},
-		arguments: args,
+		config:             cfg.Ozon,
+		reconcileDelay:     cfg.Prices.ReconcileDelay,
+		reconcileRetries:   cfg.Prices.ReconcileRetries,
+		quarantineDuration: cfg.Quarantine.Duration,
+		defaultTimeout:     cfg.Ozon.Timeout,
+		timeouts:           cfg.Ozon.EndpointTimeouts,
+		priceCoefficient:   cfg.Quarantine.PriceCoefficient,
+	}
+	var err error
+	if api.journal, err = LoadJournal(cfg.Prices.JournalPath, cfg.Prices.JournalRetention); err != nil {
+		return nil, err
+	}
+	if api.quarantine, err = LoadQuarantineStore(cfg.Quarantine.StatePath, cfg.Account); err != nil {
+		return nil, err
 	}
-	api.loadCookies()
-	return api
+	if err := api.loadCookies(); err != nil {
+		return nil, err
+	}
+	return api, nil
 }
 
-func (api *Api) loadCookies() {
-	plan, err := os.ReadFile(api.arguments[params.COOKIES_PATH])
+func (api *Api) loadCookies() error {
+	plan, err := os.ReadFile(api.config.CookiesPath)
 	if err != nil {
-		log.Fatalf("не удалось прочитать куки файл %v", err)
+		return apperrors.Wrap(apperrors.Config, err, "не удалось прочитать куки файл")
 	}
 	var data []cookie
 	err = json.Unmarshal(plan, &data)
 	if err != nil {
-		log.Fatalf("не удалось распарсить куки файл, %v", err)
+		return apperrors.Wrap(apperrors.Config, err, "не удалось распарсить куки файл")
 	}
 	cookies := make([]*http.Cookie, 0, 15)
 	for _, c := range data {
@@ -180,23 +238,27 @@ func (api *Api) loadCookies() {
 		}
 		cookies = append(cookies, cookie)
 	}
-	base, err := url.Parse("https://seller.ozon.ru/api/")
+	base, err := url.Parse(api.config.SellerURL + "/api/")
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	api.session.Jar.SetCookies(base, cookies)
+	return nil
 }
 
-func (api *Api) GetReviewsTillTime(startTime time.Time) ([]*Review, time.Time) {
+// GetReviewsTillTime загружает отзывы, опубликованные после startTime, и время самого нового из них.
+// При ошибке возвращаются отзывы, полученные до нее, вместе с ошибкой
+func (api *Api) GetReviewsTillTime(ctx context.Context, startTime time.Time) ([]*Review, time.Time, error) {
 	reviews := make([]*Review, 0, 100)
 	next := true
 	var nextStart *time.Time = nil
 	brk := true
+	var err error
 	for next && brk {
-		res, err := api.GetNextChunk()
+		var res ReviewsList
+		res, err = api.GetNextChunk(ctx)
 		if err != nil {
-			log.Printf("ошибка при получении страницы товаров %v. отзывы начиная с %v до последней успешно полученной страницы не будут обработаны",
-				err, startTime)
+			err = fmt.Errorf("отзывы начиная с %v до последней успешно полученной страницы не будут обработаны: %w", startTime, err)
 			break
 		}
 		for _, rev := range res.Result {
@@ -215,147 +277,191 @@ func (api *Api) GetReviewsTillTime(startTime time.Time) ([]*Review, time.Time) {
 	api.loadMoreParams.PaginationLastUuid = nil
 	api.loadMoreParams.PaginationLastTimestamp = nil
 	if nextStart == nil {
-		return reviews, startTime
+		return reviews, startTime, err
 	}
-	return reviews, *nextStart
+	return reviews, *nextStart, err
 }
 
-func (api *Api) GetNextChunk() (ReviewsList, error) {
-	loadMoreParams, err := json.Marshal(api.loadMoreParams)
+func (api *Api) GetNextChunk(ctx context.Context) (ReviewsList, error) {
+	res, err := api.getReviewsPage(ctx, api.loadMoreParams)
 	if err != nil {
-		return ReviewsList{}, fmt.Errorf("не удалось подготовить параметры для загрузки товаров %v", err)
-	}
-	req, err := http.NewRequest("POST", "https://seller.ozon.ru/api/v3/review/list", bytes.NewBuffer(loadMoreParams))
-	if err != nil {
-		return ReviewsList{}, fmt.Errorf("ошибка при подготовке запроса %v", err)
-	}
-	for k, v := range api.headers {
-		req.Header.Set(k, v)
+		return ReviewsList{}, err
 	}
-	resp, err := api.session.Do(req)
-	if err != nil {
-		return ReviewsList{}, fmt.Errorf("ошибка при получении списка товаров: %v", err)
-	}
-	defer resp.Body.Close()
+	api.loadMoreParams.PaginationLastUuid = &res.PaginationLastUUID
+	api.loadMoreParams.PaginationLastTimestamp = &res.PaginationLastTimestamp
+	return res, nil
+}
 
-	if resp.StatusCode > 299 {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		bodyString := string(bodyBytes)
-		return ReviewsList{}, fmt.Errorf("запрос завершился со кодом %d: %s", resp.StatusCode, bodyString)
-	}
+// getReviewsPage страница отзывов с позиции, заданной в request
+func (api *Api) getReviewsPage(ctx context.Context, request loadParams) (ReviewsList, error) {
 	var res ReviewsList
-	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-		return ReviewsList{}, fmt.Errorf("ошибка декодирования %v", err)
+	if err := api.cookieRequest(ctx, api.url, request, &res); err != nil {
+		return ReviewsList{}, fmt.Errorf("ошибка при получении списка отзывов: %w", err)
 	}
-	api.loadMoreParams.PaginationLastUuid = &res.PaginationLastUUID
-	api.loadMoreParams.PaginationLastTimestamp = &res.PaginationLastTimestamp
-	log.Printf("успешно получена страница с параметрами %v %v", res.PaginationLastUUID, res.PaginationLastTimestamp)
+	slog.Debug("успешно получена страница отзывов",
+		"pagination_last_uuid", res.PaginationLastUUID, "pagination_last_timestamp", res.PaginationLastTimestamp)
 	return res, nil
 }
 
-func (api *Api) PlaceToQuarantine(offerIds []string) error {
-	if len(offerIds) == 0 || len(offerIds) > 1000 {
-		return fmt.Errorf("список offerIDs пуст или содержит больше 1000 элементов")
+// PlaceToQuarantine отправляет товары в ценовой карантин озона: повышает цены и сразу возвращает исходные.
+// Автоприменение акций остается выключенным до снятия с карантина, чтобы озон не вернул товар в акции
+func (api *Api) PlaceToQuarantine(ctx context.Context, offerIds []string) error {
+	if len(offerIds) == 0 || len(offerIds) > maxOffersPerRequest {
+		return apperrors.New(apperrors.Validation, "список offerIDs пуст или содержит больше 1000 элементов")
 	}
-	priceChangeCoefficient := 100.00 // TODO parametrize
-	prices, err := api.GetPrice(offerIds)
+	prices, err := api.GetPrice(ctx, offerIds)
 	if err != nil {
-		return fmt.Errorf("ошибка при получении цен %v", err)
-	}
-	pricesMap := make(map[string]float64, len(offerIds))
-	for _, price := range prices.Items {
-		pricesMap[price.OfferID] = price.Price.Price * priceChangeCoefficient
-	}
-	res, err := api.ChangePrice(pricesMap)
+		return fmt.Errorf("ошибка при получении цен %w", err)
+	}
+	held := make(map[string]Price, len(offerIds))
+	raised := make(map[string]Price, len(offerIds))
+	for _, item := range prices.Items {
+		price := item.Price
+		price.AutoActionEnabled = false
+		held[item.OfferID] = price
+		raised[item.OfferID] = QuarantinePrice(item.Price, api.priceCoefficient)
+	}
+	// цены, которые должны действовать во время карантина, попадают в журнал до повышения,
+	// чтобы recover мог вернуть их, если проход прервется между повышением и восстановлением
+	if err := api.journal.RecordPrices(held); err != nil {
+		return fmt.Errorf("не удалось сохранить исходные цены перед карантином %w", err)
+	}
+	res, err := api.ChangePrice(ctx, raised)
 	if err != nil {
-		return fmt.Errorf("ошибка при повышении цен %v", err)
+		return fmt.Errorf("ошибка при повышении цен %w", err)
 	}
+	restore := make(map[string]Price, len(held))
 	for _, item := range res.Result {
 		if len(item.Errors) != 0 {
-			fmt.Printf("ошибка при повышении цены %v", item)
+			slog.Error("ошибка при повышении цены", "offer_id", item.OfferID, "errors", item.Errors)
 		} else {
-			pricesMap[item.OfferID] /= priceChangeCoefficient
+			restore[item.OfferID] = held[item.OfferID]
 		}
 	}
+	if len(restore) == 0 {
+		return fmt.Errorf("не удалось повысить ни одну цену")
+	}
 
-	res, err = api.ChangePrice(pricesMap)
+	res, err = api.ChangePrice(ctx, restore)
 	if err != nil {
-		return fmt.Errorf("ошибка при понижении цен %v", err)
+		return fmt.Errorf("ошибка при понижении цен %w", err)
 	}
 	for _, item := range res.Result {
 		if len(item.Errors) != 0 {
-			fmt.Printf("ошибка при понижении цены %v", item)
-		} else {
-			pricesMap[item.OfferID] /= priceChangeCoefficient
+			slog.Error("ошибка при понижении цены", "offer_id", item.OfferID, "errors", item.Errors)
 		}
 	}
+	mismatches, err := api.Reconcile(ctx, restore)
+	if err != nil {
+		return fmt.Errorf("ошибка при сверке цен %w", err)
+	}
+	for _, m := range mismatches {
+		slog.Warn("цена не восстановлена после карантина",
+			"offer_id", m.OfferID, "expected", m.Expected, "actual", m.Actual, "found", m.Found)
+	}
 	return nil
 }
 
-func (api *Api) GetPrice(offerIDs []string) (PriceResponse, error) {
-	if len(offerIDs) == 0 || len(offerIDs) > 1000 {
-		return PriceResponse{}, fmt.Errorf("список offerIDs пуст или содержит больше 1000 элементов")
-	}
-	type Filter struct {
-		OfferID    []string `json:"offer_id"`
-		ProductID  []string `json:"product_id"`
-		Visibility string   `json:"visibility"`
+// SetAutoActions включает или выключает автоприменение акций, не меняя остальные цены товаров
+func (api *Api) SetAutoActions(ctx context.Context, offerIDs []string, enabled bool) error {
+	prices, err := api.GetPrice(ctx, offerIDs)
+	if err != nil {
+		return fmt.Errorf("ошибка при получении цен %w", err)
+	}
+	changed := make(map[string]Price, len(prices.Items))
+	for _, item := range prices.Items {
+		if item.Price.AutoActionEnabled != enabled {
+			price := item.Price
+			price.AutoActionEnabled = enabled
+			changed[item.OfferID] = price
+		}
 	}
-	type Request struct {
-		Cursor *string `json:"cursor"`
-		Filter Filter  `json:"filter"`
-		Limit  int     `json:"limit"`
+	if len(changed) == 0 {
+		return nil
 	}
-
-	jsonData, err := json.Marshal(Request{
-		Cursor: nil,
-		Filter: Filter{
-			OfferID:    offerIDs,
-			ProductID:  nil,
-			Visibility: "ALL",
-		},
-		Limit: 1000,
-	})
+	res, err := api.ChangePrice(ctx, changed)
 	if err != nil {
-		return PriceResponse{}, fmt.Errorf("ошибка сериализации данных: %v", err)
+		return fmt.Errorf("ошибка при изменении автоприменения акций %w", err)
 	}
-	req, err := api.RequestWithAuthHeaders(
-		"POST",
-		"https://api-seller.ozon.ru/v5/product/info/prices",
-		bytes.NewBuffer(jsonData),
-	)
-	if err != nil {
-		return PriceResponse{}, fmt.Errorf("ошибка создания запроса: %v", err)
+	journal := make(map[string]Price, len(res.Result))
+	for _, item := range res.Result {
+		if item.Updated {
+			journal[item.OfferID] = changed[item.OfferID]
+		}
 	}
-	resp, err := api.session.Do(req)
-	if err != nil {
-		return PriceResponse{}, fmt.Errorf("ошибка отправки запроса: %v", err)
+	if err := api.journal.RecordPrices(journal); err != nil {
+		slog.Error("ошибка записи в журнал цен", "error", err)
 	}
-	defer resp.Body.Close()
+	return nil
+}
 
-	if resp.StatusCode >= 400 {
-		body, _ := io.ReadAll(resp.Body)
-		return PriceResponse{}, fmt.Errorf("API error: %d %s", resp.StatusCode, string(body))
+// QuarantinePrice повышенная цена для карантина. Зачеркнутая цена сбрасывается,
+// т.к. повышенная цена будет больше нее, а минимальная цена сохраняется.
+// Автоприменение акций выключается, чтобы озон не снизил цену акцией
+func QuarantinePrice(price Price, coefficient float64) Price {
+	raised := price
+	raised.Price = price.Price * coefficient
+	raised.AutoActionEnabled = false
+	if raised.OldPrice != 0 && raised.Price >= raised.OldPrice {
+		raised.OldPrice = 0
+	}
+	return raised
+}
+
+func autoActionValue(enabled bool) string {
+	if enabled {
+		return "ENABLED"
 	}
+	return "DISABLED"
+}
 
+// ValidatePrice проверяет соотношения цен, которые требует озон
+func ValidatePrice(price Price) error {
+	if price.Price <= 0 {
+		return apperrors.New(apperrors.Validation, "цена %.2f должна быть больше нуля", price.Price)
+	}
+	if price.OldPrice != 0 && price.Price >= price.OldPrice {
+		return apperrors.New(apperrors.Validation, "цена %.2f должна быть меньше зачеркнутой цены %.2f", price.Price, price.OldPrice)
+	}
+	if price.MinPrice != 0 && price.Price < price.MinPrice {
+		return apperrors.New(apperrors.Validation, "цена %.2f должна быть не меньше минимальной цены %.2f", price.Price, price.MinPrice)
+	}
+	return nil
+}
+
+func (api *Api) GetPrice(ctx context.Context, offerIDs []string) (PriceResponse, error) {
+	if len(offerIDs) == 0 || len(offerIDs) > maxOffersPerRequest {
+		return PriceResponse{}, apperrors.New(apperrors.Validation, "список offerIDs пуст или содержит больше 1000 элементов")
+	}
 	var response PriceResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return PriceResponse{}, fmt.Errorf("ошибка декодирования ответа: %v", err)
+	it := api.Prices(ctx, PriceFilter{OfferID: offerIDs, Visibility: "ALL"})
+	for it.Next() {
+		response.Items = append(response.Items, it.Page()...)
 	}
+	if err := it.Err(); err != nil {
+		return PriceResponse{}, err
+	}
+	response.Total = int32(len(response.Items))
 	return response, nil
 }
 
-func (api *Api) ChangePrice(newPrices map[string]float64) (PriceChangeResponse, error) {
+// ChangePrice устанавливает price, old_price и min_price товаров. Нулевые old_price и min_price сбрасывают
+// соответствующие цены на площадке, поэтому для сохранения их нужно передавать из полученной структуры Price.
+// В журнал цены не записываются: временную цену карантина нельзя считать ожидаемой, поэтому журнал
+// ведут вызывающие методы с ценами, которые должны действовать после их завершения
+func (api *Api) ChangePrice(ctx context.Context, newPrices map[string]Price) (PriceChangeResponse, error) {
 	// 1. Валидация входных данных
-	if len(newPrices) == 0 || len(newPrices) > 1000 {
-		return PriceChangeResponse{}, fmt.Errorf("списки offerIDs и newPrices не могут быть пустыми")
+	if len(newPrices) == 0 || len(newPrices) > maxOffersPerRequest {
+		return PriceChangeResponse{}, apperrors.New(apperrors.Validation, "список newPrices пуст или содержит больше 1000 элементов")
 	}
 
 	// 2. Подготовка структуры запроса
 	type PriceItem struct {
-		OfferID  string `json:"offer_id"`
-		Price    string `json:"price"`
-		OldPrice string `json:"old_price"`
+		OfferID           string `json:"offer_id"`
+		Price             string `json:"price"`
+		OldPrice          string `json:"old_price"`
+		MinPrice          string `json:"min_price"`
+		AutoActionEnabled string `json:"auto_action_enabled"`
 	}
 
 	type PriceUpdateRequest struct {
@@ -364,67 +470,125 @@ func (api *Api) ChangePrice(newPrices map[string]float64) (PriceChangeResponse,
 
 	var priceItems []PriceItem
 	for k, v := range newPrices {
+		if err := ValidatePrice(v); err != nil {
+			slog.Warn("цена товара не будет изменена", "offer_id", k, "error", err)
+			continue
+		}
 		priceItems = append(priceItems, PriceItem{
-			OfferID:  k,
-			Price:    fmt.Sprintf("%.2f", v),
-			OldPrice: "0",
+			OfferID:           k,
+			Price:             fmt.Sprintf("%.2f", v.Price),
+			OldPrice:          fmt.Sprintf("%.2f", v.OldPrice),
+			MinPrice:          fmt.Sprintf("%.2f", v.MinPrice),
+			AutoActionEnabled: autoActionValue(v.AutoActionEnabled),
 		})
 	}
+	if len(priceItems) == 0 {
+		return PriceChangeResponse{}, apperrors.New(apperrors.Validation, "ни одна из цен не прошла проверку")
+	}
 
 	// 3. Формирование JSON
 	jsonData, err := json.Marshal(PriceUpdateRequest{Prices: priceItems})
 	if err != nil {
-		return PriceChangeResponse{}, fmt.Errorf("ошибка сериализации данных: %v", err)
+		return PriceChangeResponse{}, fmt.Errorf("ошибка сериализации данных: %w", err)
 	}
 
 	// 4. Создание и настройка запроса
-	req, err := api.RequestWithAuthHeaders(
+	ctx, cancel := api.withTimeout(ctx, "/v1/product/import/prices")
+	defer cancel()
+	req, err := api.RequestWithAuthHeaders(ctx,
 		"POST",
-		"https://api-seller.ozon.ru/v1/product/import/prices",
+		api.apiURL+"/v1/product/import/prices",
 		bytes.NewBuffer(jsonData),
 	)
 	if err != nil {
-		return PriceChangeResponse{}, fmt.Errorf("ошибка создания запроса: %v", err)
+		return PriceChangeResponse{}, fmt.Errorf("ошибка создания запроса: %w", err)
 	}
 
 	// 5. Отправка запроса
 	resp, err := api.session.Do(req)
 	if err != nil {
-		return PriceChangeResponse{}, fmt.Errorf("ошибка отправки запроса: %v", err)
+		return PriceChangeResponse{}, apperrors.Wrap(apperrors.Transport, err, "ошибка отправки запроса")
 	}
 	defer resp.Body.Close()
 
 	// 6. Обработка ответа
 	if resp.StatusCode >= 400 {
 		body, _ := io.ReadAll(resp.Body)
-		return PriceChangeResponse{}, fmt.Errorf("API error: %d %s", resp.StatusCode, string(body))
+		return PriceChangeResponse{}, apperrors.FromStatus(resp.StatusCode, string(body))
 	}
 
 	var response PriceChangeResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return PriceChangeResponse{}, fmt.Errorf("ошибка декодирования ответа: %v", err)
+		return PriceChangeResponse{}, fmt.Errorf("ошибка декодирования ответа: %w", err)
 	}
 	successfullyUpdated := 0
 	for _, res := range response.Result {
 		if !res.Updated {
-			log.Printf("ошибка при обновлнеии цены %v", res)
+			slog.Error("ошибка при обновлении цены", "offer_id", res.OfferID, "errors", res.Errors)
 		} else {
 			successfullyUpdated++
 		}
 	}
+	metrics.PriceChanges.Add(float64(successfullyUpdated), "succeeded")
+	metrics.PriceChanges.Add(float64(len(response.Result)-successfullyUpdated), "failed")
 
-	log.Printf("Успешно обновлено %d цен", successfullyUpdated)
+	slog.Info("цены обновлены", "updated", successfullyUpdated, "failed", len(response.Result)-successfullyUpdated)
 	return response, nil
 }
 
-func (api *Api) RequestWithAuthHeaders(method, path string, body io.Reader) (*http.Request, error) {
-	req, err := http.NewRequest(method, path, body)
+func (api *Api) RequestWithAuthHeaders(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, path, body)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Accept-Encoding", "*")
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Client-Id", api.arguments[params.CLIENT_ID])
-	req.Header.Set("Api-Key", api.arguments[params.API_KEY])
+	req.Header.Set("Client-Id", api.config.ClientID)
+	req.Header.Set("Api-Key", api.config.APIKey)
 	return req, nil
 }
+
+// withTimeout ограничивает время запроса к эндпоинту path. Таймауты отдельных эндпоинтов
+// задаются параметром OZON_ENDPOINT_TIMEOUTS, для остальных используется OZON_TIMEOUT
+func (api *Api) withTimeout(ctx context.Context, path string) (context.Context, context.CancelFunc) {
+	timeout, ok := api.timeouts[path]
+	if !ok {
+		timeout = api.defaultTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// sellerRequest отправляет запрос в seller api и декодирует ответ в out
+func (api *Api) sellerRequest(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("ошибка сериализации данных: %w", err)
+		}
+		reader = bytes.NewBuffer(jsonData)
+	}
+	ctx, cancel := api.withTimeout(ctx, path)
+	defer cancel()
+	req, err := api.RequestWithAuthHeaders(ctx, method, api.apiURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	resp, err := api.session.Do(req)
+	if err != nil {
+		return apperrors.Wrap(apperrors.Transport, err, "ошибка отправки запроса")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return apperrors.FromStatus(resp.StatusCode, string(respBody))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("ошибка декодирования ответа: %w", err)
+	}
+	return nil
+}
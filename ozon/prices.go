@@ -0,0 +1,131 @@
+package ozon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// PriceFilter фильтр запроса /v5/product/info/prices
+type PriceFilter struct {
+	OfferID    []string `json:"offer_id,omitempty"`
+	ProductID  []string `json:"product_id,omitempty"`
+	Visibility string   `json:"visibility,omitempty"`
+}
+
+// PriceIterator постранично обходит цены, следуя за курсором из ответа.
+// Использование аналогично bufio.Scanner:
+//
+//	it := api.Prices(ctx, filter)
+//	for it.Next() {
+//		items := it.Page()
+//	}
+//	err := it.Err()
+type PriceIterator struct {
+	ctx    context.Context
+	api    *Api
+	filter PriceFilter
+	cursor string
+	page   []Item
+	done   bool
+	err    error
+}
+
+// Prices возвращает итератор по ценам товаров, подходящих под фильтр
+func (api *Api) Prices(ctx context.Context, filter PriceFilter) *PriceIterator {
+	if filter.Visibility == "" {
+		filter.Visibility = "ALL"
+	}
+	return &PriceIterator{ctx: ctx, api: api, filter: filter}
+}
+
+// Next загружает следующую страницу. Возвращает false, когда страницы закончились или произошла ошибка
+func (it *PriceIterator) Next() bool {
+	if it.done {
+		return false
+	}
+	res, err := it.api.getPricePage(it.ctx, it.filter, it.cursor)
+	if err != nil {
+		it.err = err
+		it.done = true
+		return false
+	}
+	it.page = res.Items
+	it.cursor = res.Cursor
+	if res.Cursor == "" || len(res.Items) == 0 {
+		it.done = true
+	}
+	return len(res.Items) > 0
+}
+
+// Page товары текущей страницы
+func (it *PriceIterator) Page() []Item {
+	return it.page
+}
+
+// Err первая ошибка, произошедшая при обходе
+func (it *PriceIterator) Err() error {
+	return it.err
+}
+
+func (api *Api) getPricePage(ctx context.Context, filter PriceFilter, cursor string) (PriceResponse, error) {
+	type Request struct {
+		Cursor string      `json:"cursor"`
+		Filter PriceFilter `json:"filter"`
+		Limit  int         `json:"limit"`
+	}
+	var response PriceResponse
+	err := api.sellerRequest(ctx, "POST", "/v5/product/info/prices", Request{
+		Cursor: cursor,
+		Filter: filter,
+		Limit:  maxOffersPerRequest,
+	}, &response)
+	if err != nil {
+		return PriceResponse{}, err
+	}
+	return response, nil
+}
+
+// PriceSnapshot цены всего каталога на момент TakenAt
+type PriceSnapshot struct {
+	TakenAt time.Time `json:"taken_at"`
+	Items   []Item    `json:"items"`
+}
+
+// TakePriceSnapshot загружает все страницы цен, подходящих под фильтр
+func (api *Api) TakePriceSnapshot(ctx context.Context, filter PriceFilter) (PriceSnapshot, error) {
+	snapshot := PriceSnapshot{TakenAt: time.Now()}
+	it := api.Prices(ctx, filter)
+	for it.Next() {
+		snapshot.Items = append(snapshot.Items, it.Page()...)
+	}
+	if err := it.Err(); err != nil {
+		return PriceSnapshot{}, fmt.Errorf("ошибка при получении цен каталога %w", err)
+	}
+	return snapshot, nil
+}
+
+func (snapshot PriceSnapshot) Save(path string) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации снимка цен %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("не удалось сохранить снимок цен %w", err)
+	}
+	return nil
+}
+
+func LoadPriceSnapshot(path string) (PriceSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PriceSnapshot{}, fmt.Errorf("не удалось прочитать снимок цен %w", err)
+	}
+	var snapshot PriceSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return PriceSnapshot{}, fmt.Errorf("не удалось распарсить снимок цен %w", err)
+	}
+	return snapshot, nil
+}
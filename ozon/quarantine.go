@@ -0,0 +1,439 @@
+package ozon
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/complaints"
+	"PriceGuardian/metrics"
+	"PriceGuardian/statefile"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QuarantineAction способ остановить продажи подозрительного товара
+type QuarantineAction string
+
+const (
+	// ActionPrice временное повышение цены, после которого озон отправляет товар в ценовой карантин
+	ActionPrice QuarantineAction = "price"
+	// ActionStock обнуление остатков на складах FBS
+	ActionStock QuarantineAction = "stock"
+	// ActionArchive перенос товара в архив
+	ActionArchive QuarantineAction = "archive"
+)
+
+func ParseQuarantineAction(s string) (QuarantineAction, error) {
+	switch action := QuarantineAction(strings.TrimSpace(s)); action {
+	case ActionPrice, ActionStock, ActionArchive:
+		return action, nil
+	default:
+		return "", apperrors.New(apperrors.Config, "неизвестное действие карантина %q", s)
+	}
+}
+
+// QuarantinePolicy выбирает действие карантина для товара
+type QuarantinePolicy struct {
+	Default    QuarantineAction
+	ByOffer    map[string]QuarantineAction
+	ByCategory map[string]QuarantineAction
+}
+
+// ParseQuarantinePolicy разбирает политику из действия по умолчанию
+// и действий по товарам и категориям жалоб из конфигурации
+func ParseQuarantinePolicy(defaultAction string, byOffer, byCategory map[string]string) (QuarantinePolicy, error) {
+	action, err := ParseQuarantineAction(defaultAction)
+	if err != nil {
+		return QuarantinePolicy{}, err
+	}
+	policy := QuarantinePolicy{Default: action}
+	if policy.ByOffer, err = parseActionMap(byOffer); err != nil {
+		return QuarantinePolicy{}, err
+	}
+	if policy.ByCategory, err = parseActionMap(byCategory); err != nil {
+		return QuarantinePolicy{}, err
+	}
+	return policy, nil
+}
+
+func parseActionMap(actions map[string]string) (map[string]QuarantineAction, error) {
+	res := make(map[string]QuarantineAction, len(actions))
+	for key, value := range actions {
+		action, err := ParseQuarantineAction(value)
+		if err != nil {
+			return nil, err
+		}
+		res[key] = action
+	}
+	return res, nil
+}
+
+// ActionFor действие для товара. Настройка для товара важнее настройки для категории жалобы.
+// categories категории жалоб в отзывах о товаре от самой частой, применяется первая настроенная
+func (policy QuarantinePolicy) ActionFor(offerID string, categories []string) QuarantineAction {
+	if action, ok := policy.ByOffer[offerID]; ok {
+		return action
+	}
+	for _, category := range categories {
+		if action, ok := policy.ByCategory[category]; ok {
+			return action
+		}
+	}
+	return policy.Default
+}
+
+// ComplaintCategories категории жалоб в отзывах от самой частой к самой редкой
+func ComplaintCategories(reviews []Review) []string {
+	texts := make([]string, 0, len(reviews))
+	for _, review := range reviews {
+		texts = append(texts, review.ComplaintText())
+	}
+	return complaints.Rank(texts)
+}
+
+// QuarantineRecord товар на карантине и все, что нужно для его восстановления
+type QuarantineRecord struct {
+	OfferID   string           `json:"offer_id"`
+	ProductID int64            `json:"product_id"`
+	Action    QuarantineAction `json:"action"`
+	// Price исходные цены товара, включая автоприменение акций, которое выключается на время карантина
+	Price Price `json:"price"`
+	// QuarantinePrice цена, установленная на время карантина, только для действия price
+	QuarantinePrice float64          `json:"quarantine_price,omitempty"`
+	Stocks          []WarehouseStock `json:"stocks,omitempty"`
+	// Actions участие товара в акциях до карантина по идентификатору акции, восстанавливается при снятии
+	Actions   map[int64]ActionProduct `json:"actions,omitempty"`
+	Reviews   []Review                `json:"reviews,omitempty"`
+	StartedAt time.Time               `json:"started_at"`
+	EndsAt    time.Time               `json:"ends_at"`
+}
+
+// QuarantineStore товары на карантине, сохраняемые в файл между запусками
+type QuarantineStore struct {
+	path    string
+	account string
+	mu      sync.Mutex
+	Records map[string]QuarantineRecord `json:"records"`
+}
+
+func LoadQuarantineStore(path, account string) (*QuarantineStore, error) {
+	store := &QuarantineStore{path: path, account: account, Records: make(map[string]QuarantineRecord)}
+	if err := store.reload(); err != nil {
+		return nil, err
+	}
+	metrics.QuarantinedOffers.Set(float64(len(store.Records)), account)
+	return store, nil
+}
+
+// reload перечитывает записи из файла: его могли изменить демон или команда CLI в другом процессе
+func (store *QuarantineStore) reload() error {
+	var state struct {
+		Records map[string]QuarantineRecord `json:"records"`
+	}
+	found, err := statefile.Read(store.path, &state)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать состояние карантина %w", err)
+	}
+	if !found {
+		return nil
+	}
+	if state.Records == nil {
+		state.Records = make(map[string]QuarantineRecord)
+	}
+	store.Records = state.Records
+	return nil
+}
+
+// refresh перечитывает файл перед чтением. Если файл прочитать не удалось, остаются записи из памяти
+func (store *QuarantineStore) refresh() {
+	if err := store.reload(); err != nil {
+		slog.Warn("используется состояние карантина из памяти", "account", store.account, "error", err)
+	}
+}
+
+// update изменяет записи под блокировкой файла, перечитав его перед изменением,
+// чтобы не затереть изменения другого процесса
+func (store *QuarantineStore) update(change func()) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	unlock, err := statefile.Lock(store.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := store.reload(); err != nil {
+		return err
+	}
+	change()
+	return store.save()
+}
+
+func (store *QuarantineStore) Add(records []QuarantineRecord) error {
+	return store.update(func() {
+		for _, record := range records {
+			store.Records[record.OfferID] = record
+		}
+	})
+}
+
+func (store *QuarantineStore) Remove(offerIDs []string) error {
+	return store.update(func() {
+		for _, offerID := range offerIDs {
+			delete(store.Records, offerID)
+		}
+	})
+}
+
+func (store *QuarantineStore) Get(offerID string) (QuarantineRecord, bool) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.refresh()
+	record, ok := store.Records[offerID]
+	return record, ok
+}
+
+// All записи, отсортированные по времени начала карантина
+func (store *QuarantineStore) All() []QuarantineRecord {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.refresh()
+	res := make([]QuarantineRecord, 0, len(store.Records))
+	for _, record := range store.Records {
+		res = append(res, record)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].StartedAt.Before(res[j].StartedAt) })
+	return res
+}
+
+// Due товары, карантин которых закончился к моменту now
+func (store *QuarantineStore) Due(now time.Time) []string {
+	var res []string
+	for _, record := range store.All() {
+		if !record.EndsAt.After(now) {
+			res = append(res, record.OfferID)
+		}
+	}
+	return res
+}
+
+func (store *QuarantineStore) save() error {
+	metrics.QuarantinedOffers.Set(float64(len(store.Records)), store.account)
+	if err := statefile.Write(store.path, store); err != nil {
+		return fmt.Errorf("не удалось сохранить состояние карантина %w", err)
+	}
+	return nil
+}
+
+// Quarantines товары на карантине
+func (api *Api) Quarantines() []QuarantineRecord {
+	return api.quarantine.All()
+}
+
+// QuarantineRecord запись о карантине товара, если он на карантине
+func (api *Api) QuarantineRecord(offerID string) (QuarantineRecord, bool) {
+	return api.quarantine.Get(offerID)
+}
+
+// Quarantine останавливает продажи товаров выбранным способом и запоминает их состояние для восстановления.
+// reviews отзывы, из-за которых товар попал на карантин, может быть nil при ручном карантине
+func (api *Api) Quarantine(ctx context.Context, offerIDs []string, action QuarantineAction, reviews map[string][]Review) ([]string, error) {
+	// повторный карантин перезаписал бы сохраненные цены и остатки уже измененными
+	fresh := make([]string, 0, len(offerIDs))
+	for _, offerID := range offerIDs {
+		if _, ok := api.quarantine.Get(offerID); ok {
+			slog.Info("товар уже находится на карантине", "offer_id", offerID)
+			continue
+		}
+		fresh = append(fresh, offerID)
+	}
+	if len(fresh) == 0 {
+		return nil, nil
+	}
+	prices, err := api.GetPrice(ctx, fresh)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении цен %w", err)
+	}
+	now := time.Now()
+	records := make(map[string]*QuarantineRecord, len(prices.Items))
+	productIDs := make([]int64, 0, len(prices.Items))
+	for _, item := range prices.Items {
+		records[item.OfferID] = &QuarantineRecord{
+			OfferID:   item.OfferID,
+			ProductID: int64(item.ProductID),
+			Action:    action,
+			Price:     item.Price,
+			Reviews:   reviews[item.OfferID],
+			StartedAt: now,
+			EndsAt:    now.Add(api.quarantineDuration),
+		}
+		if action == ActionPrice {
+			records[item.OfferID].QuarantinePrice = QuarantinePrice(item.Price, api.priceCoefficient).Price
+		}
+		productIDs = append(productIDs, int64(item.ProductID))
+	}
+	// действие применяется только к товарам, найденным в каталоге
+	offerIDs = make([]string, 0, len(records))
+	for _, offerID := range fresh {
+		if _, ok := records[offerID]; ok {
+			offerIDs = append(offerIDs, offerID)
+		} else {
+			slog.Warn("товар не найден в каталоге продавца", "offer_id", offerID)
+		}
+	}
+	if len(offerIDs) == 0 {
+		return nil, apperrors.New(apperrors.Validation, "товары %v не найдены в каталоге продавца", fresh)
+	}
+	// товары снимаются с акций при любом действии, иначе акция вернет продажи или снизит повышенную цену
+	byProduct := make(map[int64]*QuarantineRecord, len(records))
+	for _, record := range records {
+		byProduct[record.ProductID] = record
+	}
+	memberships, err := api.RemoveFromActions(ctx, productIDs)
+	if err != nil {
+		slog.Error("ошибка при снятии товаров с акций", "error", err)
+	}
+	for actionID, products := range memberships {
+		for _, product := range products {
+			if record, ok := byProduct[product.ID]; ok {
+				if record.Actions == nil {
+					record.Actions = make(map[int64]ActionProduct)
+				}
+				record.Actions[actionID] = product
+			}
+		}
+	}
+	switch action {
+	case ActionPrice:
+		err = api.PlaceToQuarantine(ctx, offerIDs)
+	case ActionStock:
+		if err = api.SetAutoActions(ctx, offerIDs, false); err != nil {
+			break
+		}
+		var stocks []WarehouseStock
+		stocks, err = api.GetFBSStocks(ctx, offerIDs)
+		if err != nil {
+			break
+		}
+		zero := make([]WarehouseStock, 0, len(stocks))
+		for _, stock := range stocks {
+			if record, ok := records[stock.OfferID]; ok {
+				record.Stocks = append(record.Stocks, stock)
+			}
+			stock.Present = 0
+			zero = append(zero, stock)
+		}
+		err = api.SetFBSStocks(ctx, zero)
+	case ActionArchive:
+		if err = api.SetAutoActions(ctx, offerIDs, false); err != nil {
+			break
+		}
+		err = api.ArchiveProducts(ctx, productIDs)
+	default:
+		err = apperrors.New(apperrors.Validation, "неизвестное действие карантина %q", action)
+	}
+	if err != nil {
+		// товары не попали на карантин, поэтому возвращаются в акции сразу
+		if restoreErr := api.RestoreActions(ctx, memberships); restoreErr != nil {
+			slog.Error("ошибка при возврате товаров в акции", "error", restoreErr)
+		}
+		return nil, err
+	}
+	toSave := make([]QuarantineRecord, 0, len(records))
+	for _, record := range records {
+		toSave = append(toSave, *record)
+	}
+	if err := api.quarantine.Add(toSave); err != nil {
+		return nil, err
+	}
+	return offerIDs, nil
+}
+
+// Release снимает товары с карантина, восстанавливая цены, остатки или возвращая их из архива,
+// а затем автоприменение и участие в акциях. Возвращает offer_id снятых с карантина товаров,
+// товары не на карантине пропускаются
+func (api *Api) Release(ctx context.Context, offerIDs []string) ([]string, error) {
+	prices := make(map[string]Price)
+	var stocks []WarehouseStock
+	var archived []int64
+	var autoActions []string
+	var released []string
+	memberships := make(ActionMemberships)
+	for _, offerID := range offerIDs {
+		record, ok := api.quarantine.Get(offerID)
+		if !ok {
+			slog.Warn("товар не находится на карантине", "offer_id", offerID)
+			continue
+		}
+		switch record.Action {
+		case ActionPrice:
+			prices[offerID] = record.Price
+		case ActionStock:
+			stocks = append(stocks, record.Stocks...)
+		case ActionArchive:
+			archived = append(archived, record.ProductID)
+		}
+		if record.Action != ActionPrice && record.Price.AutoActionEnabled {
+			autoActions = append(autoActions, offerID)
+		}
+		for actionID, product := range record.Actions {
+			memberships[actionID] = append(memberships[actionID], product)
+		}
+		released = append(released, offerID)
+	}
+	var errs []error
+	if len(prices) > 0 {
+		if res, err := api.ChangePrice(ctx, prices); err != nil {
+			errs = append(errs, err)
+		} else {
+			journal := make(map[string]Price, len(res.Result))
+			for _, item := range res.Result {
+				if item.Updated {
+					journal[item.OfferID] = prices[item.OfferID]
+				}
+			}
+			if err := api.journal.RecordPrices(journal); err != nil {
+				slog.Error("ошибка записи в журнал цен", "error", err)
+			}
+		}
+	}
+	if len(stocks) > 0 {
+		if err := api.SetFBSStocks(ctx, stocks); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(archived) > 0 {
+		if err := api.UnarchiveProducts(ctx, archived); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(autoActions) > 0 {
+		if err := api.SetAutoActions(ctx, autoActions, true); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("ошибка при снятии с карантина %w", errors.Join(errs...))
+	}
+	// в акции товары возвращаются после восстановления цен, от которых озон считает цену акции
+	if err := api.RestoreActions(ctx, memberships); err != nil {
+		return nil, err
+	}
+	if err := api.quarantine.Remove(released); err != nil {
+		return nil, err
+	}
+	return released, nil
+}
+
+// ReleaseDue снимает с карантина товары, срок карантина которых истек, и возвращает их offer_id
+func (api *Api) ReleaseDue(ctx context.Context) ([]string, error) {
+	due := api.quarantine.Due(time.Now())
+	if len(due) == 0 {
+		return nil, nil
+	}
+	slog.Info("снимаю с карантина товары с истекшим сроком", "offers", len(due))
+	return api.Release(ctx, due)
+}
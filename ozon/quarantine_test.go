@@ -0,0 +1,86 @@
+package ozon
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQuarantineAndRelease(t *testing.T) {
+	original := Price{Price: 1000, OldPrice: 1500, MinPrice: 800, AutoActionEnabled: true}
+	membership := ActionProduct{ID: 7, ActionPrice: 900, Stock: 5}
+	tests := []struct {
+		action QuarantineAction
+		// duringStock и duringArchived остатки и архив во время карантина
+		duringStock    int
+		duringArchived bool
+	}{
+		{action: ActionPrice, duringStock: 3},
+		{action: ActionStock, duringStock: 0},
+		{action: ActionArchive, duringStock: 3, duringArchived: true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.action), func(t *testing.T) {
+			seller := &fakeSeller{
+				prices:     map[string]Price{"a": original},
+				productIDs: map[string]int{"a": 7},
+				actions:    map[int64][]ActionProduct{42: {membership}},
+				stocks:     map[string]int{"a": 3},
+				archived:   map[int64]bool{},
+			}
+			api := newTestApi(t, seller)
+			api.priceCoefficient = 10
+			api.quarantineDuration = time.Hour
+			quarantined, err := api.Quarantine(context.Background(), []string{"a", "missing"}, tt.action, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(quarantined, []string{"a"}) {
+				t.Errorf("на карантине %v, ожидалось [a]", quarantined)
+			}
+
+			held := original
+			held.AutoActionEnabled = false
+			if got := seller.prices["a"]; got != held {
+				t.Errorf("цена во время карантина %+v, ожидалось %+v", got, held)
+			}
+			if len(seller.actions[42]) != 0 {
+				t.Errorf("товар остался в акции во время карантина")
+			}
+			if seller.stocks["a"] != tt.duringStock || seller.archived[7] != tt.duringArchived {
+				t.Errorf("остаток %d, в архиве %v во время карантина", seller.stocks["a"], seller.archived[7])
+			}
+			record, ok := api.QuarantineRecord("a")
+			if !ok {
+				t.Fatal("нет записи о карантине")
+			}
+			if !reflect.DeepEqual(record.Actions, map[int64]ActionProduct{42: membership}) || record.Price != original {
+				t.Errorf("запись о карантине %+v", record)
+			}
+			if expected := api.journal.States()["a"]; expected != held {
+				t.Errorf("в журнале %+v, ожидалось %+v", expected, held)
+			}
+
+			released, err := api.Release(context.Background(), []string{"a", "missing"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(released, []string{"a"}) {
+				t.Errorf("сняты с карантина %v, ожидалось [a]", released)
+			}
+			if got := seller.prices["a"]; got != original {
+				t.Errorf("цена после карантина %+v, ожидалось %+v", got, original)
+			}
+			if !reflect.DeepEqual(seller.actions[42], []ActionProduct{membership}) {
+				t.Errorf("товар не вернулся в акцию: %v", seller.actions[42])
+			}
+			if seller.stocks["a"] != 3 || seller.archived[7] {
+				t.Errorf("остаток %d, в архиве %v после карантина", seller.stocks["a"], seller.archived[7])
+			}
+			if _, ok := api.QuarantineRecord("a"); ok {
+				t.Errorf("запись о карантине не удалена")
+			}
+		})
+	}
+}
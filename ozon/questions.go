@@ -0,0 +1,152 @@
+package ozon
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// SourceQuestion источник записи: вопрос покупателя, а не отзыв
+const SourceQuestion = "question"
+
+// Question вопрос покупателя о товаре
+type Question struct {
+	ID          string `json:"id"`
+	SKU         int64  `json:"sku"`
+	Text        string `json:"text"`
+	PublishedAt string `json:"published_at"`
+	AuthorName  string `json:"author_name"`
+	ProductURL  string `json:"product_url"`
+	Status      string `json:"status"`
+	// OfferID и ProductName заполняются по SKU из каталога продавца
+	OfferID     string `json:"offer_id"`
+	ProductName string `json:"product_name"`
+}
+
+// Review вопрос в виде отзыва, чтобы он проходил через тот же архив и карантин, что и отзывы.
+// У вопроса нет оценки, поэтому Rating нулевой
+func (question Question) Review() Review {
+	return Review{
+		ID:          question.ID,
+		UUID:        question.ID,
+		SKU:         strconv.FormatInt(question.SKU, 10),
+		Text:        ReviewText{Comment: question.Text},
+		PublishedAt: question.PublishedAt,
+		AuthorName:  question.AuthorName,
+		Source:      SourceQuestion,
+		Product:     Product{Title: question.ProductName, URL: question.ProductURL, OfferID: question.OfferID},
+	}
+}
+
+// PublishedTime время публикации вопроса, нулевое, если его не удалось разобрать
+func (question Question) PublishedTime() time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, question.PublishedAt)
+	return t
+}
+
+// GetQuestionsTillTime загружает вопросы покупателей, опубликованные после startTime, и время самого нового из них.
+// Вопросы, товар которых не найден в каталоге продавца, пропускаются.
+// При ошибке возвращаются вопросы, полученные до нее, вместе с ошибкой
+func (api *Api) GetQuestionsTillTime(ctx context.Context, startTime time.Time) ([]*Question, time.Time, error) {
+	type Request struct {
+		Filter struct {
+			DateFrom *time.Time `json:"date_from,omitempty"`
+			Status   string     `json:"status"`
+		} `json:"filter"`
+		LastID string `json:"last_id"`
+	}
+	var request Request
+	request.Filter.Status = "ALL"
+	if !startTime.IsZero() {
+		request.Filter.DateFrom = &startTime
+	}
+	var questions []*Question
+	newest := startTime
+	var err error
+	for {
+		var response struct {
+			Questions []Question `json:"questions"`
+			LastID    string     `json:"last_id"`
+		}
+		if err = api.sellerRequest(ctx, "POST", "/v1/question/list", request, &response); err != nil {
+			err = fmt.Errorf("вопросы начиная с %v до последней успешно полученной страницы не будут обработаны: %w", startTime, err)
+			break
+		}
+		for i := range response.Questions {
+			question := response.Questions[i]
+			t := question.PublishedTime()
+			if !t.After(startTime) {
+				continue
+			}
+			if t.After(newest) {
+				newest = t
+			}
+			questions = append(questions, &question)
+		}
+		slog.Debug("успешно получена страница вопросов", "last_id", response.LastID, "questions", len(response.Questions))
+		if response.LastID == "" || len(response.Questions) == 0 {
+			break
+		}
+		request.LastID = response.LastID
+	}
+	if len(questions) > 0 {
+		if productErr := api.fillQuestionProducts(ctx, questions); productErr != nil {
+			// без offer_id вопрос нельзя связать с карантином, поэтому время не сдвигается
+			return nil, startTime, productErr
+		}
+	}
+	found := questions[:0]
+	for _, question := range questions {
+		if question.OfferID == "" {
+			slog.Warn("товар вопроса не найден в каталоге продавца", "question_id", question.ID, "sku", question.SKU)
+			continue
+		}
+		found = append(found, question)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].PublishedTime().After(found[j].PublishedTime()) })
+	return found, newest, err
+}
+
+// fillQuestionProducts заполняет offer_id и название товара вопросов по SKU
+func (api *Api) fillQuestionProducts(ctx context.Context, questions []*Question) error {
+	skus := make([]string, 0, len(questions))
+	seen := make(map[int64]bool, len(questions))
+	for _, question := range questions {
+		if !seen[question.SKU] {
+			seen[question.SKU] = true
+			skus = append(skus, strconv.FormatInt(question.SKU, 10))
+		}
+	}
+	type product struct {
+		OfferID string `json:"offer_id"`
+		Name    string `json:"name"`
+	}
+	products := make(map[int64]product, len(skus))
+	for i := 0; i < len(skus); i += maxOffersPerRequest {
+		end := min(i+maxOffersPerRequest, len(skus))
+		var response struct {
+			Items []struct {
+				product
+				Sources []struct {
+					SKU int64 `json:"sku"`
+				} `json:"sources"`
+			} `json:"items"`
+		}
+		if err := api.sellerRequest(ctx, "POST", "/v3/product/info/list", map[string][]string{"sku": skus[i:end]}, &response); err != nil {
+			return fmt.Errorf("ошибка при получении товаров вопросов %w", err)
+		}
+		for _, item := range response.Items {
+			for _, source := range item.Sources {
+				products[source.SKU] = item.product
+			}
+		}
+	}
+	for _, question := range questions {
+		question.OfferID = products[question.SKU].OfferID
+		question.ProductName = products[question.SKU].Name
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package ozon
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// PriceMismatch товар, у которого цена на площадке отличается от ожидаемой
+type PriceMismatch struct {
+	OfferID  string
+	Expected float64
+	Actual   float64
+	Found    bool
+}
+
+func (m PriceMismatch) String() string {
+	if !m.Found {
+		return fmt.Sprintf("{%s ожидалась %.2f, товар не найден}", m.OfferID, m.Expected)
+	}
+	return fmt.Sprintf("{%s ожидалась %.2f, на площадке %.2f}", m.OfferID, m.Expected, m.Actual)
+}
+
+// Reconcile перечитывает цены после задержки, т.к. озон применяет их асинхронно,
+// и повторно отправляет цены, которые не совпали. Возвращает расхождения, оставшиеся после всех попыток
+func (api *Api) Reconcile(ctx context.Context, expected map[string]Price) ([]PriceMismatch, error) {
+	pending := expected
+	var mismatches []PriceMismatch
+	for attempt := 0; attempt <= api.reconcileRetries; attempt++ {
+		select {
+		case <-ctx.Done():
+			return mismatches, ctx.Err()
+		case <-time.After(api.reconcileDelay):
+		}
+		pendingPrices := make(map[string]float64, len(pending))
+		for offerID, price := range pending {
+			pendingPrices[offerID] = price.Price
+		}
+		var err error
+		mismatches, err = api.findMismatches(ctx, pendingPrices)
+		if err != nil {
+			return nil, err
+		}
+		if len(mismatches) == 0 || attempt == api.reconcileRetries {
+			break
+		}
+		retry := make(map[string]Price, len(mismatches))
+		for _, m := range mismatches {
+			retry[m.OfferID] = expected[m.OfferID]
+		}
+		pending = retry
+		slog.Warn("цены не применились, повторная отправка",
+			"offers", len(retry), "attempt", attempt+1, "retries", api.reconcileRetries)
+		if _, err := api.ChangePrice(ctx, pending); err != nil {
+			return mismatches, fmt.Errorf("ошибка при повторной отправке цен %w", err)
+		}
+	}
+	return mismatches, nil
+}
+
+// CheckJournal сравнивает цены на площадке с ценами из журнала
+func (api *Api) CheckJournal(ctx context.Context) ([]PriceMismatch, error) {
+	return api.findMismatches(ctx, api.journal.Expected())
+}
+
+func (api *Api) findMismatches(ctx context.Context, expected map[string]float64) ([]PriceMismatch, error) {
+	offerIDs := make([]string, 0, len(expected))
+	for offerID := range expected {
+		offerIDs = append(offerIDs, offerID)
+	}
+	actual := make(map[string]float64, len(expected))
+	for i := 0; i < len(offerIDs); i += maxOffersPerRequest {
+		end := min(i+maxOffersPerRequest, len(offerIDs))
+		prices, err := api.GetPrice(ctx, offerIDs[i:end])
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при получении цен для сверки %w", err)
+		}
+		for _, item := range prices.Items {
+			actual[item.OfferID] = item.Price.Price
+		}
+	}
+	var mismatches []PriceMismatch
+	for offerID, want := range expected {
+		got, found := actual[offerID]
+		if !found || math.Abs(got-want) >= 0.01 {
+			mismatches = append(mismatches, PriceMismatch{OfferID: offerID, Expected: want, Actual: got, Found: found})
+		}
+	}
+	return mismatches, nil
+}
+
+// Recover восстанавливает состояние после прерванного прохода: повторно отправляет цены из журнала,
+// которые не совпадают с ценами на площадке, и снимает с карантина товары с истекшим сроком.
+// Если в журнале записаны все цены товара, они восстанавливаются целиком, иначе меняется только цена.
+// Возвращает расхождения, которые не удалось устранить
+func (api *Api) Recover(ctx context.Context) ([]PriceMismatch, error) {
+	mismatches, err := api.CheckJournal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var offerIDs []string
+	for _, m := range mismatches {
+		if m.Found {
+			offerIDs = append(offerIDs, m.OfferID)
+		} else {
+			slog.Warn("товар из журнала не найден на площадке", "offer_id", m.OfferID)
+		}
+	}
+	expected := api.journal.Expected()
+	states := api.journal.States()
+	var remaining []PriceMismatch
+	for i := 0; i < len(offerIDs); i += maxOffersPerRequest {
+		end := min(i+maxOffersPerRequest, len(offerIDs))
+		current, err := api.GetPrice(ctx, offerIDs[i:end])
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при получении цен для восстановления %w", err)
+		}
+		restore := make(map[string]Price, len(current.Items))
+		for _, item := range current.Items {
+			if state, ok := states[item.OfferID]; ok {
+				restore[item.OfferID] = state
+				continue
+			}
+			price := item.Price
+			price.Price = expected[item.OfferID]
+			if price.OldPrice != 0 && price.Price >= price.OldPrice {
+				price.OldPrice = 0
+			}
+			restore[item.OfferID] = price
+		}
+		if len(restore) == 0 {
+			continue
+		}
+		slog.Info("восстанавливаю цены из журнала", "offers", len(restore))
+		if _, err := api.ChangePrice(ctx, restore); err != nil {
+			return nil, fmt.Errorf("ошибка при восстановлении цен %w", err)
+		}
+		left, err := api.Reconcile(ctx, restore)
+		if err != nil {
+			return nil, err
+		}
+		remaining = append(remaining, left...)
+	}
+	if _, err := api.ReleaseDue(ctx); err != nil {
+		return remaining, err
+	}
+	return remaining, nil
+}
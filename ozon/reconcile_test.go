@@ -0,0 +1,371 @@
+package ozon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSeller имитирует seller api: отдает текущие цены и применяет новые, кроме товаров из stuck,
+// а также хранит участие товаров в акциях, остатки и архив
+type fakeSeller struct {
+	mu      sync.Mutex
+	prices  map[string]Price
+	stuck   map[string]bool
+	changes int
+	// productIDs идентификаторы товаров по offer_id
+	productIDs map[string]int
+	actions    map[int64][]ActionProduct
+	stocks     map[string]int
+	archived   map[int64]bool
+}
+
+func (s *fakeSeller) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v5/product/info/prices", func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Filter PriceFilter `json:"filter"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("неверный запрос цен %v", err)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var response PriceResponse
+		for _, offerID := range request.Filter.OfferID {
+			if price, ok := s.prices[offerID]; ok {
+				response.Items = append(response.Items, Item{ProductID: s.productIDs[offerID], OfferID: offerID, Price: price})
+			}
+		}
+		json.NewEncoder(w).Encode(response)
+	})
+	mux.HandleFunc("/v1/product/import/prices", func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Prices []struct {
+				OfferID           string `json:"offer_id"`
+				Price             string `json:"price"`
+				OldPrice          string `json:"old_price"`
+				MinPrice          string `json:"min_price"`
+				AutoActionEnabled string `json:"auto_action_enabled"`
+			} `json:"prices"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("неверный запрос изменения цен %v", err)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.changes++
+		var response PriceChangeResponse
+		for _, item := range request.Prices {
+			if !s.stuck[item.OfferID] {
+				price, _ := strconv.ParseFloat(item.Price, 64)
+				oldPrice, _ := strconv.ParseFloat(item.OldPrice, 64)
+				minPrice, _ := strconv.ParseFloat(item.MinPrice, 64)
+				s.prices[item.OfferID] = Price{Price: price, OldPrice: oldPrice, MinPrice: minPrice,
+					AutoActionEnabled: item.AutoActionEnabled == "ENABLED"}
+			}
+			response.Result = append(response.Result, struct {
+				ProductID int     `json:"product_id"`
+				OfferID   string  `json:"offer_id"`
+				Updated   bool    `json:"updated"`
+				Errors    []Error `json:"errors"`
+			}{OfferID: item.OfferID, Updated: true})
+		}
+		json.NewEncoder(w).Encode(response)
+	})
+	s.handleActions(t, mux)
+	s.handleStocks(t, mux)
+	return mux
+}
+
+func (s *fakeSeller) handleActions(t *testing.T, mux *http.ServeMux) {
+	mux.HandleFunc("/v1/actions", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var response struct {
+			Result []Action `json:"result"`
+		}
+		for id, products := range s.actions {
+			response.Result = append(response.Result, Action{ID: id, ParticipatingProductsCount: len(products)})
+		}
+		json.NewEncoder(w).Encode(response)
+	})
+	mux.HandleFunc("/v1/actions/products", func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ActionID int64 `json:"action_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("неверный запрос товаров акции %v", err)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var response struct {
+			Result struct {
+				Products []ActionProduct `json:"products"`
+				Total    int             `json:"total"`
+			} `json:"result"`
+		}
+		response.Result.Products = s.actions[request.ActionID]
+		response.Result.Total = len(response.Result.Products)
+		json.NewEncoder(w).Encode(response)
+	})
+	mux.HandleFunc("/v1/actions/products/deactivate", func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ActionID   int64   `json:"action_id"`
+			ProductIDs []int64 `json:"product_ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("неверный запрос снятия с акции %v", err)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var left []ActionProduct
+		for _, product := range s.actions[request.ActionID] {
+			if !slices.Contains(request.ProductIDs, product.ID) {
+				left = append(left, product)
+			}
+		}
+		s.actions[request.ActionID] = left
+	})
+	mux.HandleFunc("/v1/actions/products/activate", func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ActionID int64 `json:"action_id"`
+			Products []struct {
+				ProductID   int64   `json:"product_id"`
+				ActionPrice float64 `json:"action_price"`
+				Stock       int     `json:"stock"`
+			} `json:"products"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("неверный запрос добавления в акцию %v", err)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, p := range request.Products {
+			s.actions[request.ActionID] = append(s.actions[request.ActionID],
+				ActionProduct{ID: p.ProductID, ActionPrice: p.ActionPrice, Stock: p.Stock})
+		}
+	})
+}
+
+func (s *fakeSeller) handleStocks(t *testing.T, mux *http.ServeMux) {
+	mux.HandleFunc("/v1/product/info/stocks-by-warehouse/fbs", func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			OfferID []string `json:"offer_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("неверный запрос остатков %v", err)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var response struct {
+			Result []WarehouseStock `json:"result"`
+		}
+		for _, offerID := range request.OfferID {
+			response.Result = append(response.Result, WarehouseStock{OfferID: offerID,
+				ProductID: int64(s.productIDs[offerID]), WarehouseID: 1, Present: s.stocks[offerID]})
+		}
+		json.NewEncoder(w).Encode(response)
+	})
+	mux.HandleFunc("/v2/products/stocks", func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Stocks []struct {
+				OfferID     string `json:"offer_id"`
+				Stock       int    `json:"stock"`
+				WarehouseID int64  `json:"warehouse_id"`
+			} `json:"stocks"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("неверный запрос изменения остатков %v", err)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var response struct {
+			Result []map[string]interface{} `json:"result"`
+		}
+		for _, stock := range request.Stocks {
+			s.stocks[stock.OfferID] = stock.Stock
+			response.Result = append(response.Result, map[string]interface{}{
+				"offer_id": stock.OfferID, "warehouse_id": stock.WarehouseID, "updated": true})
+		}
+		json.NewEncoder(w).Encode(response)
+	})
+	archive := func(archived bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var request struct {
+				ProductID []int64 `json:"product_id"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				t.Errorf("неверный запрос архивации %v", err)
+			}
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for _, id := range request.ProductID {
+				s.archived[id] = archived
+			}
+		}
+	}
+	mux.HandleFunc("/v1/product/archive", archive(true))
+	mux.HandleFunc("/v1/product/unarchive", archive(false))
+}
+
+func newTestApi(t *testing.T, seller *fakeSeller) *Api {
+	server := httptest.NewServer(seller.handler(t))
+	t.Cleanup(server.Close)
+	dir := t.TempDir()
+	journal, err := LoadJournal(filepath.Join(dir, "journal.json"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quarantine, err := LoadQuarantineStore(filepath.Join(dir, "quarantine.json"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Api{session: server.Client(), apiURL: server.URL, defaultTimeout: time.Second,
+		reconcileRetries: 2, journal: journal, quarantine: quarantine}
+}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name        string
+		current     map[string]Price
+		stuck       map[string]bool
+		expected    map[string]Price
+		wantChanges int
+		want        []PriceMismatch
+	}{
+		{
+			name:     "цены уже применены",
+			current:  map[string]Price{"a": {Price: 100}, "b": {Price: 200}},
+			expected: map[string]Price{"a": {Price: 100}, "b": {Price: 200.004}},
+		},
+		{
+			name:        "цена применяется после повторной отправки",
+			current:     map[string]Price{"a": {Price: 100}, "b": {Price: 150}},
+			expected:    map[string]Price{"a": {Price: 100}, "b": {Price: 200}},
+			wantChanges: 1,
+		},
+		{
+			name:        "цена не применяется",
+			current:     map[string]Price{"a": {Price: 150}},
+			stuck:       map[string]bool{"a": true},
+			expected:    map[string]Price{"a": {Price: 100}},
+			wantChanges: 2,
+			want:        []PriceMismatch{{OfferID: "a", Expected: 100, Actual: 150, Found: true}},
+		},
+		{
+			name:        "товар не найден",
+			current:     map[string]Price{},
+			stuck:       map[string]bool{"a": true},
+			expected:    map[string]Price{"a": {Price: 100}},
+			wantChanges: 2,
+			want:        []PriceMismatch{{OfferID: "a", Expected: 100}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seller := &fakeSeller{prices: tt.current, stuck: tt.stuck}
+			api := newTestApi(t, seller)
+			got, err := api.Reconcile(context.Background(), tt.expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("получено %v, ожидалось %v", got, tt.want)
+			}
+			if seller.changes != tt.wantChanges {
+				t.Errorf("отправок цен %d, ожидалось %d", seller.changes, tt.wantChanges)
+			}
+		})
+	}
+}
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name    string
+		current map[string]Price
+		// journal цены, записанные без сопутствующих цен
+		journal map[string]float64
+		// states цены, записанные вместе с сопутствующими
+		states  map[string]Price
+		want    map[string]Price
+		missing []string
+	}{
+		{
+			name:    "полное состояние из журнала",
+			current: map[string]Price{"a": {Price: 10000, MinPrice: 50}},
+			states:  map[string]Price{"a": {Price: 100, OldPrice: 150, MinPrice: 50, AutoActionEnabled: true}},
+			want:    map[string]Price{"a": {Price: 100, OldPrice: 150, MinPrice: 50, AutoActionEnabled: true}},
+		},
+		{
+			name:    "только цена, зачеркнутая цена ниже новой сбрасывается",
+			current: map[string]Price{"a": {Price: 100, OldPrice: 150}},
+			journal: map[string]float64{"a": 200},
+			want:    map[string]Price{"a": {Price: 200}},
+		},
+		{
+			name:    "совпадающие цены не отправляются",
+			current: map[string]Price{"a": {Price: 100, OldPrice: 150}, "b": {Price: 300}},
+			journal: map[string]float64{"a": 100},
+			states:  map[string]Price{"b": {Price: 300}},
+			want:    map[string]Price{"a": {Price: 100, OldPrice: 150}, "b": {Price: 300}},
+		},
+		{
+			name:    "товар не найден на площадке",
+			current: map[string]Price{"a": {Price: 100}},
+			journal: map[string]float64{"a": 100, "gone": 100},
+			want:    map[string]Price{"a": {Price: 100}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seller := &fakeSeller{prices: tt.current}
+			api := newTestApi(t, seller)
+			if err := api.journal.Record(tt.journal); err != nil {
+				t.Fatal(err)
+			}
+			if err := api.journal.RecordPrices(tt.states); err != nil {
+				t.Fatal(err)
+			}
+			remaining, err := api.Recover(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(remaining) != 0 {
+				t.Errorf("остались расхождения %v", remaining)
+			}
+			if !reflect.DeepEqual(seller.prices, tt.want) {
+				t.Errorf("получено %v, ожидалось %v", seller.prices, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckJournal(t *testing.T) {
+	seller := &fakeSeller{prices: map[string]Price{"a": {Price: 100}, "b": {Price: 250}}}
+	api := newTestApi(t, seller)
+	if err := api.journal.Record(map[string]float64{"a": 100, "b": 200, "c": 300}); err != nil {
+		t.Fatal(err)
+	}
+	mismatches, err := api.CheckJournal(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(mismatches, func(i, j int) bool { return mismatches[i].OfferID < mismatches[j].OfferID })
+	want := []PriceMismatch{
+		{OfferID: "b", Expected: 200, Actual: 250, Found: true},
+		{OfferID: "c", Expected: 300},
+	}
+	if !reflect.DeepEqual(mismatches, want) {
+		t.Errorf("получено %v, ожидалось %v", mismatches, want)
+	}
+}
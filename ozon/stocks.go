@@ -0,0 +1,87 @@
+package ozon
+
+import (
+	"context"
+	"fmt"
+)
+
+// WarehouseStock остаток товара на складе FBS
+type WarehouseStock struct {
+	OfferID     string `json:"offer_id"`
+	ProductID   int64  `json:"product_id"`
+	WarehouseID int64  `json:"warehouse_id"`
+	Present     int    `json:"present"`
+	Reserved    int    `json:"reserved"`
+}
+
+// GetFBSStocks возвращает остатки товаров на складах FBS
+func (api *Api) GetFBSStocks(ctx context.Context, offerIDs []string) ([]WarehouseStock, error) {
+	type Request struct {
+		OfferID []string `json:"offer_id"`
+	}
+	var response struct {
+		Result []WarehouseStock `json:"result"`
+	}
+	if err := api.sellerRequest(ctx, "POST", "/v1/product/info/stocks-by-warehouse/fbs", Request{offerIDs}, &response); err != nil {
+		return nil, fmt.Errorf("ошибка при получении остатков %w", err)
+	}
+	return response.Result, nil
+}
+
+// SetFBSStocks обновляет остатки на складах. Present используется как новое значение остатка
+func (api *Api) SetFBSStocks(ctx context.Context, stocks []WarehouseStock) error {
+	type Stock struct {
+		OfferID     string `json:"offer_id"`
+		ProductID   int64  `json:"product_id"`
+		Stock       int    `json:"stock"`
+		WarehouseID int64  `json:"warehouse_id"`
+	}
+	type Request struct {
+		Stocks []Stock `json:"stocks"`
+	}
+	var response struct {
+		Result []struct {
+			OfferID     string  `json:"offer_id"`
+			WarehouseID int64   `json:"warehouse_id"`
+			Updated     bool    `json:"updated"`
+			Errors      []Error `json:"errors"`
+		} `json:"result"`
+	}
+	request := Request{}
+	for _, s := range stocks {
+		request.Stocks = append(request.Stocks, Stock{s.OfferID, s.ProductID, s.Present, s.WarehouseID})
+	}
+	if err := api.sellerRequest(ctx, "POST", "/v2/products/stocks", request, &response); err != nil {
+		return fmt.Errorf("ошибка при обновлении остатков %w", err)
+	}
+	var failed []string
+	for _, res := range response.Result {
+		if !res.Updated {
+			failed = append(failed, fmt.Sprintf("%s/%d %v", res.OfferID, res.WarehouseID, res.Errors))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("не обновлены остатки %v", failed)
+	}
+	return nil
+}
+
+func (api *Api) ArchiveProducts(ctx context.Context, productIDs []int64) error {
+	type Request struct {
+		ProductID []int64 `json:"product_id"`
+	}
+	if err := api.sellerRequest(ctx, "POST", "/v1/product/archive", Request{productIDs}, nil); err != nil {
+		return fmt.Errorf("ошибка при архивации товаров %w", err)
+	}
+	return nil
+}
+
+func (api *Api) UnarchiveProducts(ctx context.Context, productIDs []int64) error {
+	type Request struct {
+		ProductID []int64 `json:"product_id"`
+	}
+	if err := api.sellerRequest(ctx, "POST", "/v1/product/unarchive", Request{productIDs}, nil); err != nil {
+		return fmt.Errorf("ошибка при восстановлении товаров из архива %w", err)
+	}
+	return nil
+}
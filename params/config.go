@@ -0,0 +1,736 @@
+package params
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/complaints"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config типизированная конфигурация. Загружается из TOML файла, после чего
+// значения переопределяются переменными окружения, указанными в теге env
+type Config struct {
+	Profile string `toml:"profile" env:"PROFILE"`
+	Account string `toml:"-" env:"ACCOUNT"`
+	// Marketplace площадка кабинета: ozon, wildberries или yandex
+	Marketplace string            `toml:"marketplace" env:"MARKETPLACE"`
+	Gigachat    GigachatConfig    `toml:"gigachat"`
+	Ozon        OzonConfig        `toml:"ozon"`
+	Wildberries WildberriesConfig `toml:"wildberries"`
+	Yandex      YandexConfig      `toml:"yandex"`
+	Quarantine  QuarantineConfig  `toml:"quarantine"`
+	Links       LinksConfig       `toml:"links"`
+	Prices      PricesConfig      `toml:"prices"`
+	Archive     ArchiveConfig     `toml:"archive"`
+	Notify      NotifyConfig      `toml:"notify"`
+	Digest      DigestConfig      `toml:"digest"`
+	Schedule    ScheduleConfig    `toml:"schedule"`
+	HTTP        HTTPConfig        `toml:"http"`
+	Log         LogConfig         `toml:"log"`
+}
+
+type GigachatConfig struct {
+	AuthData   string `toml:"auth_data" env:"GIGACHAT_AUTH_DATA"`
+	Prompt     string `toml:"prompt" env:"GIGACHAT_PROMPT"`
+	PromptFile string `toml:"prompt_file" env:"GIGACHAT_PROMPT_FILE"`
+	// QuestionPrompt промпт для вопросов покупателей: модель отвечает "дефект", если покупатель сообщает о браке
+	QuestionPrompt     string `toml:"question_prompt" env:"GIGACHAT_QUESTION_PROMPT"`
+	QuestionPromptFile string `toml:"question_prompt_file" env:"GIGACHAT_QUESTION_PROMPT_FILE"`
+	Model              string `toml:"model" env:"GIGACHAT_MODEL"`
+	// TokenBudget сколько токенов можно израсходовать за сутки, 0 без ограничения
+	TokenBudget int           `toml:"token_budget" env:"GIGACHAT_TOKEN_BUDGET"`
+	URL         string        `toml:"url" env:"GIGACHAT_URL"`
+	AuthURL     string        `toml:"auth_url" env:"GIGACHAT_AUTH_URL"`
+	Timeout     time.Duration `toml:"timeout" env:"GIGACHAT_TIMEOUT"`
+}
+
+type OzonConfig struct {
+	CompanyID     string `toml:"company_id" env:"COMPANY_ID"`
+	ClientID      string `toml:"client_id" env:"CLIENT_ID"`
+	APIKey        string `toml:"api_key" env:"API_KEY"`
+	CookiesPath   string `toml:"cookies_path" env:"COOKIES_PATH"`
+	WatermarkPath string `toml:"watermark_path" env:"WATERMARK_PATH"`
+	// Questions анализировать вопросы покупателей вместе с отзывами
+	Questions              bool                     `toml:"questions" env:"OZON_QUESTIONS"`
+	QuestionsWatermarkPath string                   `toml:"questions_watermark_path" env:"OZON_QUESTIONS_WATERMARK_PATH"`
+	APIURL                 string                   `toml:"api_url" env:"OZON_API_URL"`
+	SellerURL              string                   `toml:"seller_url" env:"OZON_SELLER_URL"`
+	Timeout                time.Duration            `toml:"timeout" env:"OZON_TIMEOUT"`
+	EndpointTimeouts       map[string]time.Duration `toml:"endpoint_timeouts" env:"OZON_ENDPOINT_TIMEOUTS"`
+}
+
+type WildberriesConfig struct {
+	Token        string        `toml:"token" env:"WB_TOKEN"`
+	FeedbacksURL string        `toml:"feedbacks_url" env:"WB_FEEDBACKS_URL"`
+	PricesURL    string        `toml:"prices_url" env:"WB_PRICES_URL"`
+	ContentURL   string        `toml:"content_url" env:"WB_CONTENT_URL"`
+	Timeout      time.Duration `toml:"timeout" env:"WB_TIMEOUT"`
+}
+
+// YandexConfig Партнерское API Яндекс Маркета. Отзывы относятся к кабинету (business_id), цены к магазину (campaign_id)
+type YandexConfig struct {
+	Token      string        `toml:"token" env:"YANDEX_TOKEN"`
+	BusinessID string        `toml:"business_id" env:"YANDEX_BUSINESS_ID"`
+	CampaignID string        `toml:"campaign_id" env:"YANDEX_CAMPAIGN_ID"`
+	URL        string        `toml:"url" env:"YANDEX_URL"`
+	Timeout    time.Duration `toml:"timeout" env:"YANDEX_TIMEOUT"`
+}
+
+type QuarantineConfig struct {
+	Action             string            `toml:"action" env:"QUARANTINE_ACTION"`
+	OfferActions       map[string]string `toml:"offer_actions" env:"QUARANTINE_OFFER_ACTIONS"`
+	CategoryActions    map[string]string `toml:"category_actions" env:"QUARANTINE_CATEGORY_ACTIONS"`
+	Duration           time.Duration     `toml:"duration" env:"QUARANTINE_DURATION"`
+	PriceCoefficient   float64           `toml:"price_coefficient" env:"QUARANTINE_PRICE_COEFFICIENT"`
+	MinNegativeReviews int               `toml:"min_negative_reviews" env:"QUARANTINE_MIN_NEGATIVE_REVIEWS"`
+	StatePath          string            `toml:"state_path" env:"QUARANTINE_STATE_PATH"`
+	DryRun             bool              `toml:"dry_run" env:"DRY_RUN"`
+	// Approval ставить товары в очередь подтверждения вместо немедленного карантина
+	Approval     bool          `toml:"approval" env:"QUARANTINE_APPROVAL"`
+	ApprovalPath string        `toml:"approval_path" env:"QUARANTINE_APPROVAL_PATH"`
+	ApprovalTTL  time.Duration `toml:"approval_ttl" env:"QUARANTINE_APPROVAL_TTL"`
+}
+
+// LinksConfig связи одинаковых товаров разных кабинетов. Таблица связей общая для всех кабинетов
+type LinksConfig struct {
+	Path string `toml:"path" env:"LINKS_PATH"`
+	// Match способы поиска связанных товаров через запятую: offer_id, barcode, manual
+	Match string `toml:"match" env:"LINKS_MATCH"`
+	// Propagate помещать на карантин связанные товары других кабинетов вместе с исходным
+	Propagate bool `toml:"propagate" env:"LINKS_PROPAGATE"`
+}
+
+type PricesConfig struct {
+	JournalPath string `toml:"journal_path" env:"PRICE_JOURNAL_PATH"`
+	// JournalRetention сколько хранится запись журнала о товаре, цена которого больше не менялась, 0 без ограничения
+	JournalRetention time.Duration `toml:"journal_retention" env:"PRICE_JOURNAL_RETENTION"`
+	ReconcileDelay   time.Duration `toml:"reconcile_delay" env:"PRICE_RECONCILE_DELAY"`
+	ReconcileRetries int           `toml:"reconcile_retries" env:"PRICE_RECONCILE_RETRIES"`
+}
+
+type ArchiveConfig struct {
+	Path string `toml:"path" env:"ARCHIVE_PATH"`
+}
+
+// NotifyConfig каналы уведомлений. Канал включен, если задан его адрес или токен.
+// events список событий через запятую, пустой список означает все события
+type NotifyConfig struct {
+	Telegram TelegramConfig `toml:"telegram"`
+	Webhook  WebhookConfig  `toml:"webhook"`
+	SMTP     SMTPConfig     `toml:"smtp"`
+}
+
+type TelegramConfig struct {
+	URL    string `toml:"url" env:"NOTIFY_TELEGRAM_URL"`
+	Token  string `toml:"token" env:"NOTIFY_TELEGRAM_TOKEN"`
+	ChatID string `toml:"chat_id" env:"NOTIFY_TELEGRAM_CHAT_ID"`
+	Events string `toml:"events" env:"NOTIFY_TELEGRAM_EVENTS"`
+}
+
+type WebhookConfig struct {
+	URL    string `toml:"url" env:"NOTIFY_WEBHOOK_URL"`
+	Events string `toml:"events" env:"NOTIFY_WEBHOOK_EVENTS"`
+}
+
+type SMTPConfig struct {
+	Addr     string `toml:"addr" env:"NOTIFY_SMTP_ADDR"`
+	Username string `toml:"username" env:"NOTIFY_SMTP_USERNAME"`
+	Password string `toml:"password" env:"NOTIFY_SMTP_PASSWORD"`
+	From     string `toml:"from" env:"NOTIFY_SMTP_FROM"`
+	// To адреса получателей через запятую
+	To     string `toml:"to" env:"NOTIFY_SMTP_TO"`
+	Events string `toml:"events" env:"NOTIFY_SMTP_EVENTS"`
+}
+
+type DigestConfig struct {
+	// Time время отправки сводки за прошедший день в формате 15:04, пустое значение отключает сводку
+	Time string `toml:"time" env:"DIGEST_TIME"`
+	// StatePath файл с днем последней отправленной сводки по кабинетам
+	StatePath string `toml:"state_path" env:"DIGEST_STATE_PATH"`
+}
+
+type ScheduleConfig struct {
+	PollInterval time.Duration `toml:"poll_interval" env:"POLL_INTERVAL"`
+}
+
+type HTTPConfig struct {
+	Addr  string `toml:"addr" env:"HTTP_ADDR"`
+	Token string `toml:"token" env:"HTTP_TOKEN"`
+}
+
+type LogConfig struct {
+	Level  string `toml:"level" env:"LOG_LEVEL"`
+	Format string `toml:"format" env:"LOG_FORMAT"`
+}
+
+// DefaultConfig значения по умолчанию
+func DefaultConfig() Config {
+	return Config{
+		Profile:     "prod",
+		Marketplace: "ozon",
+		Gigachat: GigachatConfig{
+			Model:   "GigaChat",
+			URL:     "gigachat.devices.sberbank.ru",
+			AuthURL: "https://ngw.devices.sberbank.ru:9443/api/v2/oauth",
+			Timeout: 30 * time.Second,
+		},
+		Ozon: OzonConfig{
+			APIURL:                 "https://api-seller.ozon.ru",
+			SellerURL:              "https://seller.ozon.ru",
+			WatermarkPath:          "start.txt",
+			QuestionsWatermarkPath: "questions.start.txt",
+			Timeout:                30 * time.Second,
+		},
+		Wildberries: WildberriesConfig{
+			FeedbacksURL: "https://feedbacks-api.wildberries.ru",
+			PricesURL:    "https://discounts-prices-api.wildberries.ru",
+			ContentURL:   "https://content-api.wildberries.ru",
+			Timeout:      30 * time.Second,
+		},
+		Yandex: YandexConfig{
+			URL:     "https://api.partner.market.yandex.ru",
+			Timeout: 30 * time.Second,
+		},
+		Quarantine: QuarantineConfig{
+			Action:             "price",
+			Duration:           72 * time.Hour,
+			PriceCoefficient:   100,
+			MinNegativeReviews: 1,
+			StatePath:          "quarantine.json",
+			ApprovalPath:       "approvals.json",
+			ApprovalTTL:        24 * time.Hour,
+		},
+		Links: LinksConfig{Path: "links.json", Match: "barcode,manual"},
+		Prices: PricesConfig{
+			JournalPath:      "journal.json",
+			JournalRetention: 30 * 24 * time.Hour,
+			ReconcileDelay:   30 * time.Second,
+			ReconcileRetries: 3,
+		},
+		Archive:  ArchiveConfig{Path: "reviews.jsonl"},
+		Digest:   DigestConfig{StatePath: "digest.json"},
+		Notify:   NotifyConfig{Telegram: TelegramConfig{URL: "https://api.telegram.org"}},
+		Schedule: ScheduleConfig{PollInterval: 15 * time.Minute},
+		Log:      LogConfig{Level: "info", Format: "text"},
+	}
+}
+
+// builtinProfiles встроенные профили. Профиль из файла ([profiles.<имя>]) применяется поверх встроенного
+var builtinProfiles = map[string]map[string]string{
+	"prod":    {},
+	"staging": {"log.level": "debug"},
+	"dry-run": {"quarantine.dry_run": "true", "log.level": "debug"},
+}
+
+// LoadConfig загружает конфигурацию из файла path (может быть пустым), применяя профиль и переменные окружения.
+// Все найденные ошибки возвращаются одной ошибкой. Если в файле описано несколько кабинетов, возвращается первый
+func LoadConfig(path string) (Config, error) {
+	configs, err := LoadConfigs(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return configs[0], nil
+}
+
+// LoadConfigs загружает конфигурацию каждого кабинета из таблиц [accounts.<имя>].
+// Кабинет получает общие настройки файла, профиля и окружения, поверх которых применяются его собственные.
+// Если кабинеты не описаны, возвращается одна конфигурация без имени
+func LoadConfigs(path string) ([]Config, error) {
+	values := make(map[string]string)
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, apperrors.Wrap(apperrors.Config, err, "не удалось открыть файл конфигурации")
+		}
+		defer file.Close()
+		if values, err = parseTOML(file); err != nil {
+			return nil, apperrors.Wrap(apperrors.Config, err, "ошибка в файле конфигурации %s", path)
+		}
+	}
+	accounts := accountNames(values)
+	if len(accounts) == 0 {
+		accounts = []string{""}
+	}
+	var configs []Config
+	problems := unknownKeys(values)
+	for _, account := range accounts {
+		cfg, accountProblems := buildConfig(values, account)
+		for _, problem := range accountProblems {
+			if account != "" {
+				problem = fmt.Errorf("accounts.%s: %w", account, problem)
+			}
+			problems = append(problems, problem)
+		}
+		configs = append(configs, cfg)
+	}
+	if len(problems) > 0 {
+		return nil, &apperrors.Error{Kind: apperrors.Config, Msg: "ошибки конфигурации", Err: errors.Join(problems...)}
+	}
+	return configs, nil
+}
+
+// unknownKeys ключи файла, которых нет в Config. Без проверки опечатка в имени ключа,
+// например quarantine.ofer_actions, молча оставила бы значение по умолчанию
+func unknownKeys(values map[string]string) []error {
+	known := make(map[string]bool)
+	tables := make(map[string]bool)
+	configKeys(reflect.TypeOf(Config{}), "", known, tables)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var problems []error
+	for _, key := range keys {
+		name, nested := key, false
+		for _, section := range []string{"profiles.", "accounts."} {
+			if rest, ok := strings.CutPrefix(key, section); ok {
+				_, name, _ = strings.Cut(rest, ".")
+				nested = true
+			}
+		}
+		// профиль выбирается один на весь файл
+		if name == "profile" && nested {
+			problems = append(problems, fmt.Errorf("%s: профиль задается только на верхнем уровне файла", key))
+			continue
+		}
+		if !known[name] && !inTable(name, tables) {
+			problems = append(problems, fmt.Errorf("неизвестный ключ конфигурации %q", key))
+		}
+	}
+	return problems
+}
+
+// configKeys собирает ключи полей t в known, а ключи таблиц с произвольными ключами в tables
+func configKeys(t reflect.Type, prefix string, known, tables map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("toml")
+		if key == "" || key == "-" {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			configKeys(field.Type, key, known, tables)
+		case reflect.Map:
+			tables[key] = true
+		default:
+			known[key] = true
+		}
+	}
+}
+
+func inTable(key string, tables map[string]bool) bool {
+	for table := range tables {
+		if strings.HasPrefix(key, table+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func accountNames(values map[string]string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for key := range values {
+		rest, ok := strings.CutPrefix(key, "accounts.")
+		if !ok {
+			continue
+		}
+		name, _, _ := strings.Cut(rest, ".")
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func buildConfig(values map[string]string, account string) (Config, []error) {
+	cfg := DefaultConfig()
+	profile := values["profile"]
+	if env, ok := os.LookupEnv("PROFILE"); ok {
+		profile = env
+	}
+	if profile == "" {
+		profile = cfg.Profile
+	}
+	var problems []error
+	base := make(map[string]string)
+	fromProfile := make(map[string]string)
+	fromAccount := make(map[string]string)
+	for key, value := range values {
+		if name, ok := strings.CutPrefix(key, "profiles."); ok {
+			if rest, ok := strings.CutPrefix(name, profile+"."); ok {
+				fromProfile[rest] = value
+			}
+			continue
+		}
+		if name, ok := strings.CutPrefix(key, "accounts."); ok {
+			if rest, ok := strings.CutPrefix(name, account+"."); ok && account != "" {
+				fromAccount[rest] = value
+			}
+			continue
+		}
+		base[key] = value
+	}
+	builtin, isBuiltin := builtinProfiles[profile]
+	if !isBuiltin && !hasProfile(values, profile) {
+		problems = append(problems, fmt.Errorf("профиль %q не найден", profile))
+	}
+	for _, layer := range []map[string]string{base, builtin, fromProfile, fromAccount} {
+		problems = append(problems, decode(reflect.ValueOf(&cfg).Elem(), "", layer)...)
+	}
+	// окружение применяется последним, чтобы пустой секрет в файле не затирал заданный в окружении.
+	// Переменные кабинета ACCOUNT_<ИМЯ>_<ПЕРЕМЕННАЯ> важнее общих
+	problems = append(problems, applyEnv(reflect.ValueOf(&cfg).Elem(), "", "", nil)...)
+	fromAccountEnv := make(map[string]bool)
+	if account != "" {
+		problems = append(problems, applyEnv(reflect.ValueOf(&cfg).Elem(), accountEnvPrefix(account), "", fromAccountEnv)...)
+	}
+	cfg.Profile = profile
+	cfg.Account = account
+	if account != "" {
+		// состояние кабинетов хранится в отдельных файлах, если пути не заданы для кабинета явно
+		isolate := map[string]*string{
+			"ozon.watermark_path":           &cfg.Ozon.WatermarkPath,
+			"ozon.questions_watermark_path": &cfg.Ozon.QuestionsWatermarkPath,
+			"prices.journal_path":           &cfg.Prices.JournalPath,
+			"quarantine.state_path":         &cfg.Quarantine.StatePath,
+			"archive.path":                  &cfg.Archive.Path,
+			"quarantine.approval_path":      &cfg.Quarantine.ApprovalPath,
+		}
+		for key, path := range isolate {
+			if _, ok := fromAccount[key]; !ok && !fromAccountEnv[key] {
+				*path = filepath.Join(filepath.Dir(*path), account+"."+filepath.Base(*path))
+			}
+		}
+	}
+	if cfg.Gigachat.PromptFile != "" {
+		prompt, err := os.ReadFile(cfg.Gigachat.PromptFile)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("gigachat.prompt_file: %w", err))
+		}
+		cfg.Gigachat.Prompt = strings.TrimSpace(string(prompt))
+	}
+	if cfg.Gigachat.QuestionPromptFile != "" {
+		prompt, err := os.ReadFile(cfg.Gigachat.QuestionPromptFile)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("gigachat.question_prompt_file: %w", err))
+		}
+		cfg.Gigachat.QuestionPrompt = strings.TrimSpace(string(prompt))
+	}
+	problems = append(problems, cfg.Validate()...)
+	return cfg, problems
+}
+
+func hasProfile(values map[string]string, profile string) bool {
+	for key := range values {
+		if strings.HasPrefix(key, "profiles."+profile+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate возвращает все найденные проблемы, а не только первую
+func (cfg Config) Validate() []error {
+	var problems []error
+	required := map[string]string{
+		"gigachat.auth_data":  cfg.Gigachat.AuthData,
+		"gigachat.prompt":     cfg.Gigachat.Prompt,
+		"gigachat.model":      cfg.Gigachat.Model,
+		"quarantine.action":   cfg.Quarantine.Action,
+		"prices.journal_path": cfg.Prices.JournalPath,
+	}
+	switch cfg.Marketplace {
+	case "ozon":
+		required["ozon.company_id"] = cfg.Ozon.CompanyID
+		required["ozon.client_id"] = cfg.Ozon.ClientID
+		required["ozon.api_key"] = cfg.Ozon.APIKey
+		required["ozon.cookies_path"] = cfg.Ozon.CookiesPath
+	case "wildberries":
+		required["wildberries.token"] = cfg.Wildberries.Token
+		// у wildberries есть только карантин ценой, а снижение цены более чем в 3 раза
+		// площадка сама отправляет в карантин цен, и восстановить цену не получится
+		if cfg.Quarantine.PriceCoefficient >= 3 {
+			problems = append(problems, fmt.Errorf("quarantine.price_coefficient для wildberries должен быть меньше 3"))
+		}
+	case "yandex":
+		required["yandex.token"] = cfg.Yandex.Token
+		required["yandex.business_id"] = cfg.Yandex.BusinessID
+		required["yandex.campaign_id"] = cfg.Yandex.CampaignID
+	default:
+		problems = append(problems, fmt.Errorf("неизвестная площадка %q, ожидается ozon, wildberries или yandex", cfg.Marketplace))
+	}
+	// на площадках кроме озона карантин возможен только ценой
+	if cfg.Marketplace != "ozon" &&
+		(cfg.Quarantine.Action != "price" || len(cfg.Quarantine.OfferActions) > 0 || len(cfg.Quarantine.CategoryActions) > 0) {
+		problems = append(problems, fmt.Errorf("для %s поддерживается только действие карантина price", cfg.Marketplace))
+	}
+	keys := make([]string, 0, len(required))
+	for key := range required {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if strings.TrimSpace(required[key]) == "" {
+			problems = append(problems, fmt.Errorf("%s не задан", key))
+		}
+	}
+	if cfg.Quarantine.PriceCoefficient <= 1 {
+		problems = append(problems, fmt.Errorf("quarantine.price_coefficient должен быть больше 1"))
+	}
+	if cfg.Quarantine.MinNegativeReviews < 1 {
+		problems = append(problems, fmt.Errorf("quarantine.min_negative_reviews должен быть не меньше 1"))
+	}
+	if cfg.Quarantine.Duration <= 0 {
+		problems = append(problems, fmt.Errorf("quarantine.duration должен быть больше нуля"))
+	}
+	// повышенная цена на wildberries и маркете держится весь карантин, и ее запись не должна устареть раньше
+	if cfg.Prices.JournalRetention < 0 || (cfg.Prices.JournalRetention > 0 && cfg.Prices.JournalRetention <= cfg.Quarantine.Duration) {
+		problems = append(problems, fmt.Errorf("prices.journal_retention должен быть больше quarantine.duration или 0 (без ограничения)"))
+	}
+	if cfg.Prices.ReconcileRetries < 0 {
+		problems = append(problems, fmt.Errorf("prices.reconcile_retries не может быть отрицательным"))
+	}
+	if cfg.Schedule.PollInterval <= 0 {
+		problems = append(problems, fmt.Errorf("schedule.poll_interval должен быть больше нуля"))
+	}
+	if cfg.Ozon.Timeout <= 0 || cfg.Gigachat.Timeout <= 0 || cfg.Wildberries.Timeout <= 0 || cfg.Yandex.Timeout <= 0 {
+		problems = append(problems, fmt.Errorf("таймауты должны быть больше нуля"))
+	}
+	for endpoint, timeout := range cfg.Ozon.EndpointTimeouts {
+		if timeout <= 0 {
+			problems = append(problems, fmt.Errorf("ozon.endpoint_timeouts.%s должен быть больше нуля", endpoint))
+		}
+	}
+	if cfg.Ozon.Questions {
+		if cfg.Marketplace != "ozon" {
+			problems = append(problems, fmt.Errorf("ozon.questions поддерживается только для озона"))
+		}
+		if strings.TrimSpace(cfg.Gigachat.QuestionPrompt) == "" {
+			problems = append(problems, fmt.Errorf("gigachat.question_prompt не задан, а ozon.questions включен"))
+		}
+	}
+	if cfg.Quarantine.Approval && cfg.Quarantine.ApprovalTTL <= 0 {
+		problems = append(problems, fmt.Errorf("quarantine.approval_ttl должен быть больше нуля"))
+	}
+	categories := make([]string, 0, len(cfg.Quarantine.CategoryActions))
+	for category := range cfg.Quarantine.CategoryActions {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		if !complaints.Known(category) {
+			problems = append(problems, fmt.Errorf("quarantine.category_actions: неизвестная категория жалоб %q", category))
+		}
+	}
+	for _, match := range strings.Split(cfg.Links.Match, ",") {
+		switch strings.TrimSpace(match) {
+		case "", "offer_id", "barcode", "manual":
+		default:
+			problems = append(problems, fmt.Errorf("links.match: неизвестный способ связи %q, ожидается offer_id, barcode или manual", match))
+		}
+	}
+	if cfg.Links.Propagate && cfg.Links.Path == "" {
+		problems = append(problems, fmt.Errorf("links.path не задан"))
+	}
+	if cfg.Gigachat.TokenBudget < 0 {
+		problems = append(problems, fmt.Errorf("gigachat.token_budget не может быть отрицательным"))
+	}
+	if cfg.Notify.Telegram.Token != "" && cfg.Notify.Telegram.ChatID == "" {
+		problems = append(problems, fmt.Errorf("notify.telegram.chat_id обязателен, если задан notify.telegram.token"))
+	}
+	if cfg.Notify.SMTP.Addr != "" && (cfg.Notify.SMTP.From == "" || cfg.Notify.SMTP.To == "") {
+		problems = append(problems, fmt.Errorf("notify.smtp.from и notify.smtp.to обязательны, если задан notify.smtp.addr"))
+	}
+	if cfg.Digest.Time != "" {
+		if _, err := time.Parse("15:04", cfg.Digest.Time); err != nil {
+			problems = append(problems, fmt.Errorf("digest.time должен быть в формате ЧЧ:ММ"))
+		}
+		if cfg.Digest.StatePath == "" {
+			problems = append(problems, fmt.Errorf("digest.state_path обязателен, если задан digest.time"))
+		}
+	}
+	if cfg.HTTP.Addr != "" && cfg.HTTP.Token == "" {
+		problems = append(problems, fmt.Errorf("http.token обязателен, если задан http.addr"))
+	}
+	return problems
+}
+
+func decode(v reflect.Value, prefix string, values map[string]string) []error {
+	var problems []error
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("toml")
+		if key == "" || key == "-" {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		fv := v.Field(i)
+		switch {
+		case fv.Kind() == reflect.Struct:
+			problems = append(problems, decode(fv, key, values)...)
+		case fv.Kind() == reflect.Map:
+			for k, value := range values {
+				if name, ok := strings.CutPrefix(k, key+"."); ok {
+					if err := setMapValue(fv, name, value); err != nil {
+						problems = append(problems, fmt.Errorf("%s: %w", k, err))
+					}
+				}
+			}
+		default:
+			if value, ok := values[key]; ok {
+				if err := setValue(fv, value); err != nil {
+					problems = append(problems, fmt.Errorf("%s: %w", key, err))
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// applyEnv применяет переменные окружения с префиксом envPrefix. Если set не nil, в него
+// записываются ключи файла конфигурации, значения которых заданы в окружении
+func applyEnv(v reflect.Value, envPrefix, keyPrefix string, set map[string]bool) []error {
+	var problems []error
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+		key := field.Tag.Get("toml")
+		if keyPrefix != "" {
+			key = keyPrefix + "." + key
+		}
+		if fv.Kind() == reflect.Struct {
+			problems = append(problems, applyEnv(fv, envPrefix, key, set)...)
+			continue
+		}
+		name := field.Tag.Get("env")
+		if name == "" || field.Tag.Get("toml") == "-" {
+			continue
+		}
+		name = envPrefix + name
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if set != nil {
+			set[key] = true
+		}
+		if fv.Kind() == reflect.Map {
+			pairs, err := parsePairs(value)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("%s: %w", name, err))
+				continue
+			}
+			fv.Set(reflect.Zero(fv.Type()))
+			for k, v := range pairs {
+				if err := setMapValue(fv, k, v); err != nil {
+					problems = append(problems, fmt.Errorf("%s: %s: %w", name, k, err))
+				}
+			}
+			continue
+		}
+		if err := setValue(fv, value); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return problems
+}
+
+// accountEnvPrefix префикс переменных окружения кабинета: ACCOUNT_<ИМЯ>_, где в имени кабинета
+// буквы переведены в верхний регистр, а остальные символы кроме цифр заменены на _
+func accountEnvPrefix(account string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, account)
+	return "ACCOUNT_" + name + "_"
+}
+
+// setMapValue добавляет в таблицу v значение s, приведенное к типу элементов таблицы
+func setMapValue(v reflect.Value, key, s string) error {
+	elem := reflect.New(v.Type().Elem()).Elem()
+	if err := setValue(elem, s); err != nil {
+		return err
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+	v.SetMapIndex(reflect.ValueOf(key), elem)
+	return nil
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("ожидается целое число, получено %q", s)
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("ожидается число, получено %q", s)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("ожидается true или false, получено %q", s)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("неподдерживаемый тип %s", v.Type())
+	}
+	return nil
+}
+
+// parsePairs разбирает строку вида "ключ=значение,ключ=значение"
+func parsePairs(s string) (map[string]string, error) {
+	res := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("ожидается ключ=значение, получено %q", pair)
+		}
+		res[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return res, nil
+}
@@ -0,0 +1,157 @@
+package params
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildConfigLayers(t *testing.T) {
+	values := map[string]string{
+		"ozon.api_key":                  "file",
+		"ozon.watermark_path":           "state/start.txt",
+		"accounts.main.ozon.api_key":    "",
+		"accounts.main.ozon.client_id":  "1",
+		"accounts.other.ozon.client_id": "2",
+	}
+	tests := []struct {
+		name          string
+		account       string
+		env           map[string]string
+		wantAPIKey    string
+		wantWatermark string
+	}{
+		{
+			name:          "пустое значение кабинета затирает файл",
+			account:       "main",
+			wantAPIKey:    "",
+			wantWatermark: filepath.Join("state", "main.start.txt"),
+		},
+		{
+			name:          "общая переменная окружения важнее значения кабинета",
+			account:       "main",
+			env:           map[string]string{"API_KEY": "env"},
+			wantAPIKey:    "env",
+			wantWatermark: filepath.Join("state", "main.start.txt"),
+		},
+		{
+			name:          "переменная кабинета важнее общей",
+			account:       "main",
+			env:           map[string]string{"API_KEY": "env", "ACCOUNT_MAIN_API_KEY": "main-env"},
+			wantAPIKey:    "main-env",
+			wantWatermark: filepath.Join("state", "main.start.txt"),
+		},
+		{
+			name:          "переменная другого кабинета не применяется",
+			account:       "other",
+			env:           map[string]string{"ACCOUNT_MAIN_API_KEY": "main-env"},
+			wantAPIKey:    "file",
+			wantWatermark: filepath.Join("state", "other.start.txt"),
+		},
+		{
+			name:          "путь из переменной кабинета не получает префикс",
+			account:       "main",
+			env:           map[string]string{"ACCOUNT_MAIN_WATERMARK_PATH": "main.txt"},
+			wantAPIKey:    "",
+			wantWatermark: "main.txt",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg, _ := buildConfig(values, tt.account)
+			if cfg.Ozon.APIKey != tt.wantAPIKey {
+				t.Errorf("api_key = %q, ожидалось %q", cfg.Ozon.APIKey, tt.wantAPIKey)
+			}
+			if cfg.Ozon.WatermarkPath != tt.wantWatermark {
+				t.Errorf("watermark_path = %q, ожидалось %q", cfg.Ozon.WatermarkPath, tt.wantWatermark)
+			}
+		})
+	}
+}
+
+func TestAccountEnvPrefix(t *testing.T) {
+	tests := []struct {
+		account string
+		want    string
+	}{
+		{"main", "ACCOUNT_MAIN_"},
+		{"shop-2", "ACCOUNT_SHOP_2_"},
+		{"Outlet.ru", "ACCOUNT_OUTLET_RU_"},
+	}
+	for _, tt := range tests {
+		if got := accountEnvPrefix(tt.account); got != tt.want {
+			t.Errorf("accountEnvPrefix(%q) = %q, ожидалось %q", tt.account, got, tt.want)
+		}
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]string
+		want   int
+	}{
+		{
+			name: "известные ключи и таблицы",
+			values: map[string]string{
+				"profile":                               "staging",
+				"quarantine.offer_actions.kettle-1":     "stock",
+				"quarantine.category_actions.defect":    "archive",
+				"ozon.endpoint_timeouts./v1/actions":    "5s",
+				"profiles.staging.log.level":            "debug",
+				"accounts.main.marketplace":             "wildberries",
+				"accounts.main.quarantine.state_path":   "main.json",
+				"accounts.main.notify.telegram.chat_id": "1",
+			},
+		},
+		{
+			name:   "опечатка в имени таблицы",
+			values: map[string]string{"quarantine.ofer_actions.kettle-1": "stock"},
+			want:   1,
+		},
+		{
+			name:   "опечатка в профиле и кабинете",
+			values: map[string]string{"profiles.prod.log.levle": "debug", "accounts.main.ozon.apikey": "x"},
+			want:   2,
+		},
+		{
+			name:   "таблица задана строкой",
+			values: map[string]string{"quarantine.offer_actions": "kettle-1=stock"},
+			want:   1,
+		},
+		{
+			name:   "профиль внутри кабинета",
+			values: map[string]string{"accounts.main.profile": "dry-run"},
+			want:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unknownKeys(tt.values); len(got) != tt.want {
+				t.Errorf("получено %v, ожидалось ошибок: %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeTypedTables(t *testing.T) {
+	cfg := DefaultConfig()
+	problems := decode(reflect.ValueOf(&cfg).Elem(), "", map[string]string{
+		"ozon.endpoint_timeouts./v1/actions": "5s",
+		"ozon.endpoint_timeouts./v2/stocks":  "быстро",
+		"quarantine.offer_actions.kettle-1":  "stock",
+	})
+	if len(problems) != 1 {
+		t.Errorf("получено %v, ожидалась ошибка длительности /v2/stocks", problems)
+	}
+	if got := cfg.Ozon.EndpointTimeouts["/v1/actions"]; got != 5*time.Second {
+		t.Errorf("таймаут /v1/actions = %v, ожидалось 5s", got)
+	}
+	if got := cfg.Quarantine.OfferActions["kettle-1"]; got != "stock" {
+		t.Errorf("действие kettle-1 = %q, ожидалось stock", got)
+	}
+}
@@ -1,43 +1,19 @@
 package params
 
 import (
+	"PriceGuardian/apperrors"
 	"github.com/joho/godotenv"
-	"log"
 	"os"
 )
 
-type ParamName string
+// ConfigPathEnv переменная окружения с путем к TOML файлу конфигурации
+const ConfigPathEnv = "CONFIG_PATH"
 
-const (
-	GIGACHAT_AUTH_DATA ParamName = "GIGACHAT_AUTH_DATA"
-	COOKIES_PATH       ParamName = "COOKIES_PATH"
-	COMPANY_ID         ParamName = "COMPANY_ID"
-	GIGACHAT_PROMPT    ParamName = "GIGACHAT_PROMPT"
-	API_KEY            ParamName = "API_KEY"
-	CLIENT_ID          ParamName = "CLIENT_ID"
-)
-
-type Params map[ParamName]string
-
-func LoadParams() Params {
-	if err := godotenv.Load(); err != nil {
-		log.Fatal(".env файл не найден")
-	}
-	var params = make(Params)
-	paramNames := []ParamName{
-		GIGACHAT_AUTH_DATA,
-		COOKIES_PATH,
-		COMPANY_ID,
-		GIGACHAT_PROMPT,
-		API_KEY,
-		CLIENT_ID,
-	}
-	for _, paramName := range paramNames {
-		value, exists := os.LookupEnv(string(paramName))
-		if !exists {
-			log.Fatalf("Параметр %s не задан", paramName)
-		}
-		params[paramName] = value
+// LoadAccounts загружает .env и файл конфигурации из CONFIG_PATH, если он задан, и возвращает конфигурацию
+// каждого кабинета. Без файла конфигурации все параметры берутся из окружения, как раньше
+func LoadAccounts() ([]Config, error) {
+	if err := godotenv.Load(); err != nil && os.Getenv(ConfigPathEnv) == "" {
+		return nil, apperrors.Wrap(apperrors.Config, err, ".env файл не найден")
 	}
-	return params
+	return LoadConfigs(os.Getenv(ConfigPathEnv))
 }
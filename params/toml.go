@@ -0,0 +1,79 @@
+package params
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseTOML разбирает файл конфигурации. Результат плоский: ключи вложенных таблиц соединяются точкой,
+// значения приводятся к строкам так же, как их задают переменные окружения, а массивы соединяются через запятую
+func parseTOML(r io.Reader) (map[string]string, error) {
+	var doc map[string]interface{}
+	if _, err := toml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	res := make(map[string]string)
+	if err := flatten("", doc, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// flatten добавляет в res значения таблицы table с префиксом ключей prefix
+func flatten(prefix string, table map[string]interface{}, res map[string]string) error {
+	keys := make([]string, 0, len(table))
+	for key := range table {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, name := range keys {
+		key, value := name, table[name]
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			if err := flatten(key, nested, res); err != nil {
+				return err
+			}
+			continue
+		}
+		s, err := formatTOMLValue(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		res[key] = s
+	}
+	return nil
+}
+
+func formatTOMLValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		return v.Format(time.RFC3339), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := formatTOMLValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("неподдерживаемое значение %v", value)
+	}
+}
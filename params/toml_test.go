@@ -0,0 +1,125 @@
+package params
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "пустой файл",
+			input: "",
+			want:  map[string]string{},
+		},
+		{
+			name: "таблицы и типы значений",
+			input: `
+profile = "prod"
+[quarantine]
+dry_run = true
+price_multiplier = 100
+max_share = 0.25
+duration = "72h"
+`,
+			want: map[string]string{
+				"profile":                     "prod",
+				"quarantine.dry_run":          "true",
+				"quarantine.price_multiplier": "100",
+				"quarantine.max_share":        "0.25",
+				"quarantine.duration":         "72h",
+			},
+		},
+		{
+			name: "комментарии и решетка внутри строки",
+			input: `
+# комментарий
+[ozon] # таблица
+company_id = "12#34" # комментарий после значения
+`,
+			want: map[string]string{"ozon.company_id": "12#34"},
+		},
+		{
+			name:  "подчеркивания в числах",
+			input: "[gigachat]\nmax_tokens = 1_000_000\n",
+			want:  map[string]string{"gigachat.max_tokens": "1000000"},
+		},
+		{
+			name:  "вложенные таблицы кабинетов и ключи в кавычках",
+			input: "[accounts.shop2.ozon]\napi_key = 'raw\\string'\n[accounts.\"shop 3\"]\nmarketplace = \"wildberries\"\n",
+			want: map[string]string{
+				"accounts.shop2.ozon.api_key": `raw\string`,
+				"accounts.shop 3.marketplace": "wildberries",
+			},
+		},
+		{
+			name:  "многострочная строка",
+			input: "[gigachat]\nprompt = \"\"\"\nпервая\nвторая\"\"\"\n",
+			want:  map[string]string{"gigachat.prompt": "первая\nвторая"},
+		},
+		{
+			name:  "массив соединяется через запятую",
+			input: "[links]\nmatch = [\"barcode\", \"manual\"]\n",
+			want:  map[string]string{"links.match": "barcode,manual"},
+		},
+		{
+			name:    "повторный ключ",
+			input:   "[ozon]\napi_key = \"a\"\napi_key = \"b\"\n",
+			wantErr: true,
+		},
+		{
+			name:    "незакрытая таблица",
+			input:   "[ozon\napi_key = \"a\"\n",
+			wantErr: true,
+		},
+		{
+			name:    "значение без кавычек",
+			input:   "profile = prod\n",
+			wantErr: true,
+		},
+		{
+			name:    "массив таблиц не поддерживается",
+			input:   "[[accounts]]\nname = \"a\"\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTOML(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ошибка = %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("получено %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccountNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]string
+		want   []string
+	}{
+		{"без кабинетов", map[string]string{"ozon.api_key": "a"}, nil},
+		{
+			"кабинеты отсортированы без повторов",
+			map[string]string{"accounts.b.ozon.api_key": "1", "accounts.a.marketplace": "ozon", "accounts.b.account": "b"},
+			[]string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := accountNames(tt.values); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("получено %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
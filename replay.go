@@ -0,0 +1,129 @@
+package main
+
+import (
+	"PriceGuardian/ozon"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+)
+
+// ReplayOffer решение по товару при повторной классификации
+type ReplayOffer struct {
+	OfferID  string                `json:"offer_id"`
+	Reviews  int                   `json:"reviews"`
+	Negative int                   `json:"negative"`
+	Action   ozon.QuarantineAction `json:"action,omitempty"`
+}
+
+// ReplayDiff отличия от предыдущего прогона
+type ReplayDiff struct {
+	ChangedLabels int      `json:"changed_labels"`
+	Added         []string `json:"added"`
+	Removed       []string `json:"removed"`
+}
+
+// ReplayResult итоги повторной классификации сохраненных отзывов. Цены и остатки не меняются
+type ReplayResult struct {
+	Model       string          `json:"model"`
+	Reviews     int             `json:"reviews"`
+	Negative    int             `json:"negative"`
+	Errors      int             `json:"errors"`
+	Quarantined int             `json:"quarantined"`
+	Labels      map[string]bool `json:"labels"`
+	Offers      []ReplayOffer   `json:"offers"`
+	Diff        *ReplayDiff     `json:"diff,omitempty"`
+}
+
+// Replay классифицирует отзывы и применяет политику карантина, только сообщая о решениях
+func (p *Pipeline) Replay(ctx context.Context, reviews []ozon.Review) ReplayResult {
+	result := ReplayResult{
+		Model:   p.chat.config.Model,
+		Reviews: len(reviews),
+		Labels:  make(map[string]bool, len(reviews)),
+	}
+	logger := slog.With("account", p.account, "replay", true)
+	offers := make(map[string]*ReplayOffer)
+	negativeByOffer := make(map[string][]ozon.Review)
+	for _, review := range reviews {
+		offerID := review.Product.OfferID
+		offer, ok := offers[offerID]
+		if !ok {
+			offer = &ReplayOffer{OfferID: offerID}
+			offers[offerID] = offer
+		}
+		offer.Reviews++
+		verdict, err := p.classify(ctx, review)
+		isNegative := verdict.Negative
+		if err != nil {
+			logger.Error("ошибка при классификации отзыва", "review_id", review.ID, "error", err)
+			result.Errors++
+			continue
+		}
+		result.Labels[review.ID] = isNegative
+		if isNegative {
+			result.Negative++
+			offer.Negative++
+			negativeByOffer[offerID] = append(negativeByOffer[offerID], review)
+		}
+	}
+	for action, offerIDs := range p.decide(logger, negativeByOffer) {
+		for _, offerID := range offerIDs {
+			offers[offerID].Action = action
+			result.Quarantined++
+		}
+	}
+	for _, offer := range offers {
+		result.Offers = append(result.Offers, *offer)
+	}
+	sort.Slice(result.Offers, func(i, j int) bool { return result.Offers[i].OfferID < result.Offers[j].OfferID })
+	return result
+}
+
+// Compare сравнивает результат с предыдущим прогоном по тем же отзывам
+func (result *ReplayResult) Compare(baseline ReplayResult) {
+	diff := &ReplayDiff{}
+	for reviewID, negative := range result.Labels {
+		if before, ok := baseline.Labels[reviewID]; ok && before != negative {
+			diff.ChangedLabels++
+		}
+	}
+	quarantined := func(r ReplayResult) map[string]bool {
+		res := make(map[string]bool)
+		for _, offer := range r.Offers {
+			if offer.Action != "" {
+				res[offer.OfferID] = true
+			}
+		}
+		return res
+	}
+	now, before := quarantined(*result), quarantined(baseline)
+	for offerID := range now {
+		if !before[offerID] {
+			diff.Added = append(diff.Added, offerID)
+		}
+	}
+	for offerID := range before {
+		if !now[offerID] {
+			diff.Removed = append(diff.Removed, offerID)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	result.Diff = diff
+}
+
+// loadReplayResult загружает результат прогона, сохраненный командой replay -format json
+func loadReplayResult(path string) (ReplayResult, error) {
+	var result ReplayResult
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return result, fmt.Errorf("не удалось прочитать результат прогона %w", err)
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, fmt.Errorf("не удалось распарсить результат прогона %w", err)
+	}
+	return result, nil
+}
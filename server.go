@@ -0,0 +1,263 @@
+package main
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/approval"
+	"PriceGuardian/metrics"
+	"PriceGuardian/ozon"
+	"PriceGuardian/params"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+)
+
+// Server HTTP API для просмотра состояния и ручного управления демоном
+type Server struct {
+	*http.Server
+	daemon *Daemon
+	token  string
+}
+
+func NewServer(daemon *Daemon, addr, token string) (*Server, error) {
+	if token == "" {
+		return nil, apperrors.New(apperrors.Config, "для HTTP API необходимо задать токен")
+	}
+	server := &Server{daemon: daemon, token: token}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /quarantine", server.listQuarantine)
+	mux.HandleFunc("POST /quarantine", server.quarantine)
+	mux.HandleFunc("POST /release", server.release)
+	mux.HandleFunc("GET /approvals", server.listApprovals)
+	mux.HandleFunc("POST /approvals/approve", server.approve)
+	mux.HandleFunc("POST /approvals/reject", server.reject)
+	mux.HandleFunc("POST /poll", server.poll)
+	mux.HandleFunc("GET /runs", server.runs)
+	root := http.NewServeMux()
+	// метрики отдаются без токена, чтобы их мог собирать prometheus
+	root.Handle("GET /metrics", metrics.Handler())
+	root.Handle("/", server.authorize(mux))
+	server.Server = &http.Server{Addr: addr, Handler: root}
+	return server, nil
+}
+
+func (server *Server) ListenAndServe() {
+	slog.Info("HTTP API запущен", "addr", server.Addr)
+	if err := server.Server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Error("ошибка HTTP API", "error", err)
+	}
+}
+
+// authorize проверяет статический bearer токен
+func (server *Server) authorize(next http.Handler) http.Handler {
+	expected := []byte("Bearer " + server.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("неверный токен"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type offersRequest struct {
+	// Account имя кабинета, по умолчанию первый
+	Account  string   `json:"account"`
+	OfferIDs []string `json:"offer_ids"`
+	Action   string   `json:"action"`
+}
+
+func decodeOffersRequest(r *http.Request) (offersRequest, error) {
+	var req offersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, fmt.Errorf("ошибка декодирования запроса %v", err)
+	}
+	if len(req.OfferIDs) == 0 {
+		return req, fmt.Errorf("список offer_ids пуст")
+	}
+	return req, nil
+}
+
+// listQuarantine товары на карантине по кабинетам
+func (server *Server) listQuarantine(w http.ResponseWriter, r *http.Request) {
+	res := make(map[string][]ozon.QuarantineRecord, len(server.daemon.pipelines))
+	for _, pipeline := range server.daemon.pipelines {
+		res[pipeline.account] = pipeline.api.Quarantines()
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (server *Server) quarantine(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeOffersRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	pipeline, err := server.daemon.pipeline(req.Account)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	byAction, err := manualActions(pipeline.policy, req.OfferIDs, req.Action)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var quarantined []string
+	for action, ids := range byAction {
+		done, err := server.daemon.Quarantine(r.Context(), req.Account, ids, action)
+		quarantined = append(quarantined, done...)
+		if err != nil {
+			writeError(w, statusFor(err), err)
+			return
+		}
+	}
+	if quarantined == nil {
+		quarantined = []string{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"quarantined": quarantined, "skipped": missing(req.OfferIDs, quarantined)})
+}
+
+func (server *Server) release(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeOffersRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	released, err := server.daemon.Release(r.Context(), req.Account, req.OfferIDs)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	if released == nil {
+		released = []string{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"released": released, "skipped": missing(req.OfferIDs, released)})
+}
+
+// listApprovals товары, ожидающие подтверждения карантина, по кабинетам с включенным подтверждением
+func (server *Server) listApprovals(w http.ResponseWriter, r *http.Request) {
+	res := make(map[string][]approval.Entry, len(server.daemon.pipelines))
+	for _, pipeline := range server.daemon.pipelines {
+		if pipeline.approvals != nil {
+			res[pipeline.account] = pipeline.approvals.Pending()
+		}
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (server *Server) approve(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeOffersRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	quarantined, err := server.daemon.Approve(r.Context(), req.Account, req.OfferIDs)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"quarantined": quarantined})
+}
+
+func (server *Server) reject(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeOffersRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := server.daemon.Reject(req.Account, req.OfferIDs); err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"rejected": req.OfferIDs})
+}
+
+func (server *Server) poll(w http.ResponseWriter, r *http.Request) {
+	server.daemon.PollNow()
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"status": "scheduled"})
+}
+
+func (server *Server) runs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, server.daemon.Runs())
+}
+
+// statusFor код ответа для ошибки, полученной от площадки
+func statusFor(err error) int {
+	switch apperrors.KindOf(err) {
+	case apperrors.Validation, apperrors.Config:
+		return http.StatusBadRequest
+	case apperrors.RateLimit:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("ошибка записи ответа", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// daemonRequest отправляет запрос запущенному демону через его HTTP API, чтобы команда CLI
+// не меняла файлы состояния в обход демона. false, если HTTP API не настроен или демон не запущен:
+// тогда команда выполняется в своем процессе
+func daemonRequest(ctx context.Context, cfg params.Config, path string, req offersRequest) (bool, error) {
+	addr := cfg.HTTP.Addr
+	if addr == "" {
+		return false, nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false, apperrors.Wrap(apperrors.Config, err, "неверный адрес HTTP API %q", addr)
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return false, err
+	}
+	url := "http://" + net.JoinHostPort(host, port) + path
+	request, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer "+cfg.HTTP.Token)
+	response, err := http.DefaultClient.Do(request)
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		slog.Debug("демон не запущен, команда выполняется локально", "addr", addr)
+		return false, nil
+	}
+	if err != nil {
+		return false, apperrors.Wrap(apperrors.Transport, err, "ошибка запроса к демону")
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		var res struct {
+			Error string `json:"error"`
+		}
+		data, _ := io.ReadAll(response.Body)
+		if json.Unmarshal(data, &res) != nil || res.Error == "" {
+			res.Error = string(data)
+		}
+		return true, apperrors.FromStatus(response.StatusCode, res.Error)
+	}
+	slog.Info("команда выполнена запущенным демоном", "addr", addr, "path", path, "offer_ids", req.OfferIDs)
+	return true, nil
+}
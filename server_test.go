@@ -0,0 +1,178 @@
+package main
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/ozon"
+	"PriceGuardian/params"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T, daemon *Daemon) *httptest.Server {
+	server, err := NewServer(daemon, "", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.Handler)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestServerAuthorize(t *testing.T) {
+	ts := newTestServer(t, &Daemon{pipelines: []*Pipeline{newTestPipeline(t, "")}, pollNow: make(chan struct{}, 1)})
+	tests := []struct {
+		name       string
+		path       string
+		header     string
+		wantStatus int
+	}{
+		{"без токена", "/quarantine", "", http.StatusUnauthorized},
+		{"неверный токен", "/quarantine", "Bearer wrong", http.StatusUnauthorized},
+		{"токен без схемы", "/quarantine", "secret", http.StatusUnauthorized},
+		{"верный токен", "/quarantine", "Bearer secret", http.StatusOK},
+		{"метрики без токена", "/metrics", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, _ := http.NewRequest("GET", ts.URL+tt.path, nil)
+			if tt.header != "" {
+				request.Header.Set("Authorization", tt.header)
+			}
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+			if response.StatusCode != tt.wantStatus {
+				t.Errorf("получено %d, ожидалось %d", response.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestServerRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		want       string
+	}{
+		{
+			name: "ручной карантин", method: "POST", path: "/quarantine", body: `{"offer_ids":["a","missing"]}`,
+			wantStatus: http.StatusOK, want: `{"quarantined":["a"],"skipped":["missing"]}`,
+		},
+		{
+			name: "повторный карантин пропускается", method: "POST", path: "/quarantine", body: `{"offer_ids":["held"]}`,
+			wantStatus: http.StatusOK, want: `{"quarantined":[],"skipped":["held"]}`,
+		},
+		{
+			name: "снятие только товаров на карантине", method: "POST", path: "/release", body: `{"offer_ids":["held","a"]}`,
+			wantStatus: http.StatusOK, want: `{"released":["held"],"skipped":["a"]}`,
+		},
+		{
+			name: "неизвестное действие", method: "POST", path: "/quarantine", body: `{"offer_ids":["a"],"action":"delete"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "неизвестный кабинет", method: "POST", path: "/release", body: `{"account":"other","offer_ids":["a"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "пустой список товаров", method: "POST", path: "/release", body: `{"offer_ids":[]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "подтверждение выключено", method: "POST", path: "/approvals/approve", body: `{"offer_ids":["a"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "очередь подтверждения", method: "GET", path: "/approvals",
+			wantStatus: http.StatusOK, want: `{}`,
+		},
+		{
+			name: "внеочередной опрос", method: "POST", path: "/poll",
+			wantStatus: http.StatusAccepted, want: `{"status":"scheduled"}`,
+		},
+		{
+			name: "метод не поддерживается", method: "DELETE", path: "/quarantine",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := newTestPipeline(t, "", "a", "held")
+			if _, err := pipeline.api.Quarantine(context.Background(), []string{"held"}, ozon.ActionPrice, nil); err != nil {
+				t.Fatal(err)
+			}
+			ts := newTestServer(t, &Daemon{pipelines: []*Pipeline{pipeline}, pollNow: make(chan struct{}, 1)})
+			request, _ := http.NewRequest(tt.method, ts.URL+tt.path, strings.NewReader(tt.body))
+			request.Header.Set("Authorization", "Bearer secret")
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("получено %d, ожидалось %d", response.StatusCode, tt.wantStatus)
+			}
+			if tt.want == "" {
+				return
+			}
+			var got, want interface{}
+			if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			json.Unmarshal([]byte(tt.want), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("получено %v, ожидалось %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDaemonRequest(t *testing.T) {
+	pipeline := newTestPipeline(t, "", "a")
+	ts := newTestServer(t, &Daemon{pipelines: []*Pipeline{pipeline}, pollNow: make(chan struct{}, 1)})
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	tests := []struct {
+		name       string
+		addr       string
+		token      string
+		wantRemote bool
+		wantErr    bool
+	}{
+		{"HTTP API не настроен", "", "secret", false, false},
+		{"демон не запущен", closed.Listener.Addr().String(), "secret", false, false},
+		{"неверный токен", ts.Listener.Addr().String(), "wrong", true, true},
+		{"команда выполнена демоном", ts.Listener.Addr().String(), "secret", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := params.DefaultConfig()
+			cfg.HTTP.Addr, cfg.HTTP.Token = tt.addr, tt.token
+			remote, err := daemonRequest(context.Background(), cfg, "/quarantine",
+				offersRequest{OfferIDs: []string{"a"}, Action: string(ozon.ActionPrice)})
+			if remote != tt.wantRemote {
+				t.Errorf("выполнено демоном %v, ожидалось %v", remote, tt.wantRemote)
+			}
+			if tt.wantErr {
+				if apperrors.KindOf(err) != apperrors.Auth {
+					t.Errorf("получено %v, ожидалась ошибка авторизации", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+	if _, ok := pipeline.api.QuarantineRecord("a"); !ok {
+		t.Error("демон не поместил товар на карантин")
+	}
+}
@@ -0,0 +1,87 @@
+package statefile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// lockRetry интервал между попытками захватить блокировку
+	lockRetry = 50 * time.Millisecond
+	// staleLock блокировка старше этого считается оставшейся от упавшего процесса
+	staleLock = time.Minute
+)
+
+// lockTimeout сколько ждать блокировку, прежде чем вернуть ошибку. Переменная, чтобы тесты не ждали
+var lockTimeout = 10 * time.Second
+
+// Lock захватывает блокировку файла состояния path между процессами, например демоном и командой CLI.
+// Блокировкой служит файл <path>.lock, созданный с O_EXCL, т.к. flock недоступен на windows
+func Lock(path string) (unlock func(), err error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprint(file, os.Getpid())
+			file.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("не удалось создать блокировку %s %w", lockPath, err)
+		}
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("файл %s заблокирован другим процессом", path)
+		}
+		time.Sleep(lockRetry)
+	}
+}
+
+// Read декодирует JSON из path в v. Если файла нет, v не меняется и возвращается false
+func Read(path string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Write записывает v в path через временный файл, чтобы читатели не увидели файл записанным наполовину
+func Write(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package statefile
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestLockSerializesUpdates проверяет, что изменения под блокировкой не теряются при одновременной записи
+func TestLockSerializesUpdates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	const writers = 10
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := Lock(path)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer unlock()
+			var counter int
+			if _, err := Read(path, &counter); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := Write(path, counter+1); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	var counter int
+	if _, err := Read(path, &counter); err != nil {
+		t.Fatal(err)
+	}
+	if counter != writers {
+		t.Errorf("получено %d, ожидалось %d", counter, writers)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("файл блокировки не удален: %v", err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	lockTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { lockTimeout = 10 * time.Second })
+	tests := []struct {
+		name    string
+		lockAge time.Duration
+		wantErr bool
+	}{
+		{"блокировка другого процесса", 0, true},
+		{"блокировка упавшего процесса", 2 * staleLock, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.json")
+			if err := os.WriteFile(path+".lock", []byte("1"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			modified := time.Now().Add(-tt.lockAge)
+			if err := os.Chtimes(path+".lock", modified, modified); err != nil {
+				t.Fatal(err)
+			}
+			unlock, err := Lock(path)
+			if tt.wantErr {
+				if err == nil {
+					unlock()
+					t.Fatal("ожидалась ошибка")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			unlock()
+		})
+	}
+}
+
+func TestReadMissing(t *testing.T) {
+	value := 7
+	found, err := Read(filepath.Join(t.TempDir(), "missing.json"), &value)
+	if err != nil || found || value != 7 {
+		t.Errorf("получено %v, %v, %d, ожидалось false, nil, 7", found, err, value)
+	}
+}
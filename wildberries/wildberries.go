@@ -0,0 +1,373 @@
+package wildberries
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/metrics"
+	"PriceGuardian/ozon"
+	"PriceGuardian/params"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxFeedbacksPerRequest ограничение wildberries на количество отзывов в одном запросе
+const maxFeedbacksPerRequest = 5000
+
+// maxCardsPerRequest ограничение wildberries на количество карточек в одном запросе
+const maxCardsPerRequest = 100
+
+// maxGoodsPerRequest ограничение wildberries на количество товаров в одном запросе цен
+const maxGoodsPerRequest = 1000
+
+// Api клиент API отзывов и цен wildberries. offer_id товара соответствует артикулу продавца (vendorCode),
+// а SKU артикулу wildberries (nmID)
+type Api struct {
+	client       *http.Client
+	feedbacksURL string
+	pricesURL    string
+	contentURL   string
+	token        string
+	timeout      time.Duration
+	// nmIDs артикулы wildberries по артикулам продавца, заполняются при загрузке цен
+	nmIDs map[string]int64
+}
+
+func NewApi(cfg params.WildberriesConfig) *Api {
+	return &Api{
+		client:       &http.Client{Transport: metrics.Transport{Calls: &metrics.WildberriesAPICalls}},
+		feedbacksURL: cfg.FeedbacksURL,
+		pricesURL:    cfg.PricesURL,
+		contentURL:   cfg.ContentURL,
+		token:        cfg.Token,
+		timeout:      cfg.Timeout,
+		nmIDs:        make(map[string]int64),
+	}
+}
+
+// Name название площадки
+func (api *Api) Name() string {
+	return "wildberries"
+}
+
+type feedback struct {
+	ID               string `json:"id"`
+	Text             string `json:"text"`
+	Pros             string `json:"pros"`
+	Cons             string `json:"cons"`
+	ProductValuation int    `json:"productValuation"`
+	CreatedDate      string `json:"createdDate"`
+	UserName         string `json:"userName"`
+	ProductDetails   struct {
+		NmID            int64  `json:"nmId"`
+		ProductName     string `json:"productName"`
+		SupplierArticle string `json:"supplierArticle"`
+		BrandName       string `json:"brandName"`
+	} `json:"productDetails"`
+}
+
+// review отзыв в общем для всех площадок виде
+func (f feedback) review() ozon.Review {
+	nmID := strconv.FormatInt(f.ProductDetails.NmID, 10)
+	return ozon.Review{
+		ID:          f.ID,
+		UUID:        f.ID,
+		SKU:         nmID,
+		Text:        ozon.ReviewText{Positive: f.Pros, Negative: f.Cons, Comment: f.Text},
+		PublishedAt: f.CreatedDate,
+		Rating:      f.ProductValuation,
+		AuthorName:  f.UserName,
+		Product: ozon.Product{
+			Title:     f.ProductDetails.ProductName,
+			URL:       "https://www.wildberries.ru/catalog/" + nmID + "/detail.aspx",
+			OfferID:   f.ProductDetails.SupplierArticle,
+			BrandName: f.ProductDetails.BrandName,
+		},
+	}
+}
+
+// response общая обертка ответов API wildberries
+type response[T any] struct {
+	Data      T      `json:"data"`
+	Error     bool   `json:"error"`
+	ErrorText string `json:"errorText"`
+}
+
+// GetReviewsTillTime загружает отзывы, опубликованные после startTime, и время самого нового из них.
+// Wildberries отдает отвеченные и неотвеченные отзывы раздельно, поэтому загружаются оба списка.
+// При ошибке возвращаются отзывы, полученные до нее, вместе с ошибкой
+func (api *Api) GetReviewsTillTime(ctx context.Context, startTime time.Time) ([]*ozon.Review, time.Time, error) {
+	var reviews []*ozon.Review
+	newest := startTime
+	var err error
+	for _, answered := range []bool{false, true} {
+		for skip := 0; ; skip += maxFeedbacksPerRequest {
+			query := url.Values{
+				"isAnswered": {strconv.FormatBool(answered)},
+				"take":       {strconv.Itoa(maxFeedbacksPerRequest)},
+				"skip":       {strconv.Itoa(skip)},
+				"order":      {"dateDesc"},
+			}
+			if !startTime.IsZero() {
+				query.Set("dateFrom", strconv.FormatInt(startTime.Unix(), 10))
+			}
+			var res response[struct {
+				Feedbacks []feedback `json:"feedbacks"`
+			}]
+			if err = api.request(ctx, "GET", api.feedbacksURL+"/api/v1/feedbacks?"+query.Encode(), nil, &res); err != nil {
+				err = fmt.Errorf("отзывы начиная с %v до последней успешно полученной страницы не будут обработаны: %w", startTime, err)
+				break
+			}
+			for _, f := range res.Data.Feedbacks {
+				review := f.review()
+				t := review.PublishedTime()
+				// dateFrom учитывает только секунды, поэтому отзывы на границе отбрасываются здесь
+				if !t.After(startTime) {
+					continue
+				}
+				if t.After(newest) {
+					newest = t
+				}
+				reviews = append(reviews, &review)
+			}
+			slog.Debug("успешно получена страница отзывов wildberries", "answered", answered, "skip", skip,
+				"feedbacks", len(res.Data.Feedbacks))
+			if len(res.Data.Feedbacks) < maxFeedbacksPerRequest {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	sort.Slice(reviews, func(i, j int) bool { return reviews[i].PublishedTime().After(reviews[j].PublishedTime()) })
+	return reviews, newest, err
+}
+
+// Reply публикует ответ продавца на отзыв
+func (api *Api) Reply(ctx context.Context, review ozon.Review, text string) error {
+	body := map[string]string{"id": review.ID, "text": text}
+	return api.request(ctx, "POST", api.feedbacksURL+"/api/v1/feedbacks/answer", body, nil)
+}
+
+type good struct {
+	NmID       int64  `json:"nmID"`
+	VendorCode string `json:"vendorCode"`
+	Sizes      []struct {
+		Price           float64 `json:"price"`
+		DiscountedPrice float64 `json:"discountedPrice"`
+	} `json:"sizes"`
+	CurrencyIsoCode4217 string `json:"currencyIsoCode4217"`
+	Discount            int    `json:"discount"`
+}
+
+// price цена товара в общем для всех площадок виде. Price цена до скидки продавца, которая меняется
+// при карантине, MarketingPrice цена со скидкой
+func (g good) price() ozon.Price {
+	price := ozon.Price{CurrencyCode: g.CurrencyIsoCode4217}
+	if len(g.Sizes) > 0 {
+		price.Price = g.Sizes[0].Price
+		price.MarketingPrice = g.Sizes[0].DiscountedPrice
+	}
+	return price
+}
+
+// goods загружает все товары продавца с ценами и запоминает их артикулы wildberries.
+// Фильтра по артикулу продавца в API нет, поэтому каталог загружается целиком
+func (api *Api) goods(ctx context.Context) ([]good, error) {
+	var goods []good
+	for offset := 0; ; offset += maxGoodsPerRequest {
+		query := url.Values{"limit": {strconv.Itoa(maxGoodsPerRequest)}, "offset": {strconv.Itoa(offset)}}
+		var res response[struct {
+			ListGoods []good `json:"listGoods"`
+		}]
+		if err := api.request(ctx, "GET", api.pricesURL+"/api/v2/list/goods/filter?"+query.Encode(), nil, &res); err != nil {
+			return nil, fmt.Errorf("ошибка при получении цен %w", err)
+		}
+		goods = append(goods, res.Data.ListGoods...)
+		if len(res.Data.ListGoods) < maxGoodsPerRequest {
+			break
+		}
+	}
+	for _, g := range goods {
+		api.nmIDs[g.VendorCode] = g.NmID
+	}
+	return goods, nil
+}
+
+// GetPrices текущие цены товаров по offer_id. Товары, не найденные на площадке, в ответ не попадают
+func (api *Api) GetPrices(ctx context.Context, offerIDs []string) (map[string]ozon.Price, error) {
+	goods, err := api.goods(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(offerIDs))
+	for _, offerID := range offerIDs {
+		wanted[offerID] = true
+	}
+	res := make(map[string]ozon.Price, len(offerIDs))
+	for _, g := range goods {
+		if wanted[g.VendorCode] {
+			res[g.VendorCode] = g.price()
+		}
+	}
+	return res, nil
+}
+
+// SetPrices устанавливает цены до скидки, не меняя скидку продавца, и возвращает offer_id товаров,
+// цены которых отправлены. Wildberries применяет цены асинхронно, ошибки загрузки видны только в кабинете
+func (api *Api) SetPrices(ctx context.Context, prices map[string]ozon.Price) ([]string, error) {
+	for offerID := range prices {
+		if _, ok := api.nmIDs[offerID]; !ok {
+			if _, err := api.goods(ctx); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	type priceItem struct {
+		NmID  int64 `json:"nmID"`
+		Price int   `json:"price"`
+	}
+	var items []priceItem
+	var offerIDs []string
+	for offerID, price := range prices {
+		nmID, ok := api.nmIDs[offerID]
+		if !ok {
+			slog.Warn("товар не найден на wildberries, цена не будет изменена", "offer_id", offerID)
+			continue
+		}
+		if price.Price <= 0 {
+			slog.Warn("цена товара не будет изменена", "offer_id", offerID,
+				"error", apperrors.New(apperrors.Validation, "цена %.2f должна быть больше нуля", price.Price))
+			continue
+		}
+		// wildberries принимает цены только в целых рублях
+		items = append(items, priceItem{NmID: nmID, Price: int(price.Price + 0.5)})
+		offerIDs = append(offerIDs, offerID)
+	}
+	if len(items) == 0 {
+		return nil, apperrors.New(apperrors.Validation, "ни одна из цен не прошла проверку")
+	}
+	var updated []string
+	for i := 0; i < len(items); i += maxGoodsPerRequest {
+		end := min(i+maxGoodsPerRequest, len(items))
+		var res response[struct {
+			ID            int64 `json:"id"`
+			AlreadyExists bool  `json:"alreadyExists"`
+		}]
+		body := map[string]interface{}{"data": items[i:end]}
+		if err := api.request(ctx, "POST", api.pricesURL+"/api/v2/upload/task", body, &res); err != nil {
+			metrics.PriceChanges.Add(float64(end-i), "failed")
+			return updated, fmt.Errorf("ошибка при загрузке цен %w", err)
+		}
+		metrics.PriceChanges.Add(float64(end-i), "succeeded")
+		slog.Info("цены отправлены в wildberries", "task_id", res.Data.ID, "offers", end-i)
+		updated = append(updated, offerIDs[i:end]...)
+	}
+	return updated, nil
+}
+
+// Barcodes штрихкоды всех товаров кабинета по offer_id. Штрихкоды берутся из размеров карточек товаров,
+// поэтому токен должен иметь доступ к категории "Контент"
+func (api *Api) Barcodes(ctx context.Context) (map[string][]string, error) {
+	type cursor struct {
+		Limit     int    `json:"limit"`
+		UpdatedAt string `json:"updatedAt,omitempty"`
+		NmID      int64  `json:"nmID,omitempty"`
+	}
+	type request struct {
+		Settings struct {
+			Cursor cursor `json:"cursor"`
+			Filter struct {
+				WithPhoto int `json:"withPhoto"`
+			} `json:"filter"`
+		} `json:"settings"`
+	}
+	var body request
+	body.Settings.Cursor.Limit = maxCardsPerRequest
+	body.Settings.Filter.WithPhoto = -1
+	res := make(map[string][]string)
+	for {
+		var page struct {
+			Cards []struct {
+				NmID       int64  `json:"nmID"`
+				VendorCode string `json:"vendorCode"`
+				Sizes      []struct {
+					Skus []string `json:"skus"`
+				} `json:"sizes"`
+			} `json:"cards"`
+			Cursor struct {
+				UpdatedAt string `json:"updatedAt"`
+				NmID      int64  `json:"nmID"`
+				Total     int    `json:"total"`
+			} `json:"cursor"`
+		}
+		if err := api.request(ctx, "POST", api.contentURL+"/content/v2/get/cards/list", body, &page); err != nil {
+			return nil, fmt.Errorf("ошибка при получении карточек товаров %w", err)
+		}
+		for _, card := range page.Cards {
+			for _, size := range card.Sizes {
+				res[card.VendorCode] = append(res[card.VendorCode], size.Skus...)
+			}
+			api.nmIDs[card.VendorCode] = card.NmID
+		}
+		if page.Cursor.Total < maxCardsPerRequest {
+			break
+		}
+		body.Settings.Cursor.UpdatedAt = page.Cursor.UpdatedAt
+		body.Settings.Cursor.NmID = page.Cursor.NmID
+	}
+	return res, nil
+}
+
+// request отправляет запрос к API wildberries и декодирует ответ в out
+func (api *Api) request(ctx context.Context, method, endpoint string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("ошибка сериализации данных: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	ctx, cancel := context.WithTimeout(ctx, api.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	req.Header.Set("Authorization", api.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := api.client.Do(req)
+	if err != nil {
+		return apperrors.Wrap(apperrors.Transport, err, "ошибка отправки запроса")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return apperrors.FromStatus(resp.StatusCode, string(respBody))
+	}
+	if out == nil {
+		return nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return apperrors.Wrap(apperrors.Transport, err, "ошибка чтения ответа")
+	}
+	var envelope response[json.RawMessage]
+	if err := json.Unmarshal(data, &envelope); err == nil && envelope.Error {
+		return apperrors.New(apperrors.Validation, "wildberries вернул ошибку: %s", envelope.ErrorText)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("ошибка декодирования ответа: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package wildberries
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/ozon"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestApi(t *testing.T, handler http.HandlerFunc) *Api {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &Api{client: server.Client(), feedbacksURL: server.URL, pricesURL: server.URL, contentURL: server.URL,
+		timeout: time.Second, nmIDs: make(map[string]int64)}
+}
+
+func TestFeedbackReview(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want ozon.Review
+	}{
+		{
+			name: "полный отзыв",
+			data: `{"id":"f1","text":"коротко","pros":"цена","cons":"брак","productValuation":2,
+				"createdDate":"2024-05-01T10:00:00Z","userName":"Иван",
+				"productDetails":{"nmId":12345,"productName":"Чайник","supplierArticle":"kettle-1","brandName":"Бренд"}}`,
+			want: ozon.Review{ID: "f1", UUID: "f1", SKU: "12345",
+				Text:        ozon.ReviewText{Positive: "цена", Negative: "брак", Comment: "коротко"},
+				PublishedAt: "2024-05-01T10:00:00Z", Rating: 2, AuthorName: "Иван",
+				Product: ozon.Product{Title: "Чайник", URL: "https://www.wildberries.ru/catalog/12345/detail.aspx",
+					OfferID: "kettle-1", BrandName: "Бренд"}},
+		},
+		{
+			name: "отзыв без текста",
+			data: `{"id":"f2","productValuation":5,"createdDate":"2024-05-02T10:00:00Z","productDetails":{"nmId":1}}`,
+			want: ozon.Review{ID: "f2", UUID: "f2", SKU: "1", PublishedAt: "2024-05-02T10:00:00Z", Rating: 5,
+				Product: ozon.Product{URL: "https://www.wildberries.ru/catalog/1/detail.aspx"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f feedback
+			if err := json.Unmarshal([]byte(tt.data), &f); err != nil {
+				t.Fatal(err)
+			}
+			if got := f.review(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("получено %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoodPrice(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want ozon.Price
+	}{
+		{"цена первого размера", `{"nmID":1,"vendorCode":"a","currencyIsoCode4217":"RUB",
+			"sizes":[{"price":1000,"discountedPrice":700},{"price":1200,"discountedPrice":840}]}`,
+			ozon.Price{Price: 1000, MarketingPrice: 700, CurrencyCode: "RUB"}},
+		{"без размеров", `{"nmID":1,"vendorCode":"a","currencyIsoCode4217":"RUB","sizes":[]}`,
+			ozon.Price{CurrencyCode: "RUB"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var g good
+			if err := json.Unmarshal([]byte(tt.data), &g); err != nil {
+				t.Fatal(err)
+			}
+			if got := g.price(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("получено %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetReviewsTillTime(t *testing.T) {
+	pages := map[string]string{
+		"false": `{"data":{"feedbacks":[
+			{"id":"new","createdDate":"2024-05-03T10:00:00Z","productDetails":{"nmId":1}},
+			{"id":"old","createdDate":"2024-05-01T10:00:00Z","productDetails":{"nmId":1}}]},
+			"error":false,"errorText":""}`,
+		"true": `{"data":{"feedbacks":[
+			{"id":"answered","createdDate":"2024-05-02T10:00:00Z","productDetails":{"nmId":2}}]},
+			"error":false,"errorText":""}`,
+	}
+	api := newTestApi(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(pages[r.URL.Query().Get("isAnswered")]))
+	})
+	tests := []struct {
+		name       string
+		start      time.Time
+		want       []string
+		wantNewest time.Time
+	}{
+		{"все отзывы", time.Time{}, []string{"new", "answered", "old"}, time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC)},
+		{"после startTime", time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC), []string{"new"}, time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC)},
+		{"новых нет", time.Date(2024, 5, 4, 0, 0, 0, 0, time.UTC), nil, time.Date(2024, 5, 4, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reviews, newest, err := api.GetReviewsTillTime(context.Background(), tt.start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, review := range reviews {
+				got = append(got, review.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("получено %v, ожидалось %v", got, tt.want)
+			}
+			if !newest.Equal(tt.wantNewest) {
+				t.Errorf("самый новый отзыв %v, ожидалось %v", newest, tt.wantNewest)
+			}
+		})
+	}
+}
+
+func TestGetPrices(t *testing.T) {
+	api := newTestApi(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"listGoods":[
+			{"nmID":1,"vendorCode":"a","currencyIsoCode4217":"RUB","sizes":[{"price":1000,"discountedPrice":900}]},
+			{"nmID":2,"vendorCode":"b","currencyIsoCode4217":"RUB","sizes":[{"price":500,"discountedPrice":500}]}]},
+			"error":false,"errorText":""}`))
+	})
+	got, err := api.GetPrices(context.Background(), []string{"a", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]ozon.Price{"a": {Price: 1000, MarketingPrice: 900, CurrencyCode: "RUB"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("получено %v, ожидалось %v", got, want)
+	}
+	if wantIDs := map[string]int64{"a": 1, "b": 2}; !reflect.DeepEqual(api.nmIDs, wantIDs) {
+		t.Errorf("артикулы wildberries %v, ожидалось %v", api.nmIDs, wantIDs)
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   apperrors.Kind
+	}{
+		{"ошибка в ответе", http.StatusOK, `{"data":null,"error":true,"errorText":"неверный запрос"}`, apperrors.Validation},
+		{"неверный токен", http.StatusUnauthorized, `{"title":"unauthorized"}`, apperrors.Auth},
+		{"лимит запросов", http.StatusTooManyRequests, ``, apperrors.RateLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestApi(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+			var out response[json.RawMessage]
+			err := api.request(context.Background(), "GET", api.feedbacksURL+"/api/v1/feedbacks", nil, &out)
+			if err == nil {
+				t.Fatal("ожидалась ошибка")
+			}
+			if got := apperrors.KindOf(err); got != tt.want {
+				t.Errorf("получено %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
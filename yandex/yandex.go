@@ -0,0 +1,396 @@
+package yandex
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/metrics"
+	"PriceGuardian/ozon"
+	"PriceGuardian/params"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxFeedbacksPerRequest ограничение маркета на количество отзывов на одной странице
+const maxFeedbacksPerRequest = 50
+
+// maxOffersPerRequest ограничение маркета на количество товаров в одном запросе цен и карточек
+const maxOffersPerRequest = 200
+
+// Api клиент Партнерского API Яндекс Маркета. Отзывы и карточки товаров относятся к кабинету (business),
+// а цены к магазину (campaign)
+type Api struct {
+	client     *http.Client
+	url        string
+	token      string
+	businessID string
+	campaignID string
+	timeout    time.Duration
+	// offers карточки товаров по идентификатору модели маркета, nil пока карточки не загружены
+	offers map[int64]offer
+}
+
+func NewApi(cfg params.YandexConfig) *Api {
+	return &Api{
+		client:     &http.Client{Transport: metrics.Transport{Calls: &metrics.YandexAPICalls}},
+		url:        strings.TrimSuffix(cfg.URL, "/"),
+		token:      cfg.Token,
+		businessID: cfg.BusinessID,
+		campaignID: cfg.CampaignID,
+		timeout:    cfg.Timeout,
+	}
+}
+
+// Name название площадки
+func (api *Api) Name() string {
+	return "yandex"
+}
+
+// offer карточка товара продавца, к которой маркет привязывает отзывы через модель
+type offer struct {
+	OfferID   string
+	Name      string
+	Vendor    string
+	MarketSKU int64
+}
+
+type feedback struct {
+	FeedbackID  int64  `json:"feedbackId"`
+	CreatedAt   string `json:"createdAt"`
+	Author      string `json:"author"`
+	Identifiers struct {
+		ModelID int64 `json:"modelId"`
+	} `json:"identifiers"`
+	Description struct {
+		Advantages    string `json:"advantages"`
+		Disadvantages string `json:"disadvantages"`
+		Comment       string `json:"comment"`
+	} `json:"description"`
+	Statistics struct {
+		Rating int `json:"rating"`
+	} `json:"statistics"`
+}
+
+// review отзыв в общем для всех площадок виде
+func (f feedback) review(card offer) ozon.Review {
+	id := strconv.FormatInt(f.FeedbackID, 10)
+	return ozon.Review{
+		ID:          id,
+		UUID:        id,
+		SKU:         strconv.FormatInt(card.MarketSKU, 10),
+		Text:        ozon.ReviewText{Positive: f.Description.Advantages, Negative: f.Description.Disadvantages, Comment: f.Description.Comment},
+		PublishedAt: f.CreatedAt,
+		Rating:      f.Statistics.Rating,
+		AuthorName:  f.Author,
+		Product: ozon.Product{
+			Title:     card.Name,
+			URL:       "https://market.yandex.ru/product/" + strconv.FormatInt(f.Identifiers.ModelID, 10),
+			OfferID:   card.OfferID,
+			BrandName: card.Vendor,
+		},
+	}
+}
+
+// paging курсор следующей страницы, пустой на последней странице
+type paging struct {
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// response общая обертка ответов Партнерского API
+type response[T any] struct {
+	Status string `json:"status"`
+	Result T      `json:"result"`
+	Errors []struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// GetReviewsTillTime загружает отзывы, опубликованные после startTime, и время самого нового из них.
+// Отзывы на маркете привязаны к модели, а не к товару продавца, поэтому offer_id определяется по карточкам
+// кабинета. Если модели соответствует несколько товаров, берется первый. Отзывы о моделях без карточки
+// пропускаются, т.к. их товар нельзя поместить на карантин. При ошибке возвращаются отзывы, полученные до нее
+func (api *Api) GetReviewsTillTime(ctx context.Context, startTime time.Time) ([]*ozon.Review, time.Time, error) {
+	var reviews []*ozon.Review
+	newest := startTime
+	// карточки загружаются заново при каждом опросе, чтобы учесть новые товары
+	api.offers = nil
+	body := map[string]string{}
+	if !startTime.IsZero() {
+		body["dateTimeFrom"] = startTime.Format(time.RFC3339)
+	}
+	pageToken := ""
+	for {
+		query := url.Values{"limit": {strconv.Itoa(maxFeedbacksPerRequest)}}
+		if pageToken != "" {
+			query.Set("page_token", pageToken)
+		}
+		var res response[struct {
+			Feedbacks []feedback `json:"feedbacks"`
+			Paging    paging     `json:"paging"`
+		}]
+		path := "/businesses/" + api.businessID + "/goods-feedback?" + query.Encode()
+		if err := api.request(ctx, path, body, &res); err != nil {
+			return sortReviews(reviews), newest, fmt.Errorf(
+				"отзывы начиная с %v до последней успешно полученной страницы не будут обработаны: %w", startTime, err)
+		}
+		for _, f := range res.Result.Feedbacks {
+			card, err := api.offer(ctx, f.Identifiers.ModelID)
+			if err != nil {
+				return sortReviews(reviews), newest, err
+			}
+			if card.OfferID == "" {
+				slog.Warn("отзыв о модели без карточки продавца пропущен", "feedback_id", f.FeedbackID,
+					"model_id", f.Identifiers.ModelID)
+				continue
+			}
+			review := f.review(card)
+			t := review.PublishedTime()
+			if !t.After(startTime) {
+				continue
+			}
+			if t.After(newest) {
+				newest = t
+			}
+			reviews = append(reviews, &review)
+		}
+		slog.Debug("успешно получена страница отзывов маркета", "feedbacks", len(res.Result.Feedbacks),
+			"next_page_token", res.Result.Paging.NextPageToken)
+		if pageToken = res.Result.Paging.NextPageToken; pageToken == "" {
+			break
+		}
+	}
+	return sortReviews(reviews), newest, nil
+}
+
+// sortReviews упорядочивает отзывы от новых к старым, как их отдает озон
+func sortReviews(reviews []*ozon.Review) []*ozon.Review {
+	sort.Slice(reviews, func(i, j int) bool { return reviews[i].PublishedTime().After(reviews[j].PublishedTime()) })
+	return reviews
+}
+
+// offerMapping карточка товара кабинета вместе с ее привязкой к модели маркета
+type offerMapping struct {
+	Offer struct {
+		OfferID  string   `json:"offerId"`
+		Name     string   `json:"name"`
+		Vendor   string   `json:"vendor"`
+		Barcodes []string `json:"barcodes"`
+	} `json:"offer"`
+	Mapping struct {
+		MarketSKU     int64 `json:"marketSku"`
+		MarketModelID int64 `json:"marketModelId"`
+	} `json:"mapping"`
+}
+
+// offerMappings загружает все карточки товаров кабинета постранично и передает каждую в fn
+func (api *Api) offerMappings(ctx context.Context, fn func(offerMapping)) error {
+	pageToken := ""
+	for {
+		query := url.Values{"limit": {strconv.Itoa(maxOffersPerRequest)}}
+		if pageToken != "" {
+			query.Set("page_token", pageToken)
+		}
+		var res response[struct {
+			OfferMappings []offerMapping `json:"offerMappings"`
+			Paging        paging         `json:"paging"`
+		}]
+		path := "/businesses/" + api.businessID + "/offer-mappings?" + query.Encode()
+		if err := api.request(ctx, path, map[string]string{}, &res); err != nil {
+			return fmt.Errorf("ошибка при получении карточек товаров %w", err)
+		}
+		for _, m := range res.Result.OfferMappings {
+			fn(m)
+		}
+		if pageToken = res.Result.Paging.NextPageToken; pageToken == "" {
+			return nil
+		}
+	}
+}
+
+// offer карточка товара по модели маркета. Карточки кабинета загружаются целиком при первом обращении
+func (api *Api) offer(ctx context.Context, modelID int64) (offer, error) {
+	if api.offers != nil {
+		return api.offers[modelID], nil
+	}
+	offers := make(map[int64]offer)
+	err := api.offerMappings(ctx, func(m offerMapping) {
+		if _, ok := offers[m.Mapping.MarketModelID]; ok || m.Mapping.MarketModelID == 0 {
+			return
+		}
+		offers[m.Mapping.MarketModelID] = offer{
+			OfferID:   m.Offer.OfferID,
+			Name:      m.Offer.Name,
+			Vendor:    m.Offer.Vendor,
+			MarketSKU: m.Mapping.MarketSKU,
+		}
+	})
+	if err != nil {
+		return offer{}, err
+	}
+	api.offers = offers
+	return offers[modelID], nil
+}
+
+// Barcodes штрихкоды всех товаров кабинета по offer_id
+func (api *Api) Barcodes(ctx context.Context) (map[string][]string, error) {
+	res := make(map[string][]string)
+	err := api.offerMappings(ctx, func(m offerMapping) {
+		res[m.Offer.OfferID] = m.Offer.Barcodes
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Reply публикует ответ продавца на отзыв
+func (api *Api) Reply(ctx context.Context, review ozon.Review, text string) error {
+	feedbackID, err := strconv.ParseInt(review.ID, 10, 64)
+	if err != nil {
+		return apperrors.New(apperrors.Validation, "неверный идентификатор отзыва маркета %q", review.ID)
+	}
+	body := map[string]interface{}{
+		"feedbackId": feedbackID,
+		"comment":    map[string]string{"text": text},
+	}
+	return api.request(ctx, "/businesses/"+api.businessID+"/goods-feedback/comments/update", body, nil)
+}
+
+type offerPrice struct {
+	Value        float64 `json:"value"`
+	CurrencyID   string  `json:"currencyId"`
+	DiscountBase float64 `json:"discountBase,omitempty"`
+}
+
+// GetPrices текущие цены магазина по offer_id. Товары, не найденные на площадке, в ответ не попадают.
+// Цена до скидки маркета (discountBase) соответствует зачеркнутой цене озона
+func (api *Api) GetPrices(ctx context.Context, offerIDs []string) (map[string]ozon.Price, error) {
+	res := make(map[string]ozon.Price, len(offerIDs))
+	for i := 0; i < len(offerIDs); i += maxOffersPerRequest {
+		end := min(i+maxOffersPerRequest, len(offerIDs))
+		pageToken := ""
+		for {
+			query := url.Values{"limit": {strconv.Itoa(maxOffersPerRequest)}}
+			if pageToken != "" {
+				query.Set("page_token", pageToken)
+			}
+			var page response[struct {
+				Offers []struct {
+					OfferID string     `json:"offerId"`
+					Price   offerPrice `json:"price"`
+				} `json:"offers"`
+				Paging paging `json:"paging"`
+			}]
+			path := "/campaigns/" + api.campaignID + "/offer-prices?" + query.Encode()
+			if err := api.request(ctx, path, map[string]interface{}{"offerIds": offerIDs[i:end]}, &page); err != nil {
+				return nil, fmt.Errorf("ошибка при получении цен %w", err)
+			}
+			for _, item := range page.Result.Offers {
+				res[item.OfferID] = ozon.Price{
+					Price:        item.Price.Value,
+					OldPrice:     item.Price.DiscountBase,
+					CurrencyCode: item.Price.CurrencyID,
+				}
+			}
+			if pageToken = page.Result.Paging.NextPageToken; pageToken == "" {
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+// SetPrices устанавливает цены магазина и возвращает offer_id товаров, цены которых отправлены.
+// Нулевая зачеркнутая цена убирает скидку. Маркет применяет цены асинхронно
+func (api *Api) SetPrices(ctx context.Context, prices map[string]ozon.Price) ([]string, error) {
+	type priceItem struct {
+		OfferID string     `json:"offerId"`
+		Price   offerPrice `json:"price"`
+	}
+	var items []priceItem
+	for offerID, price := range prices {
+		if err := ozon.ValidatePrice(price); err != nil {
+			slog.Warn("цена товара не будет изменена", "offer_id", offerID, "error", err)
+			continue
+		}
+		currency := price.CurrencyCode
+		if currency == "" {
+			currency = "RUR"
+		}
+		items = append(items, priceItem{OfferID: offerID, Price: offerPrice{
+			Value:        price.Price,
+			CurrencyID:   currency,
+			DiscountBase: price.OldPrice,
+		}})
+	}
+	if len(items) == 0 {
+		return nil, apperrors.New(apperrors.Validation, "ни одна из цен не прошла проверку")
+	}
+	var updated []string
+	for i := 0; i < len(items); i += maxOffersPerRequest {
+		end := min(i+maxOffersPerRequest, len(items))
+		body := map[string]interface{}{"offers": items[i:end]}
+		if err := api.request(ctx, "/campaigns/"+api.campaignID+"/offer-prices/updates", body, nil); err != nil {
+			metrics.PriceChanges.Add(float64(end-i), "failed")
+			return updated, fmt.Errorf("ошибка при обновлении цен %w", err)
+		}
+		metrics.PriceChanges.Add(float64(end-i), "succeeded")
+		for _, item := range items[i:end] {
+			updated = append(updated, item.OfferID)
+		}
+	}
+	slog.Info("цены отправлены в маркет", "offers", len(updated))
+	return updated, nil
+}
+
+// request отправляет POST запрос к Партнерскому API и декодирует ответ в out
+func (api *Api) request(ctx context.Context, path string, body interface{}, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации данных: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, api.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", api.url+path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+api.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := api.client.Do(req)
+	if err != nil {
+		return apperrors.Wrap(apperrors.Transport, err, "ошибка отправки запроса")
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return apperrors.Wrap(apperrors.Transport, err, "ошибка чтения ответа")
+	}
+	if resp.StatusCode >= 400 {
+		return apperrors.FromStatus(resp.StatusCode, string(respBody))
+	}
+	var envelope response[json.RawMessage]
+	if err := json.Unmarshal(respBody, &envelope); err == nil && envelope.Status == "ERROR" {
+		messages := make([]string, 0, len(envelope.Errors))
+		for _, e := range envelope.Errors {
+			messages = append(messages, e.Code+": "+e.Message)
+		}
+		return apperrors.New(apperrors.Validation, "маркет вернул ошибку: %s", strings.Join(messages, "; "))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("ошибка декодирования ответа: %w", err)
+	}
+	return nil
+}
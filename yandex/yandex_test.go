@@ -0,0 +1,194 @@
+package yandex
+
+import (
+	"PriceGuardian/apperrors"
+	"PriceGuardian/ozon"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// offerMappingsPage карточки кабинета: модель 10 привязана к двум товарам, модель 30 без карточки
+const offerMappingsPage = `{"status":"OK","result":{"offerMappings":[
+	{"offer":{"offerId":"kettle-1","name":"Чайник","vendor":"Бренд","barcodes":["4600000000001"]},
+		"mapping":{"marketSku":111,"marketModelId":10}},
+	{"offer":{"offerId":"kettle-2","name":"Чайник","vendor":"Бренд","barcodes":["4600000000002","4600000000003"]},
+		"mapping":{"marketSku":112,"marketModelId":10}},
+	{"offer":{"offerId":"cup","name":"Кружка","vendor":"","barcodes":[]},
+		"mapping":{"marketSku":222,"marketModelId":20}}],
+	"paging":{}}}`
+
+// feedbackPages страницы отзывов по page_token
+var feedbackPages = map[string]string{
+	"": `{"status":"OK","result":{"feedbacks":[
+		{"feedbackId":1,"createdAt":"2024-05-03T10:00:00+03:00","author":"Иван","identifiers":{"modelId":10},
+			"description":{"advantages":"цена","disadvantages":"течет","comment":"вернул"},"statistics":{"rating":1}},
+		{"feedbackId":2,"createdAt":"2024-05-02T10:00:00+03:00","identifiers":{"modelId":30},"statistics":{"rating":2}}],
+		"paging":{"nextPageToken":"p2"}}}`,
+	"p2": `{"status":"OK","result":{"feedbacks":[
+		{"feedbackId":3,"createdAt":"2024-05-01T10:00:00+03:00","identifiers":{"modelId":20},"statistics":{"rating":5}}],
+		"paging":{}}}`,
+}
+
+func newTestApi(t *testing.T, handler http.Handler) *Api {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &Api{client: server.Client(), url: server.URL, businessID: "1", campaignID: "2", timeout: time.Second}
+}
+
+func marketHandler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/businesses/1/offer-mappings", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(offerMappingsPage))
+	})
+	mux.HandleFunc("/businesses/1/goods-feedback", func(w http.ResponseWriter, r *http.Request) {
+		page, ok := feedbackPages[r.URL.Query().Get("page_token")]
+		if !ok {
+			t.Errorf("неизвестная страница %q", r.URL.Query().Get("page_token"))
+		}
+		w.Write([]byte(page))
+	})
+	mux.HandleFunc("/campaigns/2/offer-prices", func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			OfferIDs []string `json:"offerIds"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("неверный запрос цен %v", err)
+		}
+		if !reflect.DeepEqual(request.OfferIDs, []string{"kettle-1", "cup", "missing"}) {
+			t.Errorf("запрошены цены %v", request.OfferIDs)
+		}
+		if r.URL.Query().Get("page_token") == "" {
+			w.Write([]byte(`{"status":"OK","result":{"offers":[
+				{"offerId":"kettle-1","price":{"value":900,"currencyId":"RUR","discountBase":1200}}],
+				"paging":{"nextPageToken":"p2"}}}`))
+			return
+		}
+		w.Write([]byte(`{"status":"OK","result":{"offers":[
+			{"offerId":"cup","price":{"value":300,"currencyId":"RUR"}}],"paging":{}}}`))
+	})
+	return mux
+}
+
+func TestFeedbackReview(t *testing.T) {
+	var f feedback
+	data := `{"feedbackId":7,"createdAt":"2024-05-03T10:00:00+03:00","author":"Иван","identifiers":{"modelId":10},
+		"description":{"advantages":"цена","disadvantages":"течет","comment":"вернул"},"statistics":{"rating":1}}`
+	if err := json.Unmarshal([]byte(data), &f); err != nil {
+		t.Fatal(err)
+	}
+	got := f.review(offer{OfferID: "kettle-1", Name: "Чайник", Vendor: "Бренд", MarketSKU: 111})
+	want := ozon.Review{ID: "7", UUID: "7", SKU: "111",
+		Text:        ozon.ReviewText{Positive: "цена", Negative: "течет", Comment: "вернул"},
+		PublishedAt: "2024-05-03T10:00:00+03:00", Rating: 1, AuthorName: "Иван",
+		Product: ozon.Product{Title: "Чайник", URL: "https://market.yandex.ru/product/10", OfferID: "kettle-1", BrandName: "Бренд"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("получено %+v, ожидалось %+v", got, want)
+	}
+}
+
+func TestGetReviewsTillTime(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	tests := []struct {
+		name       string
+		start      time.Time
+		want       map[string]string
+		wantNewest time.Time
+	}{
+		{
+			name:       "все отзывы, модель без карточки пропускается",
+			want:       map[string]string{"1": "kettle-1", "3": "cup"},
+			wantNewest: time.Date(2024, 5, 3, 10, 0, 0, 0, msk),
+		},
+		{
+			name:       "после startTime",
+			start:      time.Date(2024, 5, 1, 10, 0, 0, 0, msk),
+			want:       map[string]string{"1": "kettle-1"},
+			wantNewest: time.Date(2024, 5, 3, 10, 0, 0, 0, msk),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestApi(t, marketHandler(t))
+			reviews, newest, err := api.GetReviewsTillTime(context.Background(), tt.start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]string, len(reviews))
+			for _, review := range reviews {
+				got[review.ID] = review.Product.OfferID
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("получено %v, ожидалось %v", got, tt.want)
+			}
+			if !newest.Equal(tt.wantNewest) {
+				t.Errorf("самый новый отзыв %v, ожидалось %v", newest, tt.wantNewest)
+			}
+		})
+	}
+}
+
+func TestBarcodes(t *testing.T) {
+	api := newTestApi(t, marketHandler(t))
+	got, err := api.Barcodes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"kettle-1": {"4600000000001"},
+		"kettle-2": {"4600000000002", "4600000000003"},
+		"cup":      {},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("получено %v, ожидалось %v", got, want)
+	}
+}
+
+func TestGetPrices(t *testing.T) {
+	api := newTestApi(t, marketHandler(t))
+	got, err := api.GetPrices(context.Background(), []string{"kettle-1", "cup", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]ozon.Price{
+		"kettle-1": {Price: 900, OldPrice: 1200, CurrencyCode: "RUR"},
+		"cup":      {Price: 300, CurrencyCode: "RUR"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("получено %v, ожидалось %v", got, want)
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   apperrors.Kind
+	}{
+		{"ошибка в ответе", http.StatusOK, `{"status":"ERROR","errors":[{"code":"BAD_REQUEST","message":"неверный запрос"}]}`, apperrors.Validation},
+		{"неверный токен", http.StatusUnauthorized, `{"status":"ERROR"}`, apperrors.Auth},
+		{"лимит запросов", http.StatusTooManyRequests, ``, apperrors.RateLimit},
+		{"сервер недоступен", http.StatusServiceUnavailable, ``, apperrors.Transport},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestApi(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			var out response[json.RawMessage]
+			err := api.request(context.Background(), "/businesses/1/goods-feedback", map[string]string{}, &out)
+			if err == nil {
+				t.Fatal("ожидалась ошибка")
+			}
+			if got := apperrors.KindOf(err); got != tt.want {
+				t.Errorf("получено %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}